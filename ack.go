@@ -0,0 +1,161 @@
+package gosqs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// defaultManualAckMaxHold is used when ManualAckMaxHold is not configured
+const defaultManualAckMaxHold = time.Hour
+
+// PendingMessage is a message whose outcome is only known after its handler returned, e.g. once an asynchronous callback
+// arrives. Its visibility is extended until Ack or Nack is called or until the consumer's ManualAckMaxHold has passed,
+// after which it is redelivered once its visibility timeout expires
+type PendingMessage struct {
+	msg *message
+
+	mu       sync.Mutex
+	consumer *consumer
+	finished bool
+	expired  bool
+	stop     chan struct{}
+}
+
+// Defer is called by a handler to acknowledge the message later with Ack or Nack instead of by returning from the handler.
+// The handler must return nil once the message is deferred, if it returns an error the message is retried as usual and
+// Ack and Nack return ErrAckFinished. Messages of AtomicBatches and messages that were not received by a gosqs consumer
+// cannot be deferred and return ErrNotDeferrable
+func Defer(m Message) (*PendingMessage, error) {
+	msg, ok := m.(*message)
+	if !ok || msg.owner == nil || msg.batch != nil {
+		return nil, ErrNotDeferrable
+	}
+
+	if msg.pending == nil {
+		msg.pending = &PendingMessage{msg: msg, consumer: msg.owner, stop: make(chan struct{})}
+	}
+	return msg.pending, nil
+}
+
+// Message returns the deferred message
+func (p *PendingMessage) Message() Message {
+	return p.msg
+}
+
+// Ack deletes the message from the queue, the request is cancelled with the context. It returns ErrAckExpired once the
+// max hold duration has passed or once the consumer was shut down
+func (p *PendingMessage) Ack(ctx context.Context) error {
+	c, err := p.finish()
+	if err != nil {
+		return err
+	}
+	defer c.life.untrack(p.msg)
+
+	if c.externalAck != nil {
+		return c.delete(p.msg)
+	}
+
+	if _, err := c.sqs.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{QueueUrl: &c.QueueURL, ReceiptHandle: p.msg.ReceiptHandle}); err != nil {
+		err = ErrUnableToDelete.Context(err)
+		c.deleteFailed(p.msg, DeleteFailure{Err: err})
+		return err
+	}
+	return nil
+}
+
+// Nack makes the message visible again right away so it is redelivered, it stops waiting for the change once the context
+// is done. It returns ErrAckExpired once the max hold duration has passed or once the consumer was shut down
+func (p *PendingMessage) Nack(ctx context.Context) error {
+	c, err := p.finish()
+	if err != nil {
+		return err
+	}
+	defer c.life.untrack(p.msg)
+
+	return c.changeVisibilityContext(ctx, p.msg.ReceiptHandle, 0)
+}
+
+// finish stops extending the visibility of the message, it can only be called once
+func (p *PendingMessage) finish() (*consumer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.expired {
+		return nil, ErrAckExpired
+	}
+
+	if p.finished {
+		return nil, ErrAckFinished
+	}
+
+	p.finished = true
+	close(p.stop)
+	return p.consumer, nil
+}
+
+// expire gives up on a message that was neither acked nor nacked in time
+func (p *PendingMessage) expire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished || p.expired {
+		return false
+	}
+
+	p.expired = true
+	return true
+}
+
+// hold extends the visibility of a deferred message until it is acked, nacked or the max hold duration has passed. The
+// message stays tracked meanwhile, so Shutdown drains it or hands it off, and it is released once Shutdown returned
+func (c *consumer) hold(p *PendingMessage) {
+	maxHold := c.manualAckMaxHold
+	if maxHold <= 0 {
+		maxHold = defaultManualAckMaxHold
+	}
+
+	deadline := time.NewTimer(maxHold)
+	defer deadline.Stop()
+
+	// extend halfway through the visibility timeout so the message never becomes visible while it is pending
+	visibility := c.visibilityOf(p.msg)
+	interval := time.Duration(visibility) * time.Second / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-deadline.C:
+			c.release(p)
+			return
+		case <-c.life.ended():
+			c.release(p)
+			return
+		case <-ticker.C:
+			if err := c.changeVisibility(p.msg.ReceiptHandle, int64(visibility)); err != nil {
+				c.logMessage(p.msg, err.Error(), p.msg.Route())
+			}
+		}
+	}
+}
+
+// release gives up on a deferred message that was neither acked nor nacked, it is redelivered once its visibility
+// timeout expires. It reports false when the message was already finished or released
+func (c *consumer) release(p *PendingMessage) bool {
+	if !p.expire() {
+		return false
+	}
+
+	c.life.untrack(p.msg)
+	c.logMessage(p.msg, ErrAckExpired.Error(), stringValue(p.msg.MessageId), p.msg.Route())
+	return true
+}
@@ -0,0 +1,182 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDefer(t *testing.T) {
+	newConsumer := func(m *mockSQS, pending chan *PendingMessage, handlerErr error) *consumer {
+		return &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+			manualAckMaxHold: 50 * time.Millisecond,
+			handlers: map[string]Handler{
+				"post_published": func(ctx context.Context, msg Message) error {
+					p, err := Defer(msg)
+					if err != nil {
+						return err
+					}
+					pending <- p
+					return handlerErr
+				},
+			}}
+	}
+
+	t.Run("ack", func(t *testing.T) {
+		m := newMockSQS()
+		pending := make(chan *PendingMessage, 1)
+		c := newConsumer(m, pending, nil)
+
+		msg := m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if len(m.deleted) != 0 {
+			t.Fatalf("expected the deferred message to be kept, got %v", m.deleted)
+		}
+
+		p := <-pending
+		if err := p.Ack(context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(m.deleted) != 1 || m.deleted[0] != *msg.ReceiptHandle {
+			t.Errorf("expected Ack to delete the message, got %v", m.deleted)
+		}
+
+		if err := p.Nack(context.TODO()); err != ErrAckFinished {
+			t.Errorf("expected %v, got %v", ErrAckFinished, err)
+		}
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		m := newMockSQS()
+		pending := make(chan *PendingMessage, 1)
+		c := newConsumer(m, pending, nil)
+
+		msg := m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if err := (<-pending).Nack(context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if v, ok := m.visibilities[*msg.ReceiptHandle]; !ok || v != 0 {
+			t.Errorf("expected Nack to make the message visible, got %d", v)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		m := newMockSQS()
+		pending := make(chan *PendingMessage, 1)
+		c := newConsumer(m, pending, nil)
+
+		m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+		p := <-pending
+
+		time.Sleep(100 * time.Millisecond)
+		if err := p.Ack(context.TODO()); err != ErrAckExpired {
+			t.Errorf("expected %v, got %v", ErrAckExpired, err)
+		}
+
+		if len(m.deleted) != 0 {
+			t.Errorf("expected the expired message to be redelivered, got %v", m.deleted)
+		}
+	})
+
+	t.Run("ack_context", func(t *testing.T) {
+		m := newMockSQS()
+		pending := make(chan *PendingMessage, 1)
+		c := newConsumer(m, pending, nil)
+
+		m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := (<-pending).Ack(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the cancelled context to abort the delete, got %v", err)
+		}
+
+		if len(m.deleted) != 0 {
+			t.Errorf("expected the message not to be deleted, got %v", m.deleted)
+		}
+	})
+
+	t.Run("shutdown_drain", func(t *testing.T) {
+		m := newMockSQS()
+		pending := make(chan *PendingMessage, 1)
+		c := newConsumer(m, pending, nil)
+		c.manualAckMaxHold = time.Minute
+
+		msg := m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+		p := <-pending
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := c.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the shutdown to wait for the deferred message, got %v", err)
+		}
+
+		if err := p.Ack(context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := c.Shutdown(context.Background()); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if len(m.deleted) != 1 || m.deleted[0] != *msg.ReceiptHandle {
+			t.Errorf("expected the drained message to be deleted, got %v", m.deleted)
+		}
+	})
+
+	t.Run("shutdown_handoff", func(t *testing.T) {
+		m := newMockSQS()
+		pending := make(chan *PendingMessage, 1)
+		c := newConsumer(m, pending, nil)
+		c.manualAckMaxHold = time.Minute
+		c.shutdownMode = ShutdownFastHandoff
+
+		msg := m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+		p := <-pending
+
+		if err := c.Shutdown(context.Background()); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if v, ok := m.visibilities[*msg.ReceiptHandle]; !ok || v != 0 {
+			t.Errorf("expected the deferred message to be made visible, got %v", m.visibilities)
+		}
+
+		if err := p.Ack(context.TODO()); err != ErrAckExpired {
+			t.Errorf("expected %v, got %v", ErrAckExpired, err)
+		}
+
+		if len(m.deleted) != 0 {
+			t.Errorf("expected the handed off message not to be deleted, got %v", m.deleted)
+		}
+	})
+
+	t.Run("handler_error", func(t *testing.T) {
+		m := newMockSQS()
+		pending := make(chan *PendingMessage, 1)
+		c := newConsumer(m, pending, errors.New("failed"))
+
+		m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if err := (<-pending).Ack(context.TODO()); err != ErrAckFinished {
+			t.Errorf("expected %v, got %v", ErrAckFinished, err)
+		}
+	})
+
+	t.Run("not_deferrable", func(t *testing.T) {
+		if _, err := Defer(newMessage(newMockSQS().add("queue", "post_published", "{}"))); err != ErrNotDeferrable {
+			t.Errorf("expected %v, got %v", ErrNotDeferrable, err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package gosqs
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// receivedBatch withholds the deletes of the messages received together until every one of them has been handled
+type receivedBatch struct {
+	mu        sync.Mutex
+	remaining int
+	failed    bool
+	messages  []*message
+}
+
+// newReceivedBatch groups the messages and attaches the batch to each of them
+func newReceivedBatch(messages []*message) *receivedBatch {
+	b := &receivedBatch{remaining: len(messages), messages: messages}
+	for _, m := range messages {
+		m.batch = b
+	}
+
+	return b
+}
+
+// done records the outcome of a message, it reports true once the last message of a fully successful batch is done
+func (b *receivedBatch) done(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil {
+		b.failed = true
+	}
+
+	b.remaining--
+	return b.remaining == 0 && !b.failed
+}
+
+// complete records the outcome of a message of an atomic batch and deletes the whole batch once every message succeeded.
+// If any message failed nothing is deleted and the batch is redelivered once the visibility timeout passes
+func (c *consumer) complete(m *message, err error) error {
+	if !m.batch.done(err) {
+		return nil
+	}
+
+	if c.externalAck != nil {
+		for _, msg := range m.batch.messages {
+			c.ackExternally(msg)
+		}
+		return nil
+	}
+
+	entries := make([]*sqs.DeleteMessageBatchRequestEntry, len(m.batch.messages))
+	for i, msg := range m.batch.messages {
+		entries[i] = &sqs.DeleteMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: msg.ReceiptHandle}
+	}
+
+	var failed bool
+	for attempt := 0; len(entries) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(batchRetryDelay(attempt - 1))
+		}
+
+		out, err := c.sqs.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{QueueUrl: &c.QueueURL, Entries: entries})
+		if err != nil {
+			err = ErrUnableToDelete.Context(err)
+			for _, e := range entries {
+				c.deleteFailed(m.batch.message(*e.Id), DeleteFailure{Err: err})
+			}
+			return err
+		}
+
+		// entries that failed on the sender's side, or that are out of retries, are reported and left for redelivery
+		var retry []*sqs.DeleteMessageBatchRequestEntry
+		for _, f := range out.Failed {
+			if e := entryByID(entries, *f.Id); e != nil && !aws.BoolValue(f.SenderFault) && attempt < deleteBatchRetries {
+				retry = append(retry, e)
+				continue
+			}
+
+			failed = true
+			c.deleteFailed(m.batch.message(*f.Id), DeleteFailure{
+				Err:          batchEntryErr(aws.StringValue(f.Code), aws.StringValue(f.Message)),
+				PartialBatch: true,
+				Code:         aws.StringValue(f.Code),
+			})
+		}
+		entries = retry
+	}
+
+	if failed {
+		return ErrUnableToDelete
+	}
+
+	return nil
+}
+
+// message returns the message of a batch delete entry
+func (b *receivedBatch) message(id string) *message {
+	i, _ := strconv.Atoi(id)
+	return b.messages[i]
+}
+
+// entryByID returns the entry of a batch delete with the id
+func entryByID(entries []*sqs.DeleteMessageBatchRequestEntry, id string) *sqs.DeleteMessageBatchRequestEntry {
+	for _, e := range entries {
+		if *e.Id == id {
+			return e
+		}
+	}
+	return nil
+}
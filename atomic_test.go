@@ -0,0 +1,60 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAtomicBatches(t *testing.T) {
+	setup := func(fail bool) (*mockSQS, *consumer) {
+		m := newMockSQS()
+		c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, atomicBatches: true,
+			handlers: map[string]Handler{
+				"ok_event": func(ctx context.Context, msg Message) error { return nil },
+				"maybe_event": func(ctx context.Context, msg Message) error {
+					if fail {
+						return errors.New("failed")
+					}
+					return nil
+				},
+			}}
+
+		for _, route := range []string{"ok_event", "maybe_event", "unhandled_event"} {
+			m.add("queue", route, "{}")
+		}
+		return m, c
+	}
+
+	t.Run("all_succeed", func(t *testing.T) {
+		m, c := setup(false)
+		var deletedEarly int
+		c.poll(func(msg *message) {
+			c.run(msg)
+			if msg.batch.remaining > 0 {
+				deletedEarly += len(m.deleted)
+			}
+		})
+
+		if deletedEarly != 0 {
+			t.Errorf("expected no deletes before the batch completed, got %d", deletedEarly)
+		}
+
+		if len(m.deleted) != 3 {
+			t.Errorf("expected the whole batch to be deleted, got %v", m.deleted)
+		}
+	})
+
+	t.Run("one_fails", func(t *testing.T) {
+		m, c := setup(true)
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if len(m.deleted) != 0 {
+			t.Errorf("expected nothing to be deleted, got %v", m.deleted)
+		}
+
+		if len(m.inflight) != 3 {
+			t.Errorf("expected the batch to remain in flight for redelivery, got %d", len(m.inflight))
+		}
+	})
+}
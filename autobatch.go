@@ -0,0 +1,150 @@
+package gosqs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// defaultAutoBatchInterval is used when AutoBatchSize is configured without an interval
+const defaultAutoBatchInterval = 100 * time.Millisecond
+
+// encodedBody is a body that was marshaled before it was buffered, so changes the caller makes to the value afterwards
+// are not sent
+type encodedBody []byte
+
+// batchKey identifies a buffer, messages of different queues or events are never sent in the same batch
+type batchKey struct {
+	queue string
+	event string
+}
+
+// autoBatcher buffers the messages sent with Message and sends them with PublishBatch once a buffer holds size messages
+// or its oldest message has waited for interval. A nil autoBatcher is disabled
+type autoBatcher struct {
+	mu sync.Mutex
+
+	size     int
+	interval time.Duration
+	buffers  map[batchKey][]BatchEntry
+	timers   map[batchKey]*time.Timer
+
+	send func(key batchKey, entries []BatchEntry)
+}
+
+// newAutoBatcher creates an auto batcher from the config, it returns nil if auto batching is disabled
+func newAutoBatcher(c Config, send func(key batchKey, entries []BatchEntry)) *autoBatcher {
+	if c.AutoBatchSize <= 1 {
+		return nil
+	}
+
+	b := &autoBatcher{
+		size:     c.AutoBatchSize,
+		interval: c.AutoBatchInterval,
+		buffers:  map[batchKey][]BatchEntry{},
+		timers:   map[batchKey]*time.Timer{},
+		send:     send,
+	}
+
+	if b.interval <= 0 {
+		b.interval = defaultAutoBatchInterval
+	}
+
+	return b
+}
+
+// add buffers the marshaled message, sending the buffer right away once it is full
+func (b *autoBatcher) add(queue, event string, body []byte) {
+	key := batchKey{queue, event}
+
+	b.mu.Lock()
+	b.buffers[key] = append(b.buffers[key], BatchEntry{Event: event, Body: encodedBody(body)})
+	if len(b.buffers[key]) < b.size {
+		if _, ok := b.timers[key]; !ok {
+			b.timers[key] = time.AfterFunc(b.interval, func() { b.flush(key) })
+		}
+		b.mu.Unlock()
+		return
+	}
+
+	entries := b.take(key)
+	b.mu.Unlock()
+
+	b.send(key, entries)
+}
+
+// flush sends the buffered messages of a single queue and event
+func (b *autoBatcher) flush(key batchKey) {
+	b.mu.Lock()
+	entries := b.take(key)
+	b.mu.Unlock()
+
+	if len(entries) > 0 {
+		b.send(key, entries)
+	}
+}
+
+// flushAll sends every buffered message regardless of the thresholds
+func (b *autoBatcher) flushAll() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	keys := make([]batchKey, 0, len(b.buffers))
+	for key := range b.buffers {
+		keys = append(keys, key)
+	}
+	b.mu.Unlock()
+
+	for _, key := range keys {
+		b.flush(key)
+	}
+}
+
+// take removes the buffer and its timer, the lock must be held
+func (b *autoBatcher) take(key batchKey) []BatchEntry {
+	if t, ok := b.timers[key]; ok {
+		t.Stop()
+		delete(b.timers, key)
+	}
+
+	entries := b.buffers[key]
+	delete(b.buffers, key)
+	return entries
+}
+
+// sendAutoBatch sends a buffer in the background so that Flush waits for it and reports a failure per entry
+func (p *publisher) sendAutoBatch(key batchKey, entries []BatchEntry) {
+	p.asyncEntries(key.event, func() []PublishFailure { return p.publishAutoBatch(key.queue, entries) })
+}
+
+// publishAutoBatch sends the entries and retries the failed ones like sendDirectMessage, entries that failed because of
+// the request itself are not retried as they would fail again
+func (p *publisher) publishAutoBatch(queue string, entries []BatchEntry) []PublishFailure {
+	var failures []PublishFailure
+	for retryCount := 0; ; retryCount++ {
+		results, err := p.PublishBatch(context.Background(), queue, entries)
+		if err == nil {
+			return failures
+		}
+
+		var retry []BatchEntry
+		for _, i := range results.Failed() {
+			if results[i].SenderFault || retryCount >= maxRetryCount {
+				failures = append(failures, PublishFailure{Event: entries[i].Event, Err: results[i].Err})
+				continue
+			}
+			retry = append(retry, entries[i])
+		}
+
+		if len(retry) == 0 {
+			return failures
+		}
+
+		log.Println(err, " retrying in 10s")
+		time.Sleep(publishRetryDelay)
+		entries = retry
+	}
+}
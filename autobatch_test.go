@@ -0,0 +1,176 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestAutoBatch(t *testing.T) {
+	newPublisher := func(m *mockSQS, c Config) *publisher {
+		p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/", logger: &testLogger{}}
+		p.batcher = newAutoBatcher(c, p.sendAutoBatch)
+		return p
+	}
+
+	t.Run("sends_full_batches", func(t *testing.T) {
+		m := newMockSQS()
+		p := newPublisher(m, Config{AutoBatchSize: 3, AutoBatchInterval: time.Hour})
+
+		for i := 0; i < 3; i++ {
+			p.Message("post-worker", "post_published", &sample{})
+		}
+
+		if err := p.inflight.wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := len(m.queues["http://localhost:4100/dev-post-worker"]); got != 3 {
+			t.Fatalf("expected 3 messages to be sent, got %d", got)
+		}
+	})
+
+	t.Run("does_not_mix_events", func(t *testing.T) {
+		m := newMockSQS()
+		p := newPublisher(m, Config{AutoBatchSize: 2, AutoBatchInterval: time.Hour})
+
+		p.Message("post-worker", "post_published", &sample{})
+		p.Message("post-worker", "post_deleted", &sample{})
+
+		if err := p.inflight.wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(m.sent) != 0 {
+			t.Fatalf("expected messages to remain buffered, got %d sent", len(m.sent))
+		}
+
+		if err := p.Flush(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(m.sent) != 2 {
+			t.Fatalf("expected Flush to send the buffered messages, got %d", len(m.sent))
+		}
+	})
+
+	t.Run("sends_after_interval", func(t *testing.T) {
+		m := newMockSQS()
+		p := newPublisher(m, Config{AutoBatchSize: 10, AutoBatchInterval: 10 * time.Millisecond})
+
+		p.Message("post-worker", "post_published", &sample{})
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			m.mu.Lock()
+			sent := len(m.sent)
+			m.mu.Unlock()
+			if sent == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expected the message to be sent once the interval passed")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		m := newMockSQS()
+		p := newPublisher(m, Config{AutoBatchSize: 10, AutoBatchInterval: time.Hour})
+
+		p.Message("post-worker", "post_published", &sample{})
+		if err := p.Close(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		p.Message("post-worker", "post_published", &sample{})
+		if err := p.Flush(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(m.sent) != 1 {
+			t.Fatalf("expected only the message sent before Close, got %d", len(m.sent))
+		}
+	})
+
+	t.Run("marshals_when_buffered", func(t *testing.T) {
+		m := newMockSQS()
+		p := newPublisher(m, Config{AutoBatchSize: 10, AutoBatchInterval: time.Hour})
+
+		body := &sample{Val: "sent"}
+		p.Message("post-worker", "post_published", body)
+		body.Val = "changed"
+
+		if err := p.Flush(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(m.sent) != 1 || *m.sent[0].MessageBody != `{"val":"sent"}` {
+			t.Fatalf("expected the body as it was when Message returned, got %v", m.sent)
+		}
+	})
+
+	t.Run("retries_failed_entries", func(t *testing.T) {
+		publishRetryDelay = 0
+		defer func() { publishRetryDelay = 10 * time.Second }()
+
+		m := &flakyBatchSQS{mockSQS: newMockSQS(), errs: 2}
+		p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/", logger: &testLogger{}}
+		p.batcher = newAutoBatcher(Config{AutoBatchSize: 2, AutoBatchInterval: time.Hour}, p.sendAutoBatch)
+
+		p.Message("post-worker", "post_published", &sample{})
+		p.Message("post-worker", "post_published", &sample{})
+		if err := p.Flush(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(m.sent) != 2 {
+			t.Fatalf("expected the batch to be sent once the errors cleared, got %d sent", len(m.sent))
+		}
+	})
+
+	t.Run("reports_every_failed_entry", func(t *testing.T) {
+		m := newMockSQS()
+		m.failBatch = map[string]bool{"0": true, "1": true}
+		p := newPublisher(m, Config{AutoBatchSize: 3, AutoBatchInterval: time.Hour})
+
+		for i := 0; i < 3; i++ {
+			p.Message("post-worker", "post_published", &sample{})
+		}
+
+		var ferr *FlushError
+		if err := p.Flush(context.Background()); !errors.As(err, &ferr) || len(ferr.Failures) != 2 {
+			t.Fatalf("expected a failure per rejected entry, got %v", err)
+		}
+
+		if len(m.sent) != 1 {
+			t.Errorf("expected entries rejected because of the request not to be retried, got %d sent", len(m.sent))
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if b := newAutoBatcher(Config{}, nil); b != nil {
+			t.Fatal("expected auto batching to be disabled")
+		}
+	})
+}
+
+// flakyBatchSQS fails the first errs SendMessageBatch calls
+type flakyBatchSQS struct {
+	*mockSQS
+	errs int
+}
+
+func (m *flakyBatchSQS) SendMessageBatchWithContext(ctx aws.Context, in *sqs.SendMessageBatchInput, opts ...request.Option) (*sqs.SendMessageBatchOutput, error) {
+	if m.errs > 0 {
+		m.errs--
+		return nil, errors.New("service unavailable")
+	}
+	return m.mockSQS.SendMessageBatchWithContext(ctx, in, opts...)
+}
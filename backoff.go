@@ -0,0 +1,55 @@
+package gosqs
+
+import "time"
+
+// defaultRetryBackoffMax is used when RetryBackoffBase is configured without a maximum
+const defaultRetryBackoffMax = 15 * time.Minute
+
+// retryBackoff derives the redelivery delay of a failed message from its receive count. A nil retryBackoff is disabled
+type retryBackoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+// newRetryBackoff creates a retry backoff from the config, it returns nil if the backoff is disabled
+func newRetryBackoff(c Config) *retryBackoff {
+	if c.RetryBackoffBase <= 0 {
+		return nil
+	}
+
+	b := &retryBackoff{base: c.RetryBackoffBase, max: c.RetryBackoffMax}
+	if b.max <= 0 {
+		b.max = defaultRetryBackoffMax
+	}
+
+	if limit := maxVisibilityTimeout * time.Second; b.max > limit {
+		b.max = limit
+	}
+
+	return b
+}
+
+// delay returns base * 2^(receives-1) capped at the maximum
+func (b *retryBackoff) delay(receives int) time.Duration {
+	d := b.base
+	for i := 1; i < receives && d < b.max; i++ {
+		d *= 2
+	}
+
+	if d > b.max {
+		return b.max
+	}
+	return d
+}
+
+// backoff changes the visibility of a failed message so it is redelivered after the backoff of its receive count
+func (c *consumer) backoff(m *message) {
+	if c.retryBackoff == nil {
+		return
+	}
+
+	seconds := int64(c.retryBackoff.delay(m.receiveCount()).Round(time.Second) / time.Second)
+	if err := c.changeVisibility(m.ReceiptHandle, seconds); err != nil {
+		c.logMessage(m, err.Error(), m.Route())
+	}
+}
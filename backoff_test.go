@@ -0,0 +1,52 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	b := newRetryBackoff(Config{RetryBackoffBase: 10 * time.Second, RetryBackoffMax: time.Minute})
+	for receives, want := range map[int]time.Duration{0: 10 * time.Second, 1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 50: time.Minute} {
+		if got := b.delay(receives); got != want {
+			t.Errorf("expected a delay of %s after %d receives, got %s", want, receives, got)
+		}
+	}
+
+	if b := newRetryBackoff(Config{RetryBackoffBase: time.Second}); b.max != defaultRetryBackoffMax {
+		t.Errorf("expected the default maximum, got %s", b.max)
+	}
+
+	if b := newRetryBackoff(Config{}); b != nil {
+		t.Error("expected the backoff to be disabled")
+	}
+}
+
+func TestRunBackoff(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		retryBackoff: newRetryBackoff(Config{RetryBackoffBase: 5 * time.Second}),
+		handlers: map[string]Handler{
+			"failed":       func(ctx context.Context, msg Message) error { return errors.New("failed") },
+			"rate_limited": func(ctx context.Context, msg Message) error { return RetryAfter(time.Second) },
+		}}
+
+	failed := m.add("queue", "failed", "{}")
+	failed.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("3")
+	limited := m.add("queue", "rate_limited", "{}")
+	limited.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("3")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if v := m.visibilities[*failed.ReceiptHandle]; v != 20 {
+		t.Errorf("expected the failed message to back off for 20s, got %d", v)
+	}
+
+	if v := m.visibilities[*limited.ReceiptHandle]; v != 1 {
+		t.Errorf("expected RetryAfter to take precedence, got %d", v)
+	}
+}
@@ -0,0 +1,180 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// maxBatchSize is the maximum number of entries SQS accepts in a single batch request
+const maxBatchSize = 10
+
+// BatchEntry is a single message sent with PublishBatch
+type BatchEntry struct {
+	Event   string
+	Body    interface{}
+	Options []PublishOption
+}
+
+// BatchEntryResult is the outcome of a single BatchEntry
+type BatchEntryResult struct {
+	// MessageID is set when the entry was sent successfully
+	MessageID string
+	// Err is set when the entry could not be sent
+	Err error
+	// Code is the SQS failure code of the entry, e.g. InvalidParameterValue
+	Code string
+	// SenderFault is true if the entry failed because of the request itself, retrying it unchanged will fail again
+	SenderFault bool
+}
+
+// BatchResult holds the result of every entry at the index of the entry in the PublishBatch call
+type BatchResult []BatchEntryResult
+
+// Failed returns the indexes of the entries that were not sent
+func (r BatchResult) Failed() []int {
+	var failed []int
+	for i, res := range r {
+		if res.Err != nil {
+			failed = append(failed, i)
+		}
+	}
+
+	return failed
+}
+
+// PublishBatch sends direct messages to an individual queue using SendMessageBatch, splitting the entries into batches of 10
+// that fit into the 262144 byte limit.
+// The result reports a message ID or an error for every entry so that only failed entries need to be retried. The returned
+// error is nil if every entry was sent, otherwise it is ErrBatchPublish
+func (p *publisher) PublishBatch(ctx context.Context, queue string, entries []BatchEntry) (BatchResult, error) {
+	results := make(BatchResult, len(entries))
+	u := p.sqsURL + fmt.Sprintf("%s-%s", p.env, queue)
+
+	var batch []*sqs.SendMessageBatchRequestEntry
+	var batchSize int
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		p.sendBatch(ctx, u, batch, results)
+		batch, batchSize = nil, 0
+	}
+
+	for i, e := range entries {
+		entry, err := p.batchEntry(ctx, queue, strconv.Itoa(i), e)
+		if err != nil {
+			results[i] = BatchEntryResult{Err: err, SenderFault: true}
+			continue
+		}
+
+		// the whole batch must also fit into the size limit
+		size := sqsMessageSize(entry.MessageBody, entry.MessageAttributes)
+		if batchSize+size > maxBodySize {
+			flush()
+		}
+
+		batch = append(batch, entry)
+		batchSize += size
+		if len(batch) == maxBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	if failed := results.Failed(); len(failed) > 0 {
+		return results, ErrBatchPublish.Context(fmt.Errorf("%d of %d entries failed", len(failed), len(entries)))
+	}
+
+	return results, nil
+}
+
+// batchEntry builds the request entry, the index of the entry is used as its batch id
+func (p *publisher) batchEntry(ctx context.Context, queue, id string, e BatchEntry) (*sqs.SendMessageBatchRequestEntry, error) {
+	o, err := newPublishOptions(e.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.applyBody(e.Body); err != nil {
+		return nil, err
+	}
+
+	if err := o.validateFIFO(isFIFO(queue)); err != nil {
+		return nil, err
+	}
+
+	b, ok := e.Body.(encodedBody)
+	if !ok {
+		if b, err = marshalBody(p.codec, e.Body); err != nil {
+			return nil, ErrMarshal.Context(err)
+		}
+	}
+
+	out := string(b)
+	entry := &sqs.SendMessageBatchRequestEntry{
+		Id:                      &id,
+		MessageBody:             &out,
+		MessageAttributes:       defaultSQSAttributes(e.Event, p.attributesFor(queue, o.callAttributes(p.correlationAttribute)...)...),
+		MessageSystemAttributes: o.systemAttributes(),
+	}
+
+	if o.groupID != "" {
+		entry.MessageGroupId = &o.groupID
+	}
+
+	if o.dedupID != "" {
+		entry.MessageDeduplicationId = &o.dedupID
+	}
+
+	if entry.MessageBody, err = p.overflowSQS(entry.MessageBody, entry.MessageAttributes); err != nil {
+		return nil, err
+	}
+
+	if entry.MessageBody, err = p.shrinkSQS(ctx, entry.MessageBody, entry.MessageAttributes); err != nil {
+		return nil, err
+	}
+
+	if err := checkSize(sqsMessageSize(entry.MessageBody, entry.MessageAttributes)); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// sendBatch sends a single batch and records the outcome of each entry in results
+func (p *publisher) sendBatch(ctx context.Context, queueURL string, batch []*sqs.SendMessageBatchRequestEntry, results BatchResult) {
+	resp, err := p.sqs.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{QueueUrl: &queueURL, Entries: batch})
+	if err != nil {
+		for _, entry := range batch {
+			i, _ := strconv.Atoi(*entry.Id)
+			results[i] = BatchEntryResult{Err: ErrPublish.Context(err)}
+		}
+		return
+	}
+
+	for _, s := range resp.Successful {
+		i, _ := strconv.Atoi(*s.Id)
+		results[i] = BatchEntryResult{MessageID: *s.MessageId}
+	}
+
+	for _, f := range resp.Failed {
+		i, _ := strconv.Atoi(*f.Id)
+		res := BatchEntryResult{Err: ErrPublish.Context(fmt.Errorf("%s: %s", *f.Code, stringValue(f.Message))), Code: *f.Code}
+		if f.SenderFault != nil {
+			res.SenderFault = *f.SenderFault
+		}
+		results[i] = res
+	}
+}
+
+// stringValue dereferences an optional string
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
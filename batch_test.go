@@ -0,0 +1,40 @@
+package gosqs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPublishBatch(t *testing.T) {
+	m := newMockSQS()
+	m.failBatch = map[string]bool{"3": true}
+	p := &publisher{sqs: m, env: "dev"}
+
+	entries := make([]BatchEntry, 12)
+	for i := range entries {
+		entries[i] = BatchEntry{Event: "some_event", Body: &sample{}}
+	}
+	entries[5].Body = func() {}
+
+	results, err := p.PublishBatch(context.TODO(), "post-worker", entries)
+	if err == nil {
+		t.Fatal("expected an error for the failed entries")
+	}
+
+	if failed := results.Failed(); !reflect.DeepEqual(failed, []int{3, 5}) {
+		t.Fatalf("unexpected failed entries, expected [3 5], got %v", failed)
+	}
+
+	if results[3].Code != "InvalidParameterValue" || !results[3].SenderFault {
+		t.Errorf("expected the sqs failure details, got %+v", results[3])
+	}
+
+	if results[11].MessageID == "" {
+		t.Errorf("expected a message id for entries in the second batch, got %+v", results[11])
+	}
+
+	if len(m.queues["dev-post-worker"]) != 10 {
+		t.Errorf("expected 10 sent messages, got %d", len(m.queues["dev-post-worker"]))
+	}
+}
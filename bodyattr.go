@@ -0,0 +1,137 @@
+package gosqs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// bodyAttribute is an attribute whose value is extracted from the body of the message when it is sent
+type bodyAttribute struct {
+	title    string
+	path     string
+	dataType DataType
+}
+
+// WithAttributeFromBody adds a custom attribute whose value is read from the body at the JSON path, e.g. "$.order.customerId"
+// or "items[0].sku", so filter keys that live inside the payload do not have to be extracted at every call site. Strings are
+// sent as is and numbers and booleans in their JSON form, a Number datatype requires a number. The body is encoded as JSON
+// for the lookup regardless of the Codec, a missing value or a value of another type fails the call with ErrBodyAttribute
+func WithAttributeFromBody(title, jsonPath string, dataType DataType) PublishOption {
+	return func(o *publishOptions) {
+		o.bodyAttributes = append(o.bodyAttributes, bodyAttribute{title: title, path: jsonPath, dataType: dataType})
+	}
+}
+
+// WithMessageGroupIDFromBody sets the MessageGroupId of a message sent to a FIFO queue or topic to the value at the JSON path
+// of the body, see WithAttributeFromBody. WithMessageGroupID takes precedence
+func WithMessageGroupIDFromBody(jsonPath string) PublishOption {
+	return func(o *publishOptions) {
+		o.groupIDPath = jsonPath
+	}
+}
+
+// applyBody sets the attributes and group ID that are extracted from the body
+func (o *publishOptions) applyBody(body interface{}) error {
+	if len(o.bodyAttributes) == 0 && o.groupIDPath == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return ErrMarshal.Context(err)
+	}
+
+	return o.applyJSONBody(b)
+}
+
+// applyJSONBody is applyBody for a body that is already encoded as JSON
+func (o *publishOptions) applyJSONBody(b []byte) error {
+	if len(o.bodyAttributes) == 0 && o.groupIDPath == "" {
+		return nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return ErrBodyAttribute.Context(err)
+	}
+
+	for _, attr := range o.bodyAttributes {
+		v, err := lookupPath(doc, attr.path)
+		if err != nil {
+			return ErrBodyAttribute.Context(err)
+		}
+
+		value, err := bodyValue(v, attr.dataType)
+		if err != nil {
+			return ErrBodyAttribute.Context(fmt.Errorf("%s: %v", attr.path, err))
+		}
+
+		o.attributes = append(o.attributes, customAttribute{attr.title, attr.dataType.String(), value})
+	}
+
+	if o.groupIDPath != "" && o.groupID == "" {
+		v, err := lookupPath(doc, o.groupIDPath)
+		if err != nil {
+			return ErrBodyAttribute.Context(err)
+		}
+
+		if o.groupID, err = bodyValue(v, DataTypeString); err != nil {
+			return ErrBodyAttribute.Context(fmt.Errorf("%s: %v", o.groupIDPath, err))
+		}
+	}
+
+	return nil
+}
+
+// lookupPath returns the value at a dot separated path with optional array indexes, a leading "$." is ignored
+func lookupPath(doc interface{}, path string) (interface{}, error) {
+	p := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	p = strings.NewReplacer("[", ".", "]", "").Replace(p)
+
+	v := doc
+	for _, key := range strings.Split(p, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("%s: field %q not found", path, key)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("%s: index %q out of range", path, key)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("%s: %q is not an object or array", path, key)
+		}
+	}
+
+	return v, nil
+}
+
+// bodyValue formats a JSON value as an attribute value of the datatype
+func bodyValue(v interface{}, dataType DataType) (string, error) {
+	switch val := v.(type) {
+	case json.Number:
+		return val.String(), nil
+	case string:
+		if dataType.isNumber() {
+			return "", fmt.Errorf("expected a number for %s, got a string", dataType)
+		}
+		return val, nil
+	case bool:
+		if dataType.isNumber() {
+			return "", fmt.Errorf("expected a number for %s, got a boolean", dataType)
+		}
+		return strconv.FormatBool(val), nil
+	default:
+		return "", fmt.Errorf("expected a string, number or boolean, got %T", v)
+	}
+}
@@ -0,0 +1,79 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithAttributeFromBody(t *testing.T) {
+	type item struct {
+		SKU string `json:"sku"`
+	}
+	type order struct {
+		Customer struct {
+			ID     string `json:"id"`
+			Region string `json:"region"`
+		} `json:"customer"`
+		Total int    `json:"total"`
+		Items []item `json:"items"`
+	}
+
+	body := order{Total: 42, Items: []item{{SKU: "a-1"}}}
+	body.Customer.ID = "c-7"
+	body.Customer.Region = "eu"
+
+	m := &mockSNS{}
+	p := &publisher{sns: m, arn: "arn:aws:sns:local:000000000000:orders.fifo"}
+	_, err := p.Publish(context.TODO(), "order_created", body,
+		WithAttributeFromBody("region", "$.customer.region", DataTypeString),
+		WithAttributeFromBody("total", "total", DataTypeNumber),
+		WithAttributeFromBody("sku", "items[0].sku", DataTypeString),
+		WithMessageGroupIDFromBody("$.customer.id"),
+		WithContentDedup(),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	in := m.published[0]
+	for k, v := range map[string]string{"region": "eu", "total": "42", "sku": "a-1"} {
+		if attr, ok := in.MessageAttributes[k]; !ok || *attr.StringValue != v {
+			t.Errorf("expected %s to be %s, got %v", k, v, attr)
+		}
+	}
+
+	if *in.MessageAttributes["total"].DataType != "Number" || *in.MessageGroupId != "c-7" {
+		t.Errorf("unexpected datatype or group id, got %v", in)
+	}
+
+	for _, path := range []string{"customer.missing", "items[3].sku", "customer.region"} {
+		_, err := p.Publish(context.TODO(), "order_created", body, WithAttributeFromBody("x", path, DataTypeNumber), WithContentDedup())
+		if !errors.Is(err, ErrBodyAttribute) {
+			t.Errorf("expected ErrBodyAttribute for %s, got %v", path, err)
+		}
+	}
+}
+
+func TestWithAttributeFromBodyReader(t *testing.T) {
+	mq := newMockSQS()
+	ms := &mockSNS{}
+	p := &publisher{sqs: mq, sns: ms, env: "dev", sqsURL: "http://localhost:4100/", arn: "arn:aws:sns:local:000000000000:orders"}
+
+	if _, err := p.PublishReader(context.TODO(), "order_created", strings.NewReader(`{"tenant":"acme"}`), WithAttributeFromBody("tenant", "tenant", DataTypeString)); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if got := *ms.published[0].MessageAttributes["tenant"].StringValue; got != "acme" {
+		t.Errorf("unexpected attribute, got %s", got)
+	}
+
+	res, err := p.PublishBatch(context.TODO(), "post-worker", []BatchEntry{
+		{Event: "order_created", Body: map[string]string{"tenant": "acme"}, Options: []PublishOption{WithAttributeFromBody("tenant", "tenant", DataTypeString)}},
+		{Event: "order_created", Body: map[string]string{}, Options: []PublishOption{WithAttributeFromBody("tenant", "tenant", DataTypeString)}},
+	})
+	if err == nil || len(res.Failed()) != 1 || res.Failed()[0] != 1 || !errors.Is(res[1].Err, ErrBodyAttribute) {
+		t.Errorf("expected only the entry without tenant to fail, got %v", res)
+	}
+}
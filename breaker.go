@@ -0,0 +1,190 @@
+package gosqs
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState represents the state of the consumer's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed messages are received and dispatched as usual
+	CircuitClosed CircuitState = iota
+	// CircuitOpen receiving is paused, messages remain in the queue until the cooldown has passed
+	CircuitOpen
+	// CircuitHalfOpen a single probe message is processed to decide whether the circuit closes or opens again
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// defaultCircuitCooldown is used when a circuit breaker threshold is configured without a cooldown
+const defaultCircuitCooldown = 30 * time.Second
+
+// probeInterval is how often the consumer checks on an in flight probe message
+const probeInterval = 100 * time.Millisecond
+
+// circuitBreaker tracks consecutive handler failures. A nil circuitBreaker is always closed
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	onChange  func(from, to CircuitState)
+	now       func() time.Time
+
+	state        CircuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+}
+
+// newCircuitBreaker creates a circuit breaker from the config, it returns nil if the breaker is disabled
+func newCircuitBreaker(c Config) *circuitBreaker {
+	if c.CircuitBreakerThreshold <= 0 {
+		return nil
+	}
+
+	b := &circuitBreaker{
+		threshold: c.CircuitBreakerThreshold,
+		window:    c.CircuitBreakerWindow,
+		cooldown:  c.CircuitBreakerCooldown,
+		onChange:  c.OnCircuitStateChange,
+		now:       time.Now,
+	}
+
+	if b.cooldown <= 0 {
+		b.cooldown = defaultCircuitCooldown
+	}
+
+	return b
+}
+
+// wait returns how long the consumer should wait before receiving more messages. Once an open circuit has cooled down
+// it moves to half-open and allows a single probe receive
+func (b *circuitBreaker) wait() time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if remaining := b.openedAt.Add(b.cooldown).Sub(b.now()); remaining > 0 {
+			return remaining
+		}
+		b.transition(CircuitHalfOpen)
+		return 0
+	case CircuitHalfOpen:
+		if b.probing {
+			return probeInterval
+		}
+	}
+
+	return 0
+}
+
+// halfOpen reports whether the next receive is a probe
+func (b *circuitBreaker) halfOpen() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == CircuitHalfOpen
+}
+
+// probe marks the probe message as dispatched
+func (b *circuitBreaker) probe() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen {
+		b.probing = true
+	}
+}
+
+// abandon clears the probe when the probe message did not reach a handler, e.g. it had no route or was a duplicate, so
+// the next receive probes again
+func (b *circuitBreaker) abandon() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+	}
+}
+
+// record tracks the outcome of a handler
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		if b.state != CircuitClosed {
+			b.transition(CircuitClosed)
+		}
+		return
+	}
+
+	if b.state == CircuitHalfOpen {
+		b.transition(CircuitOpen)
+		return
+	}
+
+	now := b.now()
+	if b.failures == 0 || (b.window > 0 && now.Sub(b.firstFailure) > b.window) {
+		b.failures = 0
+		b.firstFailure = now
+	}
+
+	b.failures++
+	if b.state == CircuitClosed && b.failures >= b.threshold {
+		b.transition(CircuitOpen)
+	}
+}
+
+// transition changes the state and notifies the state change callback, the lock must be held
+func (b *circuitBreaker) transition(to CircuitState) {
+	from := b.state
+	b.state = to
+	b.probing = false
+
+	if to == CircuitOpen {
+		b.openedAt = b.now()
+	}
+
+	if to != CircuitClosed {
+		b.failures = 0
+	}
+
+	if b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
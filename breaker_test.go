@@ -0,0 +1,111 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	var changes []CircuitState
+	b := newCircuitBreaker(Config{
+		CircuitBreakerThreshold: 3,
+		CircuitBreakerWindow:    time.Minute,
+		CircuitBreakerCooldown:  10 * time.Second,
+		OnCircuitStateChange: func(from, to CircuitState) {
+			changes = append(changes, to)
+		},
+	})
+	b.now = func() time.Time { return now }
+
+	failure := errors.New("downstream unavailable")
+	b.record(failure)
+	b.record(failure)
+	if b.wait() != 0 {
+		t.Fatal("circuit opened before reaching the threshold")
+	}
+
+	b.record(failure)
+	if wait := b.wait(); wait != 10*time.Second {
+		t.Fatalf("expected the circuit to open for 10s, got %s", wait)
+	}
+
+	now = now.Add(11 * time.Second)
+	if wait := b.wait(); wait != 0 || !b.halfOpen() {
+		t.Fatalf("expected the circuit to be half-open after the cooldown, got wait %s", wait)
+	}
+
+	b.probe()
+	if wait := b.wait(); wait != probeInterval {
+		t.Fatalf("expected to wait on the probe, got %s", wait)
+	}
+
+	b.record(failure)
+	if wait := b.wait(); wait != 10*time.Second {
+		t.Fatalf("expected a failed probe to reopen the circuit, got %s", wait)
+	}
+
+	now = now.Add(11 * time.Second)
+	b.wait()
+	b.probe()
+	b.record(nil)
+	if b.wait() != 0 || b.halfOpen() {
+		t.Fatal("expected a successful probe to close the circuit")
+	}
+
+	expected := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(changes) != len(expected) {
+		t.Fatalf("unexpected state changes, expected %v, got %v", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Fatalf("unexpected state changes, expected %v, got %v", expected, changes)
+		}
+	}
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(Config{CircuitBreakerThreshold: 2, CircuitBreakerWindow: time.Second})
+	b.now = func() time.Time { return now }
+
+	b.record(errors.New("failure"))
+	now = now.Add(2 * time.Second)
+	b.record(errors.New("failure"))
+	if b.wait() != 0 {
+		t.Fatal("failures outside of the window should not open the circuit")
+	}
+}
+
+func TestCircuitBreakerUnhandledProbe(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(Config{CircuitBreakerThreshold: 1, CircuitBreakerCooldown: time.Second})
+	b.now = func() time.Time { return now }
+
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, breaker: b}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return nil })
+
+	b.record(errors.New("downstream unavailable"))
+	now = now.Add(2 * time.Second)
+
+	m.add("queue", "", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+	if wait := b.wait(); wait != 0 || !b.halfOpen() {
+		t.Fatalf("expected a probe without a route to allow another probe, got wait %s", wait)
+	}
+
+	m.add("queue", "post_deleted", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+	if wait := b.wait(); wait != 0 || !b.halfOpen() {
+		t.Fatalf("expected a probe without a handler to allow another probe, got wait %s", wait)
+	}
+
+	m.add("queue", "post_created", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+	if b.halfOpen() {
+		t.Errorf("expected a handled probe to close the circuit")
+	}
+}
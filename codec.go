@@ -0,0 +1,64 @@
+package gosqs
+
+import (
+	"encoding/json"
+)
+
+// contentTypeAttribute is the message attribute naming the codec a body was encoded with
+const contentTypeAttribute = "content-type"
+
+// Codec encodes and decodes message bodies of a content type, e.g. protobuf for "application/x-protobuf"
+type Codec interface {
+	// ContentType is sent as the content-type attribute and used to select the codec when decoding
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes bodies as JSON, it is used when no codec is configured and for messages without a content-type attribute
+type JSONCodec struct{}
+
+// ContentType returns application/json
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+// Marshal encodes v as JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// marshalBody encodes a body with the codec, JSON is used when there is none
+func marshalBody(codec Codec, v interface{}) ([]byte, error) {
+	if codec == nil {
+		return json.Marshal(v)
+	}
+
+	return codec.Marshal(v)
+}
+
+// codecAttributes returns the content-type attribute of the codec, messages encoded with the default JSON are sent without it
+func codecAttributes(codec Codec) []customAttribute {
+	if codec == nil {
+		return nil
+	}
+
+	return []customAttribute{{contentTypeAttribute, DataTypeString.String(), codec.ContentType()}}
+}
+
+// codecIndex maps the content types the consumer can decode to their codec
+func codecIndex(c Config) map[string]Codec {
+	index := map[string]Codec{}
+	for _, codec := range append([]Codec{JSONCodec{}, c.Codec}, c.Codecs...) {
+		if codec != nil {
+			index[codec.ContentType()] = codec
+		}
+	}
+
+	return index
+}
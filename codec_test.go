@@ -0,0 +1,64 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// upperCodec is a minimal non-JSON codec storing strings in upper case
+type upperCodec struct{}
+
+func (upperCodec) ContentType() string { return "text/upper" }
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(fmt.Sprint(v))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*string)) = strings.ToLower(string(data))
+	return nil
+}
+
+func TestCodec(t *testing.T) {
+	m := newMockSQS()
+	conf := Config{Codec: upperCodec{}}
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/", codec: conf.Codec, attributes: codecAttributes(conf.Codec)}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", "hello"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	m.add("http://localhost:4100/dev-post-worker", "post_created", `"json"`)
+
+	if got := *m.sent[0].MessageBody; got != "HELLO" {
+		t.Fatalf("expected the body to be encoded with the codec, got %s", got)
+	}
+
+	if got := *m.sent[0].MessageAttributes["content-type"].StringValue; got != "text/upper" {
+		t.Fatalf("expected the content type attribute, got %s", got)
+	}
+
+	var decoded []string
+	c := &consumer{sqs: m, QueueURL: "http://localhost:4100/dev-post-worker", logger: &testLogger{}, codecs: codecIndex(Config{Codecs: []Codec{upperCodec{}}})}
+	c.poll(func(msg *message) {
+		var out string
+		if err := msg.Decode(&out); err != nil {
+			t.Errorf("unexpected decode error, got %v", err)
+		}
+		decoded = append(decoded, out)
+	})
+
+	if len(decoded) != 2 || decoded[0] != "hello" || decoded[1] != "json" {
+		t.Errorf("expected each message to be decoded by its content type, got %v", decoded)
+	}
+
+	m.add(c.QueueURL, "post_created", "HELLO").MessageAttributes["content-type"] = m.sent[0].MessageAttributes["content-type"]
+	c.codecs = codecIndex(Config{})
+	c.poll(func(msg *message) {
+		var out string
+		if err := msg.Decode(&out); err == nil || !strings.Contains(err.Error(), ErrUnknownContentType.Err) {
+			t.Errorf("expected an unknown content type error, got %v", err)
+		}
+	})
+}
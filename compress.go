@@ -0,0 +1,113 @@
+package gosqs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+)
+
+const (
+	// contentEncodingAttribute is the message attribute naming the compression of a body
+	contentEncodingAttribute = "content-encoding"
+	// gzipEncoding marks bodies that are gzipped and then base64 encoded, as SQS bodies must be text
+	gzipEncoding = "gzip"
+)
+
+// gzipCodec compresses the bodies encoded by its codec, JSON is used when there is none
+type gzipCodec struct {
+	codec Codec
+}
+
+// withCompression wraps the codec with gzip compression if CompressBody is set without a CompressThreshold
+func withCompression(c Config) Codec {
+	if !c.compressAll() {
+		return c.Codec
+	}
+
+	return gzipCodec{codec: c.Codec}
+}
+
+// ContentType returns the content type of the wrapped codec
+func (g gzipCodec) ContentType() string {
+	if g.codec == nil {
+		return JSONCodec{}.ContentType()
+	}
+	return g.codec.ContentType()
+}
+
+// Marshal encodes v with the wrapped codec and compresses the result
+func (g gzipCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := marshalBody(g.codec, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return gzipBody(b)
+}
+
+// gzipBody compresses and base64 encodes a body
+func gzipBody(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, base64.StdEncoding.EncodedLen(buf.Len()))
+	base64.StdEncoding.Encode(out, buf.Bytes())
+	return out, nil
+}
+
+// Unmarshal decodes data with the wrapped codec, compressed bodies are decompressed when they are received
+func (g gzipCodec) Unmarshal(data []byte, v interface{}) error {
+	if g.codec == nil {
+		return JSONCodec{}.Unmarshal(data, v)
+	}
+	return g.codec.Unmarshal(data, v)
+}
+
+// compressionAttributes returns the content-encoding attribute sent with compressed bodies
+func compressionAttributes(c Config) []customAttribute {
+	if !c.compressAll() {
+		return nil
+	}
+
+	return []customAttribute{{contentEncodingAttribute, DataTypeString.String(), gzipEncoding}}
+}
+
+// decompress replaces a gzipped body with its decompressed content. Messages without the content-encoding attribute are
+// left untouched, so compressed and uncompressed messages can share a queue. A body that cannot be decompressed fails
+// to decode with ErrDecode
+func (m *message) decompress() {
+	if m.Attribute(contentEncodingAttribute) != gzipEncoding || m.Body == nil || m.bodyErr != nil {
+		return
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(*m.Body)
+	if err != nil {
+		m.bodyErr = err
+		return
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		m.bodyErr = err
+		return
+	}
+
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		m.bodyErr = err
+		return
+	}
+
+	body := string(b)
+	cp := *m.Message
+	cp.Body = &body
+	m.Message = &cp
+}
@@ -0,0 +1,74 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestCompressBody(t *testing.T) {
+	conf := Config{CompressBody: true}
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/", codec: withCompression(conf), attributes: compressionAttributes(conf)}
+
+	body := sample{Val: strings.Repeat("a", 1000)}
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", &body); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	sent := m.sent[0]
+	if len(*sent.MessageBody) >= 1000 {
+		t.Errorf("expected the body to be compressed, got %d bytes", len(*sent.MessageBody))
+	}
+
+	if v := sent.MessageAttributes[contentEncodingAttribute]; v == nil || *v.StringValue != gzipEncoding {
+		t.Errorf("expected the content-encoding attribute, got %v", v)
+	}
+
+	u := "http://localhost:4100/dev-post-worker"
+	m.add(u, "post_created", `{"val":"plain"}`)
+	corrupt := m.add(u, "post_created", "not gzip")
+	corrupt.MessageAttributes[contentEncodingAttribute] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(gzipEncoding)}
+
+	var decoded []string
+	var errs []error
+	c := &consumer{sqs: m, QueueURL: u, logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		handlers: map[string]Handler{
+			"post_created": func(ctx context.Context, msg Message) error {
+				var s sample
+				if err := msg.Decode(&s); err != nil {
+					errs = append(errs, err)
+					return err
+				}
+				decoded = append(decoded, s.Val)
+				return nil
+			},
+		}}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(decoded) != 2 || decoded[0] != body.Val || decoded[1] != "plain" {
+		t.Errorf("expected compressed and plain bodies to decode, got %v", decoded)
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], ErrDecode) {
+		t.Errorf("expected the corrupt body to fail with %v, got %v", ErrDecode, errs)
+	}
+}
+
+func TestCompressMessageStructure(t *testing.T) {
+	conf := Config{CompressBody: true}
+	s := &mockSNS{}
+	p := &publisher{sns: s, arn: "arn:aws:sns:local:000000000000:todolist-dev", codec: withCompression(conf), attributes: compressionAttributes(conf)}
+
+	if _, err := p.Publish(context.TODO(), "post_created", nil, WithMessageStructureJSON(map[string]string{"default": "{}"})); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if _, ok := s.published[0].MessageAttributes[contentEncodingAttribute]; ok {
+		t.Error("expected protocol specific payloads to be sent uncompressed")
+	}
+}
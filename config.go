@@ -1,11 +1,19 @@
 package gosqs
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 )
@@ -19,14 +27,30 @@ type SessionProviderFunc func(c Config) (*session.Session, error)
 type Config struct {
 	// a way to provide custom session setup. A default based on key/secret will be used if not provided
 	SessionProvider SessionProviderFunc
+	// the SQS and SNS clients used instead of the AWS clients of the SessionProvider, e.g. memory.New() for unit tests and
+	// local development. Credentials, Region and Hostname are not used when it is set
+	Transport Transport
 	// private key to access aws
 	Key string
 	// secret to access aws
 	Secret string
 	// region for aws and used for determining the topic ARN
 	Region string
+	// region of the SNS topic when it differs from Region, used for determining the topic ARN and by the SNS client.
+	// Default is Region
+	TopicRegion string
 	// provided automatically by aws, but must be set for emulators or local testing
 	Hostname string
+	// resolves the SQS and SNS endpoints per service, e.g. VPC interface endpoints with private DNS disabled. See
+	// ServiceEndpoints. Hostname takes precedence when both are set
+	EndpointResolver endpoints.Resolver
+	// appended to the user agent of every AWS request, e.g. "billing-service/1.4.2", to identify the traffic of a service.
+	// A custom SessionProvider is responsible for setting its own user agent
+	UserAgent string
+	// customize the request handlers of the SQS and SNS clients, e.g. to add headers, tweak signing or log every request.
+	// They are applied to the clients created from the session, including those of a custom SessionProvider, in order.
+	// They are not applied to a Transport
+	RequestHandlers []func(h *request.Handlers)
 	// account ID of the aws account, used for determining the topic ARN
 	AWSAccountID string
 	// environment name, used for determinig the topic ARN
@@ -35,22 +59,301 @@ type Config struct {
 	TopicPrefix string
 	// optional address of the topic, if this is not provided it will be created using other variables
 	TopicARN string
+	// optional topic in another region that Publish, PublishReader and the background sends retry against when publishing
+	// to the topic fails according to FailoverPolicy, e.g. for critical notifications that must survive a regional outage.
+	// Consumers have to subscribe to both topics
+	FailoverTopicARN string
+	// region of FailoverTopicARN, default is the region in the ARN
+	FailoverRegion string
+	// selects the failures that publish to FailoverTopicARN. Default is FailoverOnError
+	FailoverPolicy FailoverPolicy
+	// limits the attempt against the primary topic before failing over, default is 5s. It applies to every attempt when
+	// FailoverTopicARN is set, the AWS retries of the attempt included
+	FailoverTimeout time.Duration
 	// optional address of queue, if this is not provided it will be retrieved during setup
 	QueueURL string
-	// used to extend the allowed processing time of a message
+	// optional account ID of the account that owns the consumer's queue, used to resolve the url of a queue in another account.
+	// The queue policy must allow this account sqs:GetQueueUrl as well as the receive, delete and visibility actions
+	QueueOwnerAccountID string
+	// when true, the consumer creates its queue during setup if it does not exist
+	EnsureQueue bool
+	// ARN of the dead letter queue set in the RedrivePolicy of a queue created by EnsureQueue
+	DeadLetterQueueARN string
+	// name of the dead letter queue, prefixed with Env like the consumer's queue. It is created by EnsureQueue if it does not
+	// exist and set in the RedrivePolicy of the created queue. Ignored when DeadLetterQueueARN is set
+	DeadLetterQueue string
+	// number of receives before a message is moved to the dead letter queue, handlers registered with WithDeadLetterQueue
+	// move their messages on the last of them. Default is 5
+	MaxReceiveCount int
+	// url of the dead letter queue used by DecodeErrorDeadLetter, resolved from DeadLetterQueue when empty
+	DeadLetterQueueURL string
+	// when true, the publisher creates its topic during setup if it does not exist, the resulting ARN is used for publishing
+	EnsureTopic bool
+	// tags applied to queues and topics created by EnsureQueue and EnsureTopic, e.g. for cost allocation
+	Tags map[string]string
+	// when true, Tags are also applied to queues and topics that already exist
+	TagExistingResources bool
+	// used to extend the allowed processing time of a message. It is sent with every receive so consumers of the same queue can
+	// claim messages for different durations, the maximum is 43200 (12 hours)
 	VisibilityTimeout int
-	// used to determine how many attempts exponential backoff should use before logging an error
+	// visibility timeouts in seconds of messages by route, e.g. for message types that take longer to process. The
+	// messages are received with VisibilityTimeout and changed right after the receive, which costs a
+	// ChangeMessageVisibilityBatch call per receive, and extensions are based on the route's timeout
+	RouteVisibility map[string]int
+	// when true, the consumer compares the queue's VisibilityTimeout attribute with VisibilityTimeout during setup
+	// and updates the queue if they diverge, keeping the initial processing window in line with the extension math
+	SyncQueueVisibility bool
+	// when true, FIFO queues created by EnsureQueue deduplicate and limit throughput per message group, SQS then scales a
+	// FIFO queue with many message groups far beyond the throughput of a regular FIFO queue. Set it as well for existing
+	// high throughput queues so CheckQueueDrift verifies their attributes and the consumer does not warn about many groups
+	HighThroughputFIFO bool
+	// when true, the consumer compares the queue's VisibilityTimeout and RedrivePolicy attributes with the config during
+	// setup and reports any difference to OnQueueDrift without updating the queue
+	CheckQueueDrift bool
+	// called with the attributes that drifted when CheckQueueDrift is set, each drift is logged when nil
+	OnQueueDrift func(drifts []QueueDrift)
+	// used to determine how many attempts exponential backoff should use before logging an error. Default is 10.
+	// Retry delays use full jitter, a random delay between 0 and the exponential backoff capped at 20s
 	RetryCount int
-	// defines the total amount of goroutines that can be run by the consumer
+	// defines the total amount of goroutines that can be run by the consumer. Default is 30, negative values are rejected.
+	// A pool of 1 processes messages strictly one after the other: every received batch of up to 10 messages is handled
+	// sequentially in arrival order before the next receive
 	WorkerPool int
+	// the most messages per second that are handed to the workers, e.g. to work through a backlog after an outage without
+	// overwhelming the downstreams. Received messages wait for their turn while their visibility timeout runs, keep it
+	// above 10 messages divided by the rate. Default is unlimited
+	MaxMessagesPerSecond float64
+	// determines how messages are distributed across the worker pool. The default processes messages of the same
+	// MessageGroupId in order for FIFO queues and hands messages to any idle worker for standard queues
+	DispatchStrategy DispatchStrategy
+	// returns the key whose messages are processed serially in arrival order, e.g. a user ID, while messages of different
+	// keys are processed in parallel. Setting it makes DispatchByGroup the default strategy for standard queues. This is
+	// best-effort as standard queues do not guarantee the delivery order. Default is the MessageGroupId
+	OrderingKey func(Message) string
+	// the most keys DispatchByGroup tracks at a time, receiving pauses while this many keys are being processed.
+	// Default is 1000
+	MaxOrderingKeys int
+	// when true, the messages of every receive are dispatched in the order of their PublishTime, falling back to their
+	// SentTime, instead of the order SQS returned them in. Messages with neither are dispatched first. This gives an
+	// approximate source order for standard queues subscribed to SNS, combine it with DispatchByGroup or a WorkerPool of 1
+	// to keep the order during processing
+	SortByPublishTime bool
 	// defines the total number of processing extensions that occur. Each proccessing extension will double the
 	// visibilitytimeout counter, ensuring the handler has more time to process the message. Default is 2 extensions (1m30s processing time)
 	// set to 0 to turn off extension processing
 	ExtensionLimit *int
+	// caps the wall-clock time a message's visibility is extended for, e.g. 10 * time.Minute. When set it replaces
+	// ExtensionLimit and extensions stop once the handler has run for MaxProcessingTime regardless of their count
+	MaxProcessingTime time.Duration
+	// called when the visibility of a message is no longer extended because ExtensionLimit or MaxProcessingTime was reached,
+	// the message becomes visible again once its visibility timeout expires
+	OnExtensionExhausted func(m Message)
 
 	// Add custom attributes to the message. This might be a correlationId or client meta information
 	// custom attributes will be viewable on the sqs dashboard as meta data
 	Attributes []customAttribute
+	// name of the service, when set it is sent as the source-service attribute of every message
+	ServiceName string
+	// version of the service, when set it is sent as the source-version attribute of every message
+	ServiceVersion string
+	// messages whose SelfSourceAttribute equals SelfSourceValue are deleted without calling their handler, preventing loops
+	// in services that consume the messages they publish. Set SelfSourceValue to ServiceName to skip every message sent by
+	// this service, including messages sent with MessageSelf. Default attribute is "source-service"
+	SelfSourceAttribute string
+	// value of SelfSourceAttribute identifying messages sent by this service. Set to "" to process every message (default)
+	SelfSourceValue string
+	// custom attributes that only apply to messages sent to a specific target, keyed by queue name or topic ARN.
+	// Use Config.NewTargetAttribute to add them. Attributes are merged with the precedence call > target > Config.Attributes
+	TargetAttributes map[string][]customAttribute
+
+	// optional top level field of the JSON body that holds the route of messages that were published without a route attribute.
+	// The body is parsed once to find the route and again when the handler decodes it
+	BodyTypeField string
+	// optional function deriving the handler key of a received message, e.g. from m.TopicARN(), m.Subject(), attributes or the
+	// body, for queues fed by topics that carry the message type differently. It replaces the route attribute and BodyTypeField,
+	// messages for which it returns an empty string are not processed
+	Route func(m Message) string
+
+	// messages that were sent longer ago than MaxMessageAge are deleted when they are received without running the handler.
+	// Set to 0 to process messages regardless of their age (default)
+	MaxMessageAge time.Duration
+	// called with every message that was dropped because it exceeded MaxMessageAge
+	OnStale func(m Message)
+	// called once receives have returned no messages for IdleAfter, e.g. to scale the consumer to zero. d is how long the
+	// queue has been empty, it is called once per idle period and the next received message starts a new one
+	OnIdle func(d time.Duration)
+	// how long consecutive receives must return no messages before OnIdle is called
+	IdleAfter time.Duration
+	// called by NewConsumer with the tags of the queue before the consumer is created, e.g. to derive the VisibilityTimeout
+	// from an slo-tier tag so platform teams control consumers through their queues. Changes made to the config apply to
+	// the consumer, except those that resolve or create the queue as that happens before the tags can be read. An error
+	// fails NewConsumer with SetupValidation
+	ConfigureFromTags func(tags map[string]string, c *Config) error
+	// how often the receive loop emits a heartbeat with the number of messages processed since the previous one and whether
+	// the queue was empty, so a healthy idle consumer can be told apart from a hung one. Heartbeats are emitted between
+	// receives, none are emitted while the loop is paused or stuck. Set to 0 to disable (default)
+	HeartbeatInterval time.Duration
+	// called with every heartbeat, the heartbeat is logged when it is nil
+	OnHeartbeat HeartbeatFunc
+
+	// when true, the messages of a receive are only deleted once every one of them was handled successfully. If any handler
+	// fails none are deleted and the whole batch is redelivered, including the messages that already succeeded, so handlers
+	// must be idempotent. Messages are not extended while waiting for the rest of the batch, VisibilityTimeout should cover
+	// the processing time of the whole batch
+	AtomicBatches bool
+
+	// called when a handler returns an error of m.Decode, before OnDecodeErrorAction is applied
+	OnDecodeError func(m Message, err error)
+	// determines whether messages that fail to decode are redelivered (default), dropped or moved to the dead letter queue
+	OnDecodeErrorAction DecodeErrorAction
+
+	// called when the body of a message does not match the schema registered with RegisterSchema, before it is moved to
+	// DeadLetterQueueURL. The validation error is logged when it is nil
+	OnValidationError func(m Message, err error)
+
+	// when set, a failed message is redelivered after RetryBackoffBase * 2^(receives-1) instead of after the visibility
+	// timeout, backing off from a struggling downstream. It relies on ApproximateReceiveCount and does not apply to
+	// AtomicBatches or handlers returning gosqs.RetryAfter. Set to 0 to disable (default)
+	RetryBackoffBase time.Duration
+	// the longest delay of RetryBackoffBase. Default is 15 minutes, delays are always capped at 12 hours
+	RetryBackoffMax time.Duration
+	// when true, a message whose handler fails with a retryable error is sent to the queue again with the retry-attempt and
+	// retry-last-error attributes and the original is deleted, so the next attempt can read why the previous one failed.
+	// The copy is delayed by the RetryAfterError, the retry backoff or VisibilityTimeout, at most 15 minutes. After
+	// MaxReceiveCount attempts, or if the attributes do not fit within the limit of 10, the message is redelivered as
+	// usual. Not supported for FIFO queues
+	RepublishOnRetry bool
+
+	// the longest a message deferred with gosqs.Defer is kept invisible while waiting for Ack or Nack, after which it is
+	// redelivered once its visibility timeout expires. Default is 1 hour, SQS limits it to 12 hours after the receive
+	ManualAckMaxHold time.Duration
+
+	// number of failed receives after which a message is handed to QuarantineSink and deleted from the queue, a lightweight
+	// alternative to a dead letter queue. It relies on ApproximateReceiveCount and does not apply to AtomicBatches.
+	// Set to 0 to disable quarantining (default)
+	QuarantineAfter int
+	// receives quarantined messages, e.g. gosqs.NewFileQuarantine or a gosqs.QuarantineFunc
+	QuarantineSink QuarantineSink
+
+	// runs at the end of NewConsumer, after the clients are set up and the queue is resolved, e.g. to prime caches or
+	// connect to downstream services before any message is received. NewConsumer fails with SetupWarmup if it errors
+	WarmupFunc func(ctx context.Context) error
+	// the longest WarmupFunc may run, its context is cancelled afterwards. Set to 0 for no limit (default)
+	WarmupTimeout time.Duration
+
+	// determines whether Shutdown waits for the received messages to finish (ShutdownDrain, default) or makes them visible
+	// again right away so other consumers pick them up (ShutdownFastHandoff)
+	ShutdownMode ShutdownMode
+	// when true, a ShutdownDrain keeps receiving and processing messages after the received ones finished until the queue is
+	// empty or the Shutdown context is done, e.g. for the last instance of a deployment that scales in. Other consumers that
+	// are still running compete for the same messages, only use it when this consumer is the last one
+	FinalDrain bool
+
+	// notified when a handler starts and ends processing a message, e.g. to show the messages currently being processed
+	InFlightTracker InFlightTracker
+	// called after every ReceiveMessage call with its SQS request ID, the number of received messages and the error if it
+	// failed, e.g. to correlate missing or delayed messages with AWS support
+	ReceiveHook ReceiveHookFunc
+	// called after every handler run with the route, duration and result of the handler
+	MetricsHook MetricsHookFunc
+	// called once the consumer is done with a message with the time it waited for a worker, spent decoding, in the
+	// handler and deleting it, e.g. to find out whether decoding, the handler or the AWS calls dominate the latency
+	TimingsHook TimingsHookFunc
+	// called for every message that could not be deleted after it was processed, e.g. to alert on the duplicate processing
+	// its redelivery causes. DeleteMessage is retried by the retryer before it is reported, the failed entries of an
+	// atomic batch are retried up to 3 times unless SQS reports a sender fault
+	OnDeleteFailure DeleteFailureHookFunc
+	// when set, the consumer never deletes messages. Every message it is done with, because its handler succeeded or it
+	// was dead-lettered, quarantined, skipped or dropped, is handed to ExternalAck with its receipt handle instead, e.g. for
+	// a separate process that deletes it once a multi-step workflow completes. Messages that are not deleted by the
+	// caller are redelivered once their visibility timeout expires and eventually moved by the redrive policy
+	ExternalAck ExternalAckFunc
+	// handlers running longer than SlowHandlerThreshold are logged as slow, including the route and message ID.
+	// Set to 0 to disable slow handler logging (default)
+	SlowHandlerThreshold time.Duration
+
+	// when true, the publisher builds every message as usual but hands the SNS and SQS send inputs to DryRunHook instead of
+	// sending them, returning synthetic message IDs. Setup calls such as EnsureTopic still reach AWS
+	DryRun bool
+	// receives the *sns.PublishInput, *sqs.SendMessageInput or *sqs.SendMessageBatchInput of a dry run. Default logs the input
+	DryRunHook func(input interface{})
+
+	// attributes whose values are shown as "***" whenever the package logs message contents, e.g. messages without a route
+	// or dry run inputs
+	RedactAttributes []string
+	// optional function applied to message bodies before the package logs them
+	RedactBody func(body string) string
+
+	// encodes the bodies of sent messages, which then carry its content type as the content-type attribute. Default is JSON
+	// without a content-type attribute
+	Codec Codec
+	// when true, bodies are gzipped and base64 encoded before they are sent and carry a content-encoding attribute of
+	// "gzip". Consumers decompress such bodies before decoding regardless of this setting, so compressed and uncompressed
+	// messages can share a queue
+	CompressBody bool
+	// when set, only bodies larger than this many bytes are compressed, whether or not CompressBody is set. Compression is
+	// skipped when it does not make the body smaller
+	CompressThreshold int
+	// S3 bucket that bodies are offloaded to when the message is still larger than OffloadThreshold after compression. The
+	// message then carries a pointer to the object and the ExtendedPayloadSize attribute, the format of the AWS extended
+	// client libraries. Consumers download offloaded bodies before decompressing them regardless of this setting. Objects
+	// are not deleted by gosqs, expire them with a lifecycle rule of the bucket
+	OffloadBucket string
+	// prefix of the keys of offloaded bodies, e.g. "orders/"
+	OffloadKeyPrefix string
+	// message size in bytes, body and attributes, above which the body is offloaded to OffloadBucket. Default and maximum
+	// is the limit of 262144 bytes
+	OffloadThreshold int
+	// when true, attributes beyond the limit of 10 per message are moved into a "_gosqs_attrs" field of the JSON object body
+	// instead of failing the send, and consumers merge them back into the message attributes on receive regardless of this
+	// setting. The route, content-type, content-encoding and correlation attributes are never moved. Moved attributes count
+	// towards the body size and cannot be matched by SNS filter policies, bodies that are not JSON objects or are compressed
+	// still fail with ErrAttributeOverflow
+	AttributeOverflow bool
+	// additional codecs the consumer uses to decode messages by their content-type attribute, messages without one are
+	// decoded as JSON
+	Codecs []Codec
+
+	// name of the String attribute holding the correlation ID set with gosqs.WithCorrelationID. Default is "correlationId"
+	CorrelationAttribute string
+
+	// system attributes requested when receiving messages, e.g. SentTimestamp or ApproximateReceiveCount. Default is "All"
+	AttributeNames []string
+	// message attributes requested when receiving messages. Default is "All", the route attribute is always requested
+	// as it is required for routing messages to their handlers
+	MessageAttributeNames []string
+	// when true, AttributeNames and MessageAttributeNames default to the names required by the enabled features instead of
+	// "All", trimming the payload of every receive on high throughput queues. Names listed in AttributeNames and
+	// MessageAttributeNames are requested in addition
+	MinimalReceive bool
+
+	// number of consecutive handler failures within CircuitBreakerWindow that opens the circuit breaker. While open, the consumer
+	// stops receiving messages for CircuitBreakerCooldown and then processes a single probe message before resuming.
+	// Set to 0 to disable the circuit breaker (default)
+	CircuitBreakerThreshold int
+	// the window in which consecutive failures are counted, 0 counts failures regardless of when they occurred
+	CircuitBreakerWindow time.Duration
+	// how long receiving is paused once the circuit opens. Default is 30s
+	CircuitBreakerCooldown time.Duration
+	// called whenever the circuit breaker changes state, it must not block
+	OnCircuitStateChange func(from, to CircuitState)
+
+	// number of messages sent with Message that are buffered per queue and event and then sent in a single SendMessageBatch
+	// request, at most 10. Messages of different events are never mixed in a batch. Set to 0 to send every message on its
+	// own (default)
+	AutoBatchSize int
+	// the longest a buffered message waits for its batch to fill before it is sent anyway. Default is 100ms
+	AutoBatchInterval time.Duration
+
+	// when true, the signature of every message delivered through SNS without raw message delivery is verified against its
+	// signing certificate, messages that fail verification are logged and deleted. Certificates are downloaded from SNS and
+	// cached for an hour. Raw deliveries and direct messages carry no signature and are not verified. Default is false
+	VerifySNSSignature bool
+
+	// a message whose MessageId, or SNS message ID for SNS deliveries, was processed within the window is deleted without
+	// calling its handler. The IDs are kept in memory, so this only protects a single consumer process against duplicate
+	// deliveries, not several instances consuming the same queue. Set to 0 to disable (default)
+	InMemoryDedupWindow time.Duration
 
 	// Add a custom logger, the default will be log.Println
 	Logger Logger
@@ -60,7 +363,7 @@ type Config struct {
 // separate from the payload body. These attributes can be easily seen from the SQS console.
 type customAttribute struct {
 	Title string
-	// Use gosqs.DataTypeNumber or gosqs.DataTypeString
+	// Use gosqs.DataTypeNumber, gosqs.DataTypeString or a custom type such as gosqs.DataTypeStringArray
 	DataType string
 	// Value represents the value
 	Value string
@@ -69,38 +372,240 @@ type customAttribute struct {
 // NewCustomAttribute adds a custom attribute to SNS and SQS messages. This can include correlationIds, logIds, or any additional information you would like
 // separate from the payload body. These attributes can be easily seen from the SQS console.
 //
-// must use gosqs.DataTypeNumber of gosqs.DataTypeString for the datatype, the value must match the type provided
-func (c *Config) NewCustomAttribute(dataType dataType, title string, value interface{}) error {
-	if dataType == DataTypeNumber {
-		val, ok := value.(int)
+// use gosqs.DataTypeNumber or gosqs.DataTypeString for the datatype, the value must match the type provided.
+// Custom types such as "String.Array" or "Number.float" can be provided with gosqs.DataType("String.Array"), any type
+// based on Number requires an int, a float64 or a numeric string, every other type requires a string value that is sent as is
+func (c *Config) NewCustomAttribute(dataType DataType, title string, value interface{}) error {
+	attr, err := newCustomAttribute(dataType, title, value)
+	if err != nil {
+		return err
+	}
+
+	c.Attributes = append(c.Attributes, attr)
+	return nil
+}
+
+// NewTargetAttribute adds a custom attribute that is only sent to a single target, the target is either the name of a queue
+// that receives direct messages or the ARN of the topic. Target attributes override Config.Attributes with the same title
+// and are themselves overridden by attributes provided with the call
+func (c *Config) NewTargetAttribute(target string, dataType DataType, title string, value interface{}) error {
+	attr, err := newCustomAttribute(dataType, title, value)
+	if err != nil {
+		return err
+	}
+
+	if c.TargetAttributes == nil {
+		c.TargetAttributes = map[string][]customAttribute{}
+	}
+
+	c.TargetAttributes[target] = append(c.TargetAttributes[target], attr)
+	return nil
+}
+
+// newCustomAttribute validates the value against the datatype and creates the attribute
+func newCustomAttribute(dataType DataType, title string, value interface{}) (customAttribute, error) {
+	if dataType.isNumber() {
+		val, ok := numberValue(value)
 		if !ok {
-			return ErrMarshal
+			return customAttribute{}, ErrMarshal
 		}
 
-		c.Attributes = append(c.Attributes, customAttribute{title, dataType.String(), strconv.Itoa(val)})
-		return nil
+		return customAttribute{title, dataType.String(), val}, nil
 	}
 
 	val, ok := value.(string)
 	if !ok {
-		return ErrMarshal
+		return customAttribute{}, ErrMarshal
+	}
+
+	return customAttribute{title, dataType.String(), val}, nil
+}
+
+// numberValue formats the value of a Number attribute, an int, a float64 or a string holding a finite number
+func numberValue(value interface{}) (string, bool) {
+	switch val := value.(type) {
+	case int:
+		return strconv.Itoa(val), true
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case string:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		return val, true
+	}
+
+	return "", false
+}
+
+// maxVisibilityTimeout is the longest visibility timeout SQS accepts, 12 hours in seconds
+const maxVisibilityTimeout = 43200
+
+// Validate reports configuration values that can never work, it is called by NewConsumer
+func (c Config) Validate() error {
+	if c.WorkerPool < 0 {
+		return ErrInvalidConfig.Context(fmt.Errorf("WorkerPool must not be negative, got %d", c.WorkerPool))
+	}
+
+	if c.VisibilityTimeout < 0 || c.VisibilityTimeout > maxVisibilityTimeout {
+		return ErrInvalidConfig.Context(fmt.Errorf("VisibilityTimeout must be between 0 and %d seconds, got %d", maxVisibilityTimeout, c.VisibilityTimeout))
+	}
+
+	for route, timeout := range c.RouteVisibility {
+		if timeout < 1 || timeout > maxVisibilityTimeout {
+			return ErrInvalidConfig.Context(fmt.Errorf("RouteVisibility of %s must be between 1 and %d seconds, got %d", route, maxVisibilityTimeout, timeout))
+		}
+	}
+
+	if c.RetryBackoffBase < 0 || c.RetryBackoffMax < 0 {
+		return ErrInvalidConfig.Context(fmt.Errorf("RetryBackoffBase and RetryBackoffMax must not be negative"))
+	}
+
+	if c.AutoBatchSize < 0 || c.AutoBatchSize > maxBatchSize {
+		return ErrInvalidConfig.Context(fmt.Errorf("AutoBatchSize must be between 0 and %d, got %d", maxBatchSize, c.AutoBatchSize))
+	}
+
+	if c.CompressThreshold < 0 || c.OffloadThreshold < 0 {
+		return ErrInvalidConfig.Context(fmt.Errorf("CompressThreshold and OffloadThreshold must not be negative"))
+	}
+
+	if c.MaxMessagesPerSecond < 0 {
+		return ErrInvalidConfig.Context(fmt.Errorf("MaxMessagesPerSecond must not be negative"))
 	}
-	c.Attributes = append(c.Attributes, customAttribute{title, dataType.String(), val})
+
+	if c.HeartbeatInterval < 0 {
+		return ErrInvalidConfig.Context(fmt.Errorf("HeartbeatInterval must not be negative"))
+	}
+
+	if c.OnIdle != nil && c.IdleAfter <= 0 {
+		return ErrInvalidConfig.Context(fmt.Errorf("OnIdle requires a positive IdleAfter"))
+	}
+
+	if c.FailoverTopicARN != "" && !topicARNPattern.MatchString(c.FailoverTopicARN) {
+		return ErrInvalidConfig.Context(fmt.Errorf("invalid FailoverTopicARN %q", c.FailoverTopicARN))
+	}
+
+	if c.FailoverTimeout < 0 {
+		return ErrInvalidConfig.Context(fmt.Errorf("FailoverTimeout must not be negative"))
+	}
+
+	if c.OnDecodeErrorAction == DecodeErrorDeadLetter && c.DeadLetterQueueURL == "" && c.DeadLetterQueue == "" {
+		return ErrInvalidConfig.Context(fmt.Errorf("DecodeErrorDeadLetter requires DeadLetterQueueURL or DeadLetterQueue"))
+	}
+
 	return nil
 }
 
-type dataType string
+// topicRegion returns the region of the SNS topic
+func (c Config) topicRegion() string {
+	if c.TopicRegion == "" {
+		return c.Region
+	}
+	return c.TopicRegion
+}
 
-func (dt dataType) String() string {
+// targetAttributes returns a copy of TargetAttributes, so adding target attributes to the config after the publisher or
+// consumer was created does not race with the messages they send
+func (c Config) targetAttributes() map[string][]customAttribute {
+	out := make(map[string][]customAttribute, len(c.TargetAttributes))
+	for target, attrs := range c.TargetAttributes {
+		out[target] = append([]customAttribute{}, attrs...)
+	}
+	return out
+}
+
+// sourceAttributes returns the provenance attributes derived from ServiceName and ServiceVersion, they are added to every message
+// with the lowest precedence
+func (c Config) sourceAttributes() []customAttribute {
+	var attrs []customAttribute
+	if c.ServiceName != "" {
+		attrs = append(attrs, customAttribute{"source-service", DataTypeString.String(), c.ServiceName})
+	}
+
+	if c.ServiceVersion != "" {
+		attrs = append(attrs, customAttribute{"source-version", DataTypeString.String(), c.ServiceVersion})
+	}
+
+	return attrs
+}
+
+// mergeAttributes combines layers of custom attributes, attributes in later layers override earlier ones with the same title
+func mergeAttributes(layers ...[]customAttribute) []customAttribute {
+	var out []customAttribute
+	index := map[string]int{}
+	for _, layer := range layers {
+		for _, attr := range layer {
+			if i, ok := index[attr.Title]; ok {
+				out[i] = attr
+				continue
+			}
+			index[attr.Title] = len(out)
+			out = append(out, attr)
+		}
+	}
+
+	return out
+}
+
+// DataType represents the data type of a custom attribute. SNS and SQS accept the base types String, Number and Binary,
+// optionally followed by a custom label e.g. "Number.float" or "String.Array"
+type DataType string
+
+func (dt DataType) String() string {
 	return string(dt)
 }
 
+// isNumber reports whether the datatype is the Number base type or a custom type derived from it
+func (dt DataType) isNumber() bool {
+	return dt == DataTypeNumber || strings.HasPrefix(dt.String(), DataTypeNumber.String()+".")
+}
+
 // DataTypeNumber represents the Number datatype, use it when creating custom attributes
-const DataTypeNumber = dataType("Number")
+const DataTypeNumber = DataType("Number")
 
 // DataTypeString represents the String datatype, use it when creating custom attributes
-const DataTypeString = dataType("String")
+const DataTypeString = DataType("String")
+
+// DataTypeStringArray represents the SNS String.Array datatype, the value must be a JSON encoded array
+const DataTypeStringArray = DataType("String.Array")
+
+const (
+	// retryBaseDelay is the base delay used to calculate the backoff of a failed aws request
+	retryBaseDelay = 30 * time.Millisecond
+	// retryThrottleBaseDelay is the base delay used to calculate the backoff of a throttled aws request
+	retryThrottleBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay caps the backoff of a single retry
+	retryMaxDelay = 20 * time.Second
+)
+
+// jitterRand is the source used for randomizing retry delays, it is seeded at startup so that separate processes do not
+// share the same retry schedule
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// fullJitter returns a random delay between 0 and base * 2^attempt, capped at max
+func fullJitter(base, max time.Duration, attempt int) time.Duration {
+	ceil := max
+	if attempt < 32 {
+		if d := base << uint(attempt); d > 0 && d < max {
+			ceil = d
+		}
+	}
 
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(ceil) + 1))
+}
+
+// retryer retries failed aws requests using exponential backoff with full jitter. Retries are spread randomly between 0 and
+// the exponential delay (30ms base, 500ms base when throttled, capped at 20s) so that many workers or consumers that are
+// throttled at the same time do not retry in lockstep
 type retryer struct {
 	client.DefaultRetryer
 	retryCount int
@@ -115,6 +620,25 @@ func (r retryer) MaxRetries() int {
 	return 10
 }
 
+// ShouldRetry returns true if the request failed with a retryable or throttling error
+func (r retryer) ShouldRetry(req *request.Request) bool {
+	if req.Retryable != nil {
+		return *req.Retryable
+	}
+
+	return req.IsErrorRetryable() || req.IsErrorThrottle()
+}
+
+// RetryRules returns the jittered delay before retrying the request
+func (r retryer) RetryRules(req *request.Request) time.Duration {
+	base := retryBaseDelay
+	if req.IsErrorThrottle() {
+		base = retryThrottleBaseDelay
+	}
+
+	return fullJitter(base, retryMaxDelay, req.RetryCount)
+}
+
 // newSession creates a new aws session.
 // This will be used as the default SessionProvider if one is not set
 func newSession(c Config) (*session.Session, error) {
@@ -136,5 +660,30 @@ func newSession(c Config) (*session.Session, error) {
 		cfg.Endpoint = &c.Hostname
 	}
 
-	return session.NewSession(cfg)
+	if c.EndpointResolver != nil {
+		cfg.EndpointResolver = c.EndpointResolver
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.UserAgent != "" {
+		sess.Handlers.Build.PushBack(request.MakeAddToUserAgentFreeFormHandler(c.UserAgent))
+	}
+
+	return sess, nil
+}
+
+// ServiceEndpoints returns an endpoint resolver that sends the requests of a service to its url, keyed by the service
+// ID "sqs" or "sns", e.g. the DNS names of VPC interface endpoints. Services without a url use the default AWS endpoints
+func ServiceEndpoints(urls map[string]string) endpoints.Resolver {
+	return endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if u, ok := urls[service]; ok {
+			return endpoints.ResolvedEndpoint{URL: u, SigningRegion: region}, nil
+		}
+
+		return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+	})
 }
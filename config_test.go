@@ -0,0 +1,194 @@
+package gosqs
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestNewCustomAttribute(t *testing.T) {
+	c := Config{}
+	if err := c.NewCustomAttribute(DataTypeNumber, "count", 1); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if err := c.NewCustomAttribute(DataType("Number.float"), "ratio", "one"); err != ErrMarshal {
+		t.Fatalf("expected %v for custom number type with a non numeric string, got %v", ErrMarshal, err)
+	}
+
+	if err := c.NewCustomAttribute(DataTypeStringArray, "tags", `["a","b"]`); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(c.Attributes) != 2 {
+		t.Fatalf("expected 2 attributes, got %d", len(c.Attributes))
+	}
+
+	if c.Attributes[1].DataType != "String.Array" {
+		t.Errorf("did not keep the custom data type, got %s", c.Attributes[1].DataType)
+	}
+}
+
+func TestNewCustomAttributeFloat(t *testing.T) {
+	c := Config{}
+	for _, value := range []interface{}{1.5, "2.25", 3} {
+		if err := c.NewCustomAttribute(DataType("Number.float"), "ratio", value); err != nil {
+			t.Fatalf("unexpected error for %v, got %v", value, err)
+		}
+	}
+
+	var got []string
+	for _, a := range c.Attributes {
+		got = append(got, a.Value)
+	}
+	if !reflect.DeepEqual(got, []string{"1.5", "2.25", "3"}) {
+		t.Errorf("expected the formatted numbers, got %v", got)
+	}
+
+	for _, value := range []interface{}{true, "NaN", math.Inf(1)} {
+		if err := c.NewCustomAttribute(DataType("Number.float"), "ratio", value); err != ErrMarshal {
+			t.Errorf("expected %v for %v, got %v", ErrMarshal, value, err)
+		}
+	}
+}
+
+func TestFullJitter(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 50; i++ {
+		d := fullJitter(base, max, 2)
+		if d < 0 || d > 400*time.Millisecond {
+			t.Fatalf("delay out of bounds, expected between 0 and 400ms, got %s", d)
+		}
+		seen[d] = true
+	}
+
+	if len(seen) < 2 {
+		t.Errorf("expected randomized delays, got %d distinct values", len(seen))
+	}
+
+	for i := 0; i < 50; i++ {
+		if d := fullJitter(base, max, 40); d > max {
+			t.Fatalf("delay exceeded the cap, expected at most %s, got %s", max, d)
+		}
+	}
+}
+
+func TestMergeAttributes(t *testing.T) {
+	global := []customAttribute{{"a", "String", "global"}, {"b", "String", "global"}}
+	target := []customAttribute{{"b", "String", "target"}}
+	call := []customAttribute{{"c", "String", "call"}}
+
+	got := mergeAttributes(global, target, call)
+	expected := []customAttribute{{"a", "String", "global"}, {"b", "String", "target"}, {"c", "String", "call"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected merge result, expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestSourceAttributes(t *testing.T) {
+	conf := Config{ServiceName: "posts", ServiceVersion: "1.2.0"}
+	conf.NewCustomAttribute(DataTypeString, "source-version", "override")
+
+	got := mergeAttributes(conf.sourceAttributes(), conf.Attributes)
+	expected := []customAttribute{{"source-service", "String", "posts"}, {"source-version", "String", "override"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected attributes, expected %+v, got %+v", expected, got)
+	}
+
+	if attrs := (Config{}).sourceAttributes(); len(attrs) != 0 {
+		t.Errorf("expected no source attributes without a service name, got %+v", attrs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Config{}).Validate(); err != nil {
+		t.Errorf("expected the zero config to be valid, got %v", err)
+	}
+
+	if err := (Config{WorkerPool: -1}).Validate(); err == nil || !strings.Contains(err.Error(), ErrInvalidConfig.Err) {
+		t.Errorf("expected a negative worker pool to be rejected, got %v", err)
+	}
+
+	if err := (Config{VisibilityTimeout: 43201}).Validate(); err == nil {
+		t.Error("expected a visibility timeout above 12 hours to be rejected")
+	}
+
+	if _, err := NewConsumer(Config{WorkerPool: -1}, "post-worker"); err == nil {
+		t.Error("expected NewConsumer to validate the config")
+	}
+}
+
+func TestNewSessionEndpointResolver(t *testing.T) {
+	resolver := ServiceEndpoints(map[string]string{"sqs": "https://vpce-0a1b.sqs.us-west-1.vpce.amazonaws.com"})
+	sess, err := newSession(Config{Key: "key", Secret: "secret", Region: "us-west-1", EndpointResolver: resolver})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := sqs.New(sess).Endpoint; got != "https://vpce-0a1b.sqs.us-west-1.vpce.amazonaws.com" {
+		t.Errorf("expected the sqs client to use the vpc endpoint, got %q", got)
+	}
+
+	if got := sns.New(sess).Endpoint; got != "https://sns.us-west-1.amazonaws.com" {
+		t.Errorf("expected the sns client to use the default endpoint, got %q", got)
+	}
+}
+
+func TestRequestHandlers(t *testing.T) {
+	var sent []string
+	conf := Config{Key: "key", Secret: "secret", Region: "us-west-1", RequestHandlers: []func(h *request.Handlers){
+		func(h *request.Handlers) {
+			h.Build.PushBack(func(r *request.Request) {
+				r.HTTPRequest.Header.Set("X-Tenant", "billing")
+				sent = append(sent, r.Operation.Name)
+			})
+		},
+	}}
+
+	tr, err := conf.transport()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sqsReq, _ := tr.SQS().(*sqs.SQS).GetQueueUrlRequest(&sqs.GetQueueUrlInput{QueueName: aws.String("dev-post-worker")})
+	snsReq, _ := tr.SNS().(*sns.SNS).PublishRequest(&sns.PublishInput{Message: aws.String("{}"), TopicArn: aws.String("arn")})
+	for _, req := range []*request.Request{sqsReq, snsReq} {
+		if err := req.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if h := req.HTTPRequest.Header.Get("X-Tenant"); h != "billing" {
+			t.Errorf("expected the handler to set the header of %s, got %q", req.Operation.Name, h)
+		}
+	}
+
+	if !reflect.DeepEqual(sent, []string{"GetQueueUrl", "Publish"}) {
+		t.Errorf("expected the handlers on both clients, got %v", sent)
+	}
+}
+
+func TestNewSessionUserAgent(t *testing.T) {
+	sess, err := newSession(Config{Key: "key", Secret: "secret", Region: "us-west-1", UserAgent: "billing-service/1.4.2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req, _ := sqs.New(sess).GetQueueUrlRequest(&sqs.GetQueueUrlInput{QueueName: aws.String("dev-post-worker")})
+	if err := req.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ua := req.HTTPRequest.Header.Get("User-Agent"); !strings.HasSuffix(ua, " billing-service/1.4.2") {
+		t.Fatalf("expected the user agent to be appended, got %q", ua)
+	}
+}
@@ -2,17 +2,28 @@ package gosqs
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 )
 
 var maxMessages = int64(10)
 
+// defaultWorkerPool is the number of workers used when Config.WorkerPool is not set
+const defaultWorkerPool = 30
+
 // Consumer provides an interface for receiving messages through AWS SQS and SNS
+//
+// NewConsumer returns the AWS backed implementation, code that registers handlers or sends direct messages should depend
+// on this interface so that a fake such as sqstesting.StubConsumer can be injected in tests
 type Consumer interface {
 	// Consume polls for new messages and if it finds one, decodes it, sends it to the handler and deletes it
 	//
@@ -27,22 +38,65 @@ type Consumer interface {
 	//
 	// When a new message is received, it runs in a separate go-routine that will handle the full consuming of the message, error reporting
 	// and deleting
+	//
+	// Consume returns once Shutdown is called
 	Consume()
 	// RegisterHandler registers an event listener and an associated handler. If the event matches, the handler will
 	// be run
 	RegisterHandler(name string, h Handler, adapters ...Adapter)
+	// RegisterRawHandler registers a handler that receives the body as is instead of decoding it from JSON
+	RegisterRawHandler(name string, h RawHandler, adapters ...Adapter)
+	// RequireHandlers returns ErrMissingHandlers naming every type without a registered handler, call it after registering
+	// the handlers to fail at startup instead of leaving unhandled messages on the queue
+	RequireHandlers(types ...string) error
+	// RegisterResultHandler registers a handler that returns the disposition of the message, e.g. ResultRetryAfter or
+	// ResultDeadLetter, instead of an error
+	RegisterResultHandler(name string, h ResultHandler, adapters ...Adapter)
+	// RegisterSchema registers the JSON schema the body of messages of the type must match before its handler runs, see
+	// Config.OnValidationError
+	RegisterSchema(msgType string, schema []byte) error
 	// Message serves as the direct messaging capability within the consumer. A worker can send direct messages to other workers
 	Message(ctx context.Context, queue, event string, body interface{})
 	// MessageSelf serves as the self messaging capability within the consumer, a worker can send messages to itself for continued
 	// processing and resiliency
 	MessageSelf(ctx context.Context, event string, body interface{})
+	// RedriveDLQ moves messages from the dead letter queue back into the consumer's queue and returns the number of messages moved.
+	// Use WithRedriveFilter to only move a selection of the dead-lettered messages
+	RedriveDLQ(ctx context.Context, dlqURL string, opts ...RedriveOption) (int, error)
+	// PeekMessage fetches the message with the SQS or SNS message ID without processing it, an empty ID returns the first
+	// message received. The messages received while searching are made visible again right away
+	PeekMessage(ctx context.Context, messageID string) (Message, error)
+	// ProcessOne runs the handler of the message's route without deleting it or affecting the live consumer
+	ProcessOne(ctx context.Context, m Message) error
+	// QueueTags returns the tags of the consumer's queue
+	QueueTags(ctx context.Context) (map[string]string, error)
+	// QueueDepth returns the approximate number of visible, in flight and delayed messages of the consumer's queue
+	QueueDepth() (QueueDepth, error)
+	// Stats returns a point in time snapshot of the consumer
+	Stats() ConsumerStats
+	// ResolvedQueueURL returns the queue url the consumer receives from, either as configured or as resolved during setup
+	ResolvedQueueURL() string
+	// Pause stops receiving new messages while in flight messages are processed as usual
+	Pause()
+	// Resume restarts receiving messages after Pause
+	Resume()
+	// IsPaused reports whether the consumer is paused
+	IsPaused() bool
+	// Shutdown stops receiving new messages and makes Consume return, the received messages are drained or handed off
+	// according to the ShutdownMode
+	Shutdown(ctx context.Context) error
+	// Reply sends a message to the ReplyTo queue of a request it received, carrying the request's correlation ID
+	Reply(ctx context.Context, request Message, event string, body interface{}) error
 }
 
+var _ Consumer = (*consumer)(nil)
+
 // consumer is a wrapper around sqs.SQS
 type consumer struct {
-	sqs               *sqs.SQS
+	sqs               sqsiface.SQSAPI
 	handlers          map[string]Handler
 	env               string
+	queueName         string
 	QueueURL          string
 	Hostname          string
 	VisibilityTimeout int
@@ -50,29 +104,100 @@ type consumer struct {
 	workerCount       int
 	extensionLimit    int
 	attributes        []customAttribute
+	targetAttributes  map[string][]customAttribute
+
+	attributeNames        []*string
+	messageAttributeNames []*string
+
+	breaker       *circuitBreaker
+	bodyTypeField string
+	router        func(Message) string
+	stats         consumerStats
+	strategy      DispatchStrategy
+	// orderingKey replaces the MessageGroupId as the key of DispatchByGroup, at most maxOrderingKeys are active at a time
+	orderingKey     func(Message) string
+	maxOrderingKeys int
+	// highThroughputFIFO silences the warning about FIFO queues with many message groups
+	highThroughputFIFO bool
+	maxMessageAge      time.Duration
+	onStale            func(Message)
+	metricsHook        MetricsHookFunc
+	timingsHook        TimingsHookFunc
+	onDeleteFailure    DeleteFailureHookFunc
+	slowHandler        time.Duration
+
+	correlationAttribute string
+	redactor             *redactor
+	atomicBatches        bool
+	quarantineAfter      int
+	quarantineSink       QuarantineSink
+	visibility           visibilityBatcher
+	codec                Codec
+	codecs               map[string]Codec
+	tracker              InFlightTracker
+	gate                 pauseGate
+	onDecodeError        func(Message, error)
+	schemas              map[string]*jsonSchema
+	onValidationError    func(Message, error)
+	decodeErrorAction    DecodeErrorAction
+	deadLetterQueueURL   string
+	maxReceiveCount      int
+	routeVisibility      map[string]int
+	reconnector          *reconnector
+	attempts             receiveAttempts
+	idle                 idleTracker
+	idleAfter            time.Duration
+	sortByPublishTime    bool
+	onIdle               func(d time.Duration)
+	heartbeat            heartbeat
+	heartbeatInterval    time.Duration
+	onHeartbeat          HeartbeatFunc
+	dedup                *dedup
+	selfSource           *selfSource
+	retryBackoff         *retryBackoff
+	republishOnRetry     bool
+	s3                   s3iface.S3API
+	externalAck          ExternalAckFunc
+	throttle             *rateLimiter
+	manualAckMaxHold     time.Duration
+	verifier             *signatureVerifier
+	receiveHook          ReceiveHookFunc
+	shutdownMode         ShutdownMode
+	finalDrain           bool
+	maxProcessingTime    time.Duration
+	onExtensionExhausted func(Message)
+	life                 lifecycle
 
 	logger Logger
 }
 
 // NewConsumer creates a new SQS instance and provides a configured consumer interface for
-// receiving and sending messages
+// receiving and sending messages. Errors are returned as a *SetupError
 func NewConsumer(c Config, queueName string) (Consumer, error) {
-	if c.SessionProvider == nil {
-		c.SessionProvider = newSession
+	if err := c.Validate(); err != nil {
+		return nil, setupErr(SetupValidation, err)
 	}
 
-	sess, err := c.SessionProvider(c)
-
+	transport, err := c.transport()
 	if err != nil {
-		return nil, err
+		return nil, setupErr(SetupCredentials, err)
+	}
+
+	if c.ConfigureFromTags != nil {
+		if c, err = c.configureFromTags(transport.SQS(), queueName); err != nil {
+			return nil, err
+		}
 	}
 
 	cons := &consumer{
-		sqs:               sqs.New(sess),
+		sqs:               transport.SQS(),
 		env:               c.Env,
+		queueName:         queueName,
 		VisibilityTimeout: 30,
-		workerPool:        30,
+		workerPool:        defaultWorkerPool,
 		extensionLimit:    2,
+		attributes:        mergeAttributes(c.sourceAttributes(), c.Attributes, codecAttributes(c.Codec), compressionAttributes(c)),
+		targetAttributes:  c.targetAttributes(),
 	}
 
 	if c.Logger != nil {
@@ -91,20 +216,179 @@ func NewConsumer(c Config, queueName string) (Consumer, error) {
 		cons.extensionLimit = *c.ExtensionLimit
 	}
 
+	cons.maxProcessingTime = c.MaxProcessingTime
+	cons.onExtensionExhausted = c.OnExtensionExhausted
+
+	cons.strategy = c.DispatchStrategy
+	cons.orderingKey = c.OrderingKey
+	cons.maxOrderingKeys = c.MaxOrderingKeys
+	cons.highThroughputFIFO = c.HighThroughputFIFO
+	cons.maxMessageAge = c.MaxMessageAge
+	cons.onStale = c.OnStale
+	cons.metricsHook = c.MetricsHook
+	cons.timingsHook = c.TimingsHook
+	cons.idleAfter = c.IdleAfter
+	cons.sortByPublishTime = c.SortByPublishTime
+	cons.onIdle = c.OnIdle
+	cons.heartbeatInterval = c.HeartbeatInterval
+	cons.onHeartbeat = c.OnHeartbeat
+	cons.onDeleteFailure = c.OnDeleteFailure
+	cons.slowHandler = c.SlowHandlerThreshold
+
+	// the group is needed to keep FIFO messages in order, the trace header is read through m.TraceHeader
+	required := []string{sqs.MessageSystemAttributeNameMessageGroupId, sqs.MessageSystemAttributeNameAwstraceHeader}
+	if c.MaxMessageAge > 0 || c.SortByPublishTime {
+		required = append(required, sqs.MessageSystemAttributeNameSentTimestamp)
+	}
+	if c.MetricsHook != nil {
+		required = append(required, sqs.MessageSystemAttributeNameSentTimestamp, sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp)
+	}
+	if c.QuarantineAfter > 0 || c.RetryBackoffBase > 0 {
+		required = append(required, sqs.MessageSystemAttributeNameApproximateReceiveCount)
+	}
+
+	// offloaded bodies cannot be downloaded when the Transport does not provide an S3 client, they fail to decode
+	cons.s3, _ = c.s3Client()
+	cons.correlationAttribute = correlationAttribute(c.CorrelationAttribute)
+	messageAttributes := []string{"route", cons.correlationAttribute, contentTypeAttribute, contentEncodingAttribute, replyToAttribute,
+		schemaVersionAttribute, traceHeaderPrefix + ".*"}
+	if c.SelfSourceValue != "" {
+		messageAttributes = append(messageAttributes, selfSourceAttribute(c.SelfSourceAttribute))
+	}
+	if cons.s3 != nil {
+		// without the size the S3 pointer would be handed to the handler as the body
+		messageAttributes = append(messageAttributes, offloadSizeAttribute)
+	}
+	if c.RepublishOnRetry {
+		// the attempt bounds the republished copies, each of them starts over with a receive count of 1
+		messageAttributes = append(messageAttributes, retryAttemptAttribute, retryLastErrorAttribute)
+	}
+	if c.MinimalReceive {
+		// only the names required by the enabled features are requested instead of All
+		c.AttributeNames = append(append([]string{}, c.AttributeNames...), required...)
+		c.MessageAttributeNames = append(append([]string{}, c.MessageAttributeNames...), messageAttributes...)
+	}
+	cons.attributeNames = receiveNames(c.AttributeNames, required...)
+	cons.messageAttributeNames = receiveNames(c.MessageAttributeNames, messageAttributes...)
+	cons.breaker = newCircuitBreaker(c)
+	cons.bodyTypeField = c.BodyTypeField
+	cons.router = c.Route
+	cons.redactor = newRedactor(c)
+	cons.atomicBatches = c.AtomicBatches
+	cons.quarantineAfter = c.QuarantineAfter
+	cons.quarantineSink = c.QuarantineSink
+	cons.codec = withCompression(c)
+	cons.codecs = codecIndex(c)
+	cons.tracker = c.InFlightTracker
+	cons.onDecodeError = c.OnDecodeError
+	cons.decodeErrorAction = c.OnDecodeErrorAction
+	cons.onValidationError = c.OnValidationError
+	cons.deadLetterQueueURL = c.DeadLetterQueueURL
+	cons.maxReceiveCount = c.MaxReceiveCount
+	cons.routeVisibility = c.RouteVisibility
+
+	// AWS clients are rebuilt with a fresh session when their credentials fail, a custom Transport is used as is
+	if c.Transport == nil {
+		cons.reconnector = newReconnector(cons.sqs, func() (sqsiface.SQSAPI, error) {
+			t, err := c.transport()
+			if err != nil {
+				return nil, err
+			}
+			return t.SQS(), nil
+		})
+		cons.sqs = cons.reconnector.client
+	}
+	cons.dedup = newDedup(c)
+	cons.selfSource = newSelfSource(c)
+	cons.retryBackoff = newRetryBackoff(c)
+	cons.republishOnRetry = c.RepublishOnRetry
+	cons.externalAck = c.ExternalAck
+	cons.throttle = newRateLimiter(c.MaxMessagesPerSecond)
+
+	cons.manualAckMaxHold = c.ManualAckMaxHold
+	cons.verifier = newSignatureVerifier(c)
+	cons.receiveHook = c.ReceiveHook
+	cons.shutdownMode = c.ShutdownMode
+	cons.finalDrain = c.FinalDrain
+
 	cons.QueueURL = c.QueueURL
 	// custom QueueURLs can be provided for testing and mocking purposes
 	if cons.QueueURL == "" {
-		name := fmt.Sprintf("%s-%s", c.Env, queueName)
-		o, err := cons.sqs.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: &name})
-		if err != nil {
-			return nil, err
+		if err := cons.resolveQueue(c, queueName); err != nil {
+			return nil, setupErr(SetupResolution, err)
 		}
-		cons.QueueURL = *o.QueueUrl
+	}
+
+	if c.SyncQueueVisibility {
+		if err := cons.syncVisibility(); err != nil {
+			return nil, setupErr(SetupResolution, err)
+		}
+	}
+
+	if c.CheckQueueDrift {
+		if err := cons.checkDrift(c); err != nil {
+			return nil, setupErr(SetupResolution, err)
+		}
+	}
+
+	if err := cons.resolveDeadLetterQueue(c); err != nil {
+		return nil, setupErr(SetupResolution, err)
+	}
+
+	if err := warmup(c); err != nil {
+		return nil, setupErr(SetupWarmup, err)
 	}
 
 	return cons, nil
 }
 
+// warmup runs the WarmupFunc within the WarmupTimeout
+func warmup(c Config) error {
+	if c.WarmupFunc == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+	if c.WarmupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.WarmupTimeout)
+		defer cancel()
+	}
+
+	if err := c.WarmupFunc(ctx); err != nil {
+		return ErrWarmup.Context(err)
+	}
+
+	return nil
+}
+
+// syncVisibility ensures the queue's default VisibilityTimeout matches the configured value, updating the queue
+// attribute if the two have diverged
+func (c *consumer) syncVisibility() error {
+	name := sqs.QueueAttributeNameVisibilityTimeout
+	o, err := c.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{QueueUrl: &c.QueueURL, AttributeNames: []*string{&name}})
+	if err != nil {
+		return ErrQueueAttributes.Context(err)
+	}
+
+	want := strconv.Itoa(c.VisibilityTimeout)
+	if got, ok := o.Attributes[name]; ok && got != nil && *got == want {
+		return nil
+	}
+
+	c.Logger().Println("queue visibility timeout does not match config, updating to", want)
+	if _, err := c.sqs.SetQueueAttributes(&sqs.SetQueueAttributesInput{QueueUrl: &c.QueueURL, Attributes: map[string]*string{name: &want}}); err != nil {
+		return ErrQueueAttributes.Context(err)
+	}
+
+	return nil
+}
+
+// ResolvedQueueURL returns the queue url the consumer receives from, either as configured or as resolved during setup
+func (c *consumer) ResolvedQueueURL() string {
+	return c.QueueURL
+}
+
 // Logger accesses the logging field or applies a default logger
 func (c *consumer) Logger() Logger {
 	if c.logger == nil {
@@ -129,10 +413,73 @@ func (c *consumer) RegisterHandler(name string, h Handler, adapters ...Adapter)
 	}
 }
 
+// RequireHandlers returns ErrMissingHandlers naming every type without a registered handler
+func (c *consumer) RequireHandlers(types ...string) error {
+	var missing []string
+	for _, t := range types {
+		if _, ok := c.handlers[t]; !ok {
+			missing = append(missing, t)
+		}
+	}
+
+	if len(missing) > 0 {
+		return ErrMissingHandlers.Context(fmt.Errorf("%s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
 var (
 	all = "All"
 )
 
+// receiveNames converts the configured attribute names for ReceiveMessage, defaulting to All. Required names are added if
+// they are missing from a custom selection
+func receiveNames(names []string, required ...string) []*string {
+	if len(names) == 0 {
+		return []*string{&all}
+	}
+
+	out := make([]*string, 0, len(names)+len(required))
+	seen := map[string]bool{}
+	for _, n := range append(append([]string{}, names...), required...) {
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, aws.String(n))
+	}
+
+	return out
+}
+
+// receiveInput builds the ReceiveMessage request used by the consumer
+func (c *consumer) receiveInput() *sqs.ReceiveMessageInput {
+	attributeNames := c.attributeNames
+	if attributeNames == nil {
+		attributeNames = []*string{&all}
+	}
+
+	messageAttributeNames := c.messageAttributeNames
+	if messageAttributeNames == nil {
+		messageAttributeNames = []*string{&all}
+	}
+
+	input := &sqs.ReceiveMessageInput{
+		QueueUrl:              &c.QueueURL,
+		MaxNumberOfMessages:   &maxMessages,
+		AttributeNames:        attributeNames,
+		MessageAttributeNames: messageAttributeNames,
+	}
+
+	// claim received messages for this consumer's visibility timeout rather than the queue default
+	if c.VisibilityTimeout > 0 {
+		input.VisibilityTimeout = aws.Int64(int64(c.VisibilityTimeout))
+	}
+
+	return input
+}
+
 // Consume polls for new messages and if it finds one, decodes it, sends it to the handler and deletes it
 //
 // A message is not considered dequeued until it has been sucessfully processed and deleted. There is a 30 Second
@@ -146,37 +493,183 @@ var (
 //
 // When a new message is received, it runs in a separate go-routine that will handle the full consuming of the message, error reporting
 // and deleting
+//
+// Consume returns once Shutdown is called
 func (c *consumer) Consume() {
-	jobs := make(chan *message)
-	for w := 1; w <= c.workerPool; w++ {
-		go c.worker(w, jobs)
-	}
+	c.Logger().Println(fmt.Sprintf("consuming %s with %d workers", c.QueueURL, c.workerPool))
+	dispatch := c.startWorkers()
 
 	for {
-		output, err := c.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: &c.QueueURL, MaxNumberOfMessages: &maxMessages, MessageAttributeNames: []*string{&all}})
-		if err != nil {
-			c.Logger().Println("%s , retrying in 10s", ErrGetMessage.Context(err).Error())
-			time.Sleep(10 * time.Second)
-			continue
+		c.gate.wait()
+		if c.life.isStopping() {
+			return
 		}
 
-		for _, m := range output.Messages {
-			if _, ok := m.MessageAttributes["route"]; !ok {
-				//a message will be sent to the DLQ automatically after 4 tries if it is received but not deleted
-				c.Logger().Println(ErrNoRoute.Error())
-				continue
-			}
+		pause := c.poll(dispatch)
+		c.beat(time.Now())
+		if pause > 0 {
+			c.life.sleep(pause)
+		}
+	}
+}
+
+// poll receives a single batch of messages and hands them to the workers. It returns how long the receive loop should wait
+// before polling again, any accumulated backoff is reset as soon as a receive succeeds
+func (c *consumer) poll(dispatch func(*message)) time.Duration {
+	ctx, cancel := c.life.receiveContext()
+	defer cancel()
+
+	pause, _ := c.receive(ctx, c.receiveInput(), dispatch)
+	return pause
+}
+
+// receive runs a single receive of poll and returns the pause along with the number of messages handed to the workers
+func (c *consumer) receive(ctx context.Context, input *sqs.ReceiveMessageInput, dispatch func(*message)) (time.Duration, int) {
+	// an open circuit breaker leaves messages in the queue until the cooldown has passed
+	if wait := c.breaker.wait(); wait > 0 {
+		return wait, 0
+	}
+
+	probe := c.breaker.halfOpen()
+	if probe {
+		input.MaxNumberOfMessages = aws.Int64(1)
+	}
+
+	if isFIFO(c.QueueURL) {
+		input.ReceiveRequestAttemptId = aws.String(c.attempts.next())
+	}
+
+	var requestID string
+	start := time.Now()
+	output, err := c.sqs.ReceiveMessageWithContext(ctx, input, captureRequestID(&requestID))
+	info := ReceiveInfo{RequestID: requestID, Err: err, Duration: time.Since(start), AttemptID: aws.StringValue(input.ReceiveRequestAttemptId)}
+	c.attempts.done(err)
+	if err != nil && ctx.Err() != nil {
+		// the receive was cancelled by Shutdown
+		return 0, 0
+	}
+
+	if err != nil {
+		c.received(info)
+
+		// expired or invalid credentials do not recover by retrying the same client
+		if c.reconnector != nil && isCredentialError(err) {
+			return c.reconnect(err, requestID), 0
+		}
 
-			jobs <- newMessage(m)
+		// throttling gets its own growing pause so a shared account can recover before we try again
+		if request.IsErrorThrottle(err) {
+			pause := c.stats.throttled(time.Now())
+			c.Logger().Println(ErrThrottled.Context(err).Error(), "request id", requestID, "retrying in", pause)
+			return pause, 0
 		}
+
+		c.Logger().Println(ErrGetMessage.Context(err).Error(), "request id", requestID, "retrying in 10s")
+		return 10 * time.Second, 0
+	}
+
+	info.Messages = len(output.Messages)
+	c.received(info)
+	c.trackIdle(time.Now(), len(output.Messages))
+	c.heartbeat.received(len(output.Messages))
+
+	c.stats.resetThrottle()
+	if c.reconnector != nil {
+		c.reconnector.reset()
+	}
+
+	if probe && len(output.Messages) > 0 {
+		c.breaker.probe()
+	}
+
+	received := make([]*message, 0, len(output.Messages))
+	for _, m := range output.Messages {
+		msg := c.prepare(m)
+		msg.probe = probe
+		if !c.resolveRoute(msg) {
+			//a message will be sent to the DLQ automatically after 4 tries if it is received but not deleted
+			c.logMessage(msg, ErrNoRoute.Error(), aws.StringValue(m.MessageId), c.redactor.messageAttributes(msg.MessageAttributes))
+			continue
+		}
+
+		if c.forged(msg) || c.stale(msg) || c.selfPublished(msg) {
+			continue
+		}
+
+		c.life.track(msg)
+		received = append(received, msg)
+	}
+
+	if probe && len(received) == 0 {
+		c.breaker.abandon()
+	}
+
+	now := time.Now()
+	for _, msg := range received {
+		msg.receivedAt = now
 	}
+	c.stats.received(now, len(received))
+	c.applyRouteVisibility(received)
+
+	if c.sortByPublishTime {
+		sortByPublishTime(received)
+	}
+
+	if c.atomicBatches && len(received) > 0 {
+		newReceivedBatch(received)
+	}
+
+	for _, msg := range received {
+		// the wait is not cancelled by Shutdown, the messages are tracked and have to be dispatched to be drained
+		c.throttle.wait(context.Background())
+		dispatch(msg)
+	}
+
+	return 0, len(received)
+}
+
+// stale deletes the message without processing it if it is older than the configured max message age
+func (c *consumer) stale(m *message) bool {
+	if c.maxMessageAge <= 0 {
+		return false
+	}
+
+	sent := m.SentTime()
+	if sent.IsZero() || time.Since(sent) <= c.maxMessageAge {
+		return false
+	}
+
+	if err := c.delete(m); err != nil {
+		return true
+	}
+
+	if c.onStale != nil {
+		c.onStale(m)
+	}
+
+	return true
+}
+
+// resolveRoute ensures the message has a route, falling back to the configured body field when the route attribute is missing.
+// A configured router takes precedence over both
+func (c *consumer) resolveRoute(m *message) bool {
+	if c.router != nil {
+		m.route = c.router(m)
+		return m.route != ""
+	}
+
+	if m.route == "" && c.bodyTypeField != "" {
+		m.route = m.routeFromBody(c.bodyTypeField)
+	}
+
+	return m.route != ""
 }
 
 // worker is an always-on concurrent worker that will take tasks when they are added into the messages buffer
 func (c *consumer) worker(id int, messages <-chan *message) {
 	for m := range messages {
 		if err := c.run(m); err != nil {
-			c.Logger().Println(err.Error())
+			c.logMessage(m, err.Error())
 		}
 	}
 }
@@ -188,26 +681,130 @@ func (c *consumer) worker(id int, messages <-chan *message) {
 // if the handler exists, it will wait for the err channel to be processed. Once it receives feedback from the handler in the form
 // of a channel, it will either log the error, or consume the message
 func (c *consumer) run(m *message) error {
+	defer c.stats.done()
+
+	// a deferred message stays tracked until it is acked, nacked or released, see hold
+	var held bool
+	defer func() {
+		if !held {
+			c.life.untrack(m)
+		}
+	}()
+
+	// the outcome of a handled probe has already moved the breaker out of half-open
+	if m.probe {
+		defer c.breaker.abandon()
+	}
+
+	// a message released by a fast handoff is received by another consumer
+	if c.life.isHandedOff() {
+		return nil
+	}
+
+	id := m.dedupID()
+	if !c.dedup.claim(id) {
+		c.logMessage(m, ErrDuplicateMessage.Error(), id, m.Route())
+		return c.consumed(m, nil)
+	}
+
+	var processed bool
+	defer func() { c.dedup.release(id, processed) }()
+
+	timings := c.newTimings(m)
+	defer c.reportTimings(timings)
+
 	if h, ok := c.handlers[m.Route()]; ok {
 		ctx := context.Background()
+		if id := m.CorrelationID(); id != "" {
+			ctx = ContextWithCorrelationID(ctx, id)
+		}
+
+		// a download that ran out of time is retried instead of failing to decode
+		if err := c.loadBody(ctx, m); err != nil {
+			if m.batch != nil {
+				return c.complete(m, err)
+			}
+			return err
+		}
+
+		if err := c.validateSchema(m); err != nil {
+			moved := c.rejectInvalid(ctx, m, err)
+			switch {
+			case m.batch != nil && moved:
+				processed = true
+				return c.complete(m, nil)
+			case m.batch != nil:
+				return c.complete(m, err)
+			case moved:
+				processed = true
+				return timings.timeDelete(func() error { return c.delete(m) })
+			}
+			return err
+		}
 
 		go c.extend(ctx, m)
-		if err := h(ctx, m); err != nil {
+		start := time.Now()
+		m.owner = c
+		c.stats.started()
+		err := c.handle(ctx, h, m)
+		c.stats.finished(err)
+		c.observe(m, time.Since(start), err)
+		timings.handled(m, time.Since(start), err)
+		c.breaker.record(err)
+		if err != nil {
+			if m.pending != nil {
+				m.pending.finish()
+			}
+
+			switch {
+			case m.batch != nil:
+				c.complete(m, err)
+			case c.decodeFailed(ctx, m, err), c.deadLettered(ctx, m, err), c.exhausted(ctx, m, err), c.quarantine(ctx, m, err):
+				processed = true
+				m.Success(ctx)
+				return timings.timeDelete(func() error { return c.delete(m) })
+			case c.republished(ctx, m, err):
+				// the copy is a new message, the claim is released so it is not dropped as a duplicate
+				m.Success(ctx)
+				return timings.timeDelete(func() error { return c.delete(m) })
+			case c.retryAfter(m, err):
+			default:
+				c.backoff(m)
+			}
 			return m.ErrorResponse(ctx, err)
 		}
 
+		// a healthy handler means any receive backoff is no longer needed
+		c.stats.resetThrottle()
+
 		// finish the extension channel if the message was processed successfully
 		m.Success(ctx)
+
+		// a deferred message is deleted once it is acked
+		if m.pending != nil {
+			processed, held = true, true
+			go c.hold(m.pending)
+			return nil
+		}
 	}
 
-	//deletes message if the handler was successful or if there was no handler with that route
-	return c.delete(m) //MESSAGE CONSUMED
+	processed = true
+	return c.consumed(m, timings)
+}
+
+// consumed deletes a message if the handler was successful or if there was no handler with that route
+func (c *consumer) consumed(m *message, timings *MessageTimings) error {
+	if m.batch != nil {
+		return c.complete(m, nil)
+	}
+
+	return timings.timeDelete(func() error { return c.delete(m) }) //MESSAGE CONSUMED
 }
 
 // MessageSelf serves as the self messaging capability within the consumer, a worker can send messages to itself for continued
 // processing and resiliency
 func (c *consumer) MessageSelf(ctx context.Context, event string, body interface{}) {
-	o, err := json.Marshal(body)
+	o, err := marshalBody(c.codec, body)
 	if err != nil {
 		log.Println(ErrMarshal.Context(err).Error(), event)
 		return
@@ -217,7 +814,7 @@ func (c *consumer) MessageSelf(ctx context.Context, event string, body interface
 
 	sqsInput := &sqs.SendMessageInput{
 		MessageBody:       &out,
-		MessageAttributes: defaultSQSAttributes(event, c.attributes...),
+		MessageAttributes: defaultSQSAttributes(event, c.attributesFor(ctx, c.queueName)...),
 		QueueUrl:          &c.QueueURL,
 	}
 
@@ -234,7 +831,7 @@ func (c *consumer) Message(ctx context.Context, queue, event string, body interf
 		return
 	}
 
-	o, err := json.Marshal(body)
+	o, err := marshalBody(c.codec, body)
 	if err != nil {
 		log.Println(ErrMarshal.Context(err).Error(), event)
 		return
@@ -244,13 +841,18 @@ func (c *consumer) Message(ctx context.Context, queue, event string, body interf
 
 	sqsInput := &sqs.SendMessageInput{
 		MessageBody:       &out,
-		MessageAttributes: defaultSQSAttributes(event, c.attributes...),
+		MessageAttributes: defaultSQSAttributes(event, c.attributesFor(ctx, queue)...),
 		QueueUrl:          queueResp.QueueUrl,
 	}
 
 	go c.sendDirectMessage(ctx, sqsInput, event)
 }
 
+// attributesFor merges the custom attributes for a message sent to the queue, carrying along the correlation ID of the context
+func (c *consumer) attributesFor(ctx context.Context, queue string) []customAttribute {
+	return mergeAttributes(c.attributes, c.targetAttributes[queue], correlationAttributes(c.correlationAttribute, CorrelationIDFromContext(ctx)))
+}
+
 // sendDirectMessage is a helper that should be run concurrently since it will block the main thread if there is a connection issue
 func (c *consumer) sendDirectMessage(ctx context.Context, input *sqs.SendMessageInput, event string) {
 	if _, err := c.sqs.SendMessage(input); err != nil {
@@ -262,39 +864,81 @@ func (c *consumer) sendDirectMessage(ctx context.Context, input *sqs.SendMessage
 
 // delete will remove a message from the queue, this is necessary to fully and successfully consume a message
 func (c *consumer) delete(m *message) error {
+	if c.externalAck != nil {
+		c.ackExternally(m)
+		return nil
+	}
+
 	_, err := c.sqs.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: &c.QueueURL, ReceiptHandle: m.ReceiptHandle})
 	if err != nil {
-		c.Logger().Println(ErrUnableToDelete.Context(err).Error())
-		return ErrUnableToDelete.Context(err)
+		err = ErrUnableToDelete.Context(err)
+		c.deleteFailed(m, DeleteFailure{Err: err})
+		return err
 	}
 	return nil
 }
 
 func (c *consumer) extend(ctx context.Context, m *message) {
 	var count int
-	extension := int64(c.VisibilityTimeout)
+	start := time.Now()
+	visibility := c.visibilityOf(m)
+	extension := int64(visibility)
 	for {
-		//only allow 1 extensions (Default 1m30s)
-		if count >= c.extensionLimit {
-			c.Logger().Println(ErrMessageProcessing.Error(), m.Route())
+		//only allow 1 extensions (Default 1m30s), a MaxProcessingTime replaces the limit
+		if c.maxProcessingTime <= 0 && count >= c.extensionLimit {
+			c.extensionExhausted(m)
 			return
 		}
 
 		count++
-		// allow 10 seconds to process the extension request
-		time.Sleep(time.Duration(c.VisibilityTimeout-10) * time.Second)
+		time.Sleep(extendAfter(visibility))
 		select {
 		case <-m.err:
 			// goroutine finished
 			return
 		default:
-			// double the allowed processing time
-			extension = extension + int64(c.VisibilityTimeout)
-			_, err := c.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{QueueUrl: &c.QueueURL, ReceiptHandle: m.ReceiptHandle, VisibilityTimeout: &extension})
-			if err != nil {
-				c.Logger().Println(ErrUnableToExtend.Error(), err.Error())
+			// the message was released by a fast handoff and must not be claimed again
+			if c.life.isHandedOff() {
+				return
+			}
+
+			if c.maxProcessingTime > 0 && time.Since(start) >= c.maxProcessingTime {
+				c.extensionExhausted(m)
+				return
+			}
+
+			if c.maxProcessingTime > 0 {
+				// every extension hides the message for another visibility timeout from now, the cap bounds the total
+				extension = int64(visibility)
+			} else {
+				// double the allowed processing time
+				extension = extension + int64(visibility)
+			}
+			if err := c.changeVisibility(m.ReceiptHandle, extension); err != nil {
+				c.logMessage(m, err.Error(), m.Route())
 				return
 			}
 		}
 	}
 }
+
+// extendAfter returns how long extend waits before changing the visibility. It allows 10 seconds to process the extension
+// request, timeouts of 10 seconds or less are extended halfway through instead so extend does not run in a hot loop
+func extendAfter(visibility int) time.Duration {
+	if visibility > 10 {
+		return time.Duration(visibility-10) * time.Second
+	}
+
+	if half := time.Duration(visibility) * time.Second / 2; half > time.Second {
+		return half
+	}
+	return time.Second
+}
+
+// extensionExhausted reports a message whose handler is still running once its visibility is no longer extended
+func (c *consumer) extensionExhausted(m *message) {
+	c.logMessage(m, ErrMessageProcessing.Error(), m.Route())
+	if c.onExtensionExhausted != nil {
+		c.onExtensionExhausted(m)
+	}
+}
@@ -2,10 +2,15 @@ package gosqs
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -136,6 +141,25 @@ func TestRegisterHandler(t *testing.T) {
 	}
 }
 
+func TestRequireHandlers(t *testing.T) {
+	c := &consumer{logger: &testLogger{}}
+	c.RegisterHandler("post_published", test)
+	c.RegisterRawHandler("post_archived", func(ctx context.Context, body []byte, m Message) error { return nil })
+
+	if err := c.RequireHandlers("post_published", "post_archived"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	err := c.RequireHandlers("post_published", "post_deleted", "post_flagged")
+	if !errors.Is(err, ErrMissingHandlers) {
+		t.Fatalf("expected %v, got %v", ErrMissingHandlers, err)
+	}
+
+	if !strings.Contains(err.Error(), "post_deleted, post_flagged") || strings.Contains(err.Error(), "post_published") {
+		t.Errorf("expected the error to name the missing types only, got %v", err)
+	}
+}
+
 func TestMessageSelf(t *testing.T) {
 	c := getConsumer(t)
 
@@ -227,3 +251,275 @@ func TestRun(t *testing.T) {
 	})
 
 }
+
+func TestSyncVisibility(t *testing.T) {
+	t.Run("matching", func(t *testing.T) {
+		v := "30"
+		m := &mockSQS{attributes: map[string]*string{sqs.QueueAttributeNameVisibilityTimeout: &v}}
+		c := &consumer{sqs: m, VisibilityTimeout: 30, logger: &testLogger{}}
+		if err := c.syncVisibility(); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if len(m.setInputs) != 0 {
+			t.Errorf("expected no update, got %d", len(m.setInputs))
+		}
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		v := "30"
+		m := &mockSQS{attributes: map[string]*string{sqs.QueueAttributeNameVisibilityTimeout: &v}}
+		c := &consumer{sqs: m, VisibilityTimeout: 120, logger: &testLogger{}}
+		if err := c.syncVisibility(); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		if len(m.setInputs) != 1 {
+			t.Fatalf("expected 1 update, got %d", len(m.setInputs))
+		}
+		if got := *m.attributes[sqs.QueueAttributeNameVisibilityTimeout]; got != "120" {
+			t.Errorf("did not update the visibility timeout, expected 120, got %s", got)
+		}
+	})
+}
+
+func TestReceiveInput(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c := &consumer{QueueURL: "queue"}
+		in := c.receiveInput()
+		if len(in.AttributeNames) != 1 || *in.AttributeNames[0] != "All" {
+			t.Errorf("expected All attribute names, got %v", aws.StringValueSlice(in.AttributeNames))
+		}
+		if len(in.MessageAttributeNames) != 1 || *in.MessageAttributeNames[0] != "All" {
+			t.Errorf("expected All message attribute names, got %v", aws.StringValueSlice(in.MessageAttributeNames))
+		}
+		if in.VisibilityTimeout != nil {
+			t.Errorf("expected the queue visibility timeout to be used, got %d", *in.VisibilityTimeout)
+		}
+	})
+
+	t.Run("visibility_timeout", func(t *testing.T) {
+		c := &consumer{QueueURL: "queue", VisibilityTimeout: 120}
+		if in := c.receiveInput(); in.VisibilityTimeout == nil || *in.VisibilityTimeout != 120 {
+			t.Errorf("expected the consumer's visibility timeout on the receive, got %v", in.VisibilityTimeout)
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		c := &consumer{
+			QueueURL:              "queue",
+			attributeNames:        receiveNames([]string{"SentTimestamp"}, "MessageGroupId"),
+			messageAttributeNames: receiveNames([]string{"correlationId"}, "route"),
+		}
+		in := c.receiveInput()
+		if got := aws.StringValueSlice(in.AttributeNames); !reflect.DeepEqual(got, []string{"SentTimestamp", "MessageGroupId"}) {
+			t.Errorf("unexpected attribute names, got %v", got)
+		}
+		if got := aws.StringValueSlice(in.MessageAttributeNames); !reflect.DeepEqual(got, []string{"correlationId", "route"}) {
+			t.Errorf("unexpected message attribute names, got %v", got)
+		}
+	})
+}
+
+func TestResolveRoute(t *testing.T) {
+	body := `{"type":"post_created","val":"val"}`
+	c := &consumer{bodyTypeField: "type"}
+
+	m := newMessage(&sqs.Message{Body: &body})
+	if !c.resolveRoute(m) || m.Route() != "post_created" {
+		t.Errorf("expected the route to be read from the body, got %q", m.Route())
+	}
+
+	route := "post_updated"
+	st := "String"
+	m = newMessage(&sqs.Message{Body: &body, MessageAttributes: map[string]*sqs.MessageAttributeValue{"route": {DataType: &st, StringValue: &route}}})
+	if !c.resolveRoute(m) || m.Route() != route {
+		t.Errorf("expected the route attribute to take precedence, got %q", m.Route())
+	}
+
+	c.bodyTypeField = ""
+	m = newMessage(&sqs.Message{Body: &body})
+	if c.resolveRoute(m) {
+		t.Error("expected a message without a route to be rejected")
+	}
+
+	c.router = func(m Message) string {
+		if m.TopicARN() == "" {
+			return ""
+		}
+		return m.TopicARN() + "/" + m.Subject()
+	}
+	env := `{"Type":"Notification","TopicArn":"arn:aws:sns:local:000000000000:billing","Subject":"invoice_paid","Message":"{}"}`
+	m = newMessage(&sqs.Message{Body: &env, MessageAttributes: map[string]*sqs.MessageAttributeValue{"route": {DataType: &st, StringValue: &route}}})
+	if !c.resolveRoute(m) || m.Route() != "arn:aws:sns:local:000000000000:billing/invoice_paid" {
+		t.Errorf("expected the router to take precedence, got %q", m.Route())
+	}
+
+	m = newMessage(&sqs.Message{Body: &body, MessageAttributes: map[string]*sqs.MessageAttributeValue{"route": {DataType: &st, StringValue: &route}}})
+	if c.resolveRoute(m) {
+		t.Error("expected a message the router returns no key for to be rejected")
+	}
+}
+
+func TestPollBackoffReset(t *testing.T) {
+	m := newMockSQS()
+	throttle := awserr.New("ThrottlingException", "rate exceeded", nil)
+	m.receiveErrs = []error{throttle, throttle, throttle}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+
+	var dispatched int
+	dispatch := func(*message) { dispatched++ }
+
+	var last time.Duration
+	for i := 0; i < 3; i++ {
+		pause := c.poll(dispatch)
+		if pause <= last {
+			t.Fatalf("expected the backoff to grow on throttle %d, got %s after %s", i, pause, last)
+		}
+		last = pause
+	}
+
+	m.add("queue", "post_created", "{}")
+	if pause := c.poll(dispatch); pause != 0 {
+		t.Fatalf("expected the next poll right after a successful receive, got %s", pause)
+	}
+
+	if dispatched != 1 {
+		t.Errorf("expected 1 dispatched message, got %d", dispatched)
+	}
+
+	if c.Stats().Throttled {
+		t.Error("expected the throttle state to be reset")
+	}
+
+	m.receiveErrs = []error{throttle}
+	if pause := c.poll(dispatch); pause != throttleBaseDelay {
+		t.Errorf("expected the backoff to start over, got %s", pause)
+	}
+}
+
+func TestPollEmptyReceives(t *testing.T) {
+	m := newMockSQS()
+	throttle := awserr.New("ThrottlingException", "rate exceeded", nil)
+	m.receiveErrs = []error{throttle}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+
+	var dispatched int
+	dispatch := func(*message) { dispatched++ }
+
+	if pause := c.poll(dispatch); pause == 0 {
+		t.Fatal("expected a throttled receive to back off")
+	}
+
+	for i := 0; i < 5; i++ {
+		if pause := c.poll(dispatch); pause != 0 {
+			t.Fatalf("expected no backoff after empty receive %d, got %s", i, pause)
+		}
+	}
+
+	m.add("queue", "post_created", "{}")
+	if pause := c.poll(dispatch); pause != 0 {
+		t.Fatalf("expected no backoff after the empty receives, got %s", pause)
+	}
+
+	if pause := c.poll(dispatch); pause != 0 || dispatched != 1 {
+		t.Errorf("expected the next poll right after the message was dispatched, got %s and %d dispatched", pause, dispatched)
+	}
+
+	if m.receives != 8 {
+		t.Errorf("expected a receive for every poll, got %d", m.receives)
+	}
+}
+
+func TestStaleMessages(t *testing.T) {
+	m := newMockSQS()
+	var stale []string
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, maxMessageAge: time.Minute, onStale: func(msg Message) {
+		stale = append(stale, msg.Route())
+	}}
+
+	old := m.add("queue", "old_event", "{}")
+	old.Attributes[sqs.MessageSystemAttributeNameSentTimestamp] = aws.String(strconv.FormatInt(time.Now().Add(-time.Hour).UnixNano()/int64(time.Millisecond), 10))
+	fresh := m.add("queue", "fresh_event", "{}")
+	fresh.Attributes[sqs.MessageSystemAttributeNameSentTimestamp] = aws.String(strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+
+	var dispatched []string
+	c.poll(func(msg *message) { dispatched = append(dispatched, msg.Route()) })
+
+	if !reflect.DeepEqual(dispatched, []string{"fresh_event"}) {
+		t.Errorf("expected only the fresh message to be dispatched, got %v", dispatched)
+	}
+
+	if !reflect.DeepEqual(stale, []string{"old_event"}) {
+		t.Errorf("expected the old message to be reported as stale, got %v", stale)
+	}
+
+	if !reflect.DeepEqual(m.deleted, []string{*old.ReceiptHandle}) {
+		t.Errorf("expected the old message to be deleted, got %v", m.deleted)
+	}
+}
+
+func TestSlowHandler(t *testing.T) {
+	m := newMockSQS()
+	l := &testLogger{}
+	var metrics []MessageMetrics
+	c := &consumer{sqs: m, QueueURL: "queue", logger: l, VisibilityTimeout: 30, extensionLimit: 2, slowHandler: time.Millisecond,
+		metricsHook: func(mm MessageMetrics) { metrics = append(metrics, mm) },
+		handlers: map[string]Handler{
+			"slow_event": func(ctx context.Context, m Message) error { time.Sleep(5 * time.Millisecond); return nil },
+			"fast_event": func(ctx context.Context, m Message) error { return nil },
+		}}
+
+	for _, route := range []string{"slow_event", "fast_event"} {
+		m.add("queue", route, "{}")
+	}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 handler runs to be reported, got %d", len(metrics))
+	}
+
+	if !metrics[0].Slow || metrics[0].Route != "slow_event" || metrics[0].MessageID != "1" || metrics[0].Duration < 5*time.Millisecond {
+		t.Errorf("unexpected metrics for the slow handler, got %+v", metrics[0])
+	}
+
+	if metrics[1].Slow {
+		t.Errorf("expected the fast handler not to be slow, got %+v", metrics[1])
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lines) != 1 || l.lines[0][0] != ErrSlowHandler.Error() {
+		t.Errorf("expected a single slow handler warning, got %v", l.lines)
+	}
+}
+
+func TestLatencyMetrics(t *testing.T) {
+	m := newMockSQS()
+	var metrics []MessageMetrics
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		metricsHook: func(mm MessageMetrics) { metrics = append(metrics, mm) },
+		handlers: map[string]Handler{
+			"post_created": func(ctx context.Context, m Message) error { return nil },
+		}}
+
+	epoch := func(t time.Time) *string {
+		return aws.String(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))
+	}
+
+	now := time.Now()
+	msg := m.add("queue", "post_created", "{}")
+	msg.Attributes[sqs.MessageSystemAttributeNameSentTimestamp] = epoch(now.Add(-3 * time.Minute))
+	msg.Attributes[sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp] = epoch(now.Add(-time.Minute))
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(metrics) != 1 {
+		t.Fatalf("expected 1 handler run to be reported, got %d", len(metrics))
+	}
+
+	if d := metrics[0].QueueWait; d < 2*time.Minute-time.Second || d > 2*time.Minute+time.Second {
+		t.Errorf("expected a queue wait of about 2m, got %s", d)
+	}
+
+	if d := metrics[0].RedeliveryDelay; d < time.Minute-time.Second || d > time.Minute+time.Second {
+		t.Errorf("expected a redelivery delay of about 1m, got %s", d)
+	}
+}
@@ -0,0 +1,63 @@
+package gosqs
+
+import (
+	"context"
+)
+
+// defaultCorrelationAttribute is the attribute holding the correlation ID when Config.CorrelationAttribute is not set
+const defaultCorrelationAttribute = "correlationId"
+
+type correlationKey struct{}
+
+// correlationAttribute returns the configured attribute name or the default
+func correlationAttribute(name string) string {
+	if name == "" {
+		return defaultCorrelationAttribute
+	}
+
+	return name
+}
+
+// WithCorrelationID sets the correlation ID of a message, it is sent as a String attribute named by Config.CorrelationAttribute
+// and overrides a custom attribute with the same title
+func WithCorrelationID(id string) PublishOption {
+	return func(o *publishOptions) {
+		o.correlationID = id
+	}
+}
+
+// ContextWithCorrelationID returns a copy of the context carrying the correlation ID
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID of the message a handler is processing, or an empty string. Messages sent
+// with the consumer's Message and MessageSelf carry it along automatically
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// correlationAttributes returns the correlation attribute for the ID, or nil if there is none
+func correlationAttributes(name, id string) []customAttribute {
+	if id == "" {
+		return nil
+	}
+
+	return []customAttribute{{correlationAttribute(name), DataTypeString.String(), id}}
+}
+
+// CorrelationID returns the correlation ID the message was sent with, or an empty string
+func (m *message) CorrelationID() string {
+	return m.correlationID
+}
+
+// logMessage logs a line about a message, followed by its correlation ID when it was sent with one so the consumer's
+// log output can be matched with the logs of the other services handling the message
+func (c *consumer) logMessage(m *message, v ...interface{}) {
+	if id := m.CorrelationID(); id != "" {
+		v = append(v, "correlation id", id)
+	}
+
+	c.Logger().Println(v...)
+}
@@ -0,0 +1,73 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestCorrelationID(t *testing.T) {
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/", correlationAttribute: "requestId"}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", &sample{}, WithCorrelationID("abc")); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if got := *m.sent[0].MessageAttributes["requestId"].StringValue; got != "abc" {
+		t.Fatalf("expected the correlation attribute to be sent, got %s", got)
+	}
+
+	var fromCtx string
+	c := &consumer{sqs: m, QueueURL: "http://localhost:4100/dev-post-worker", logger: &testLogger{}, extensionLimit: 2, VisibilityTimeout: 30,
+		correlationAttribute: "requestId",
+		handlers: map[string]Handler{
+			"post_created": func(ctx context.Context, msg Message) error {
+				if msg.CorrelationID() != "abc" {
+					t.Errorf("unexpected correlation id on the message, got %q", msg.CorrelationID())
+				}
+				fromCtx = CorrelationIDFromContext(ctx)
+				return nil
+			},
+		}}
+
+	c.poll(func(msg *message) { c.run(msg) })
+	if fromCtx != "abc" {
+		t.Errorf("expected the correlation id in the handler context, got %q", fromCtx)
+	}
+
+	attrs := c.attributesFor(ContextWithCorrelationID(context.TODO(), "abc"), "post-worker")
+	if len(attrs) != 1 || attrs[0].Title != "requestId" || attrs[0].Value != "abc" {
+		t.Errorf("expected messages sent from a handler to carry the correlation id, got %+v", attrs)
+	}
+}
+
+func TestCorrelationIDLogged(t *testing.T) {
+	m := newMockSQS()
+	logger := &testLogger{}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: logger, extensionLimit: 2, VisibilityTimeout: 30}
+	c.RegisterHandler("post_created", func(ctx context.Context, msg Message) error { return errors.New("downstream unavailable") })
+
+	for _, route := range []string{"", "post_created"} {
+		msg := m.add("queue", route, "{}")
+		msg.MessageAttributes[defaultCorrelationAttribute] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("abc")}
+	}
+
+	messages := make(chan *message, 2)
+	c.poll(func(msg *message) { messages <- msg })
+	close(messages)
+	c.worker(0, messages)
+
+	if len(logger.lines) != 2 {
+		t.Fatalf("expected the missing route and the handler failure to be logged, got %v", logger.lines)
+	}
+
+	for _, line := range logger.lines {
+		if n := len(line); n < 2 || line[n-2] != "correlation id" || line[n-1] != "abc" {
+			t.Errorf("expected the correlation id in the log line, got %v", line)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// attributes describing where a dead-lettered message came from and why, they are removed again by RedriveDLQ
+const (
+	deadLetterSourceAttribute    = "dead-letter-source-queue"
+	deadLetterReasonAttribute    = "dead-letter-reason"
+	deadLetterTimestampAttribute = "dead-letter-timestamp"
+)
+
+// maxMessageAttributes is the largest number of message attributes SQS accepts
+const maxMessageAttributes = 10
+
+// maxDeadLetterReason bounds the length of the dead-letter-reason attribute
+const maxDeadLetterReason = 256
+
+// deadLetterAttributes returns the attributes of a message sent to a dead letter queue, the original attributes along with
+// the source queue, the reason and the time. Metadata that does not fit within the attribute limit is left out, in that
+// order of precedence, so the original attributes are always preserved
+func deadLetterAttributes(original map[string]*sqs.MessageAttributeValue, source, reason string, now time.Time) map[string]*sqs.MessageAttributeValue {
+	attrs := make(map[string]*sqs.MessageAttributeValue, len(original)+3)
+	for k, v := range original {
+		attrs[k] = v
+	}
+
+	if len(reason) > maxDeadLetterReason {
+		reason = reason[:maxDeadLetterReason]
+	}
+
+	metadata := []customAttribute{
+		{deadLetterSourceAttribute, DataTypeString.String(), source},
+		{deadLetterReasonAttribute, DataTypeString.String(), reason},
+		{deadLetterTimestampAttribute, DataTypeString.String(), now.UTC().Format(time.RFC3339)},
+	}
+	for _, attr := range metadata {
+		if len(attrs) >= maxMessageAttributes {
+			break
+		}
+		attrs[attr.Title] = &sqs.MessageAttributeValue{DataType: aws.String(attr.DataType), StringValue: aws.String(attr.Value)}
+	}
+
+	return attrs
+}
+
+// stripDeadLetterAttributes returns the attributes without the dead letter metadata
+func stripDeadLetterAttributes(attrs map[string]*sqs.MessageAttributeValue) map[string]*sqs.MessageAttributeValue {
+	out := make(map[string]*sqs.MessageAttributeValue, len(attrs))
+	for k, v := range attrs {
+		switch k {
+		case deadLetterSourceAttribute, deadLetterReasonAttribute, deadLetterTimestampAttribute:
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// deadLetterError carries the dead letter queue of the handler that returned the error, see WithDeadLetterQueue
+type deadLetterError struct {
+	queueURL string
+	err      error
+}
+
+// Error is used for implementing the error interface
+func (e *deadLetterError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the handler
+func (e *deadLetterError) Unwrap() error {
+	return e.err
+}
+
+// WithDeadLetterQueue is an adapter that routes the failed messages of a handler to a dead letter queue of its own, e.g.
+// one owned by the team of the message type. A message that fails on its last receive before the queue's RedrivePolicy
+// would move it, Config.MaxReceiveCount (default 5), is sent to queueURL and deleted instead. DecodeErrorDeadLetter moves
+// its messages to queueURL as well. Messages of handlers without it fall back to the queue's redrive policy and
+// Config.DeadLetterQueueURL. It relies on ApproximateReceiveCount, which MinimalReceive does not request by itself, and
+// does not apply to AtomicBatches
+//
+//	consumer.RegisterHandler("invoice_created", handler, gosqs.WithDeadLetterQueue(billingDLQ))
+func WithDeadLetterQueue(queueURL string) Adapter {
+	return func(fn Handler) Handler {
+		return func(ctx context.Context, m Message) error {
+			if err := fn(ctx, m); err != nil {
+				return &deadLetterError{queueURL: queueURL, err: err}
+			}
+			return nil
+		}
+	}
+}
+
+// deadLetterQueue returns the dead letter queue for a handler error, the handler's own queue or the global one
+func (c *consumer) deadLetterQueue(err error) string {
+	var dlq *deadLetterError
+	if errors.As(err, &dlq) {
+		return dlq.queueURL
+	}
+
+	return c.deadLetterQueueURL
+}
+
+// exhausted moves a message to the dead letter queue of its handler once it failed on its last receive, it reports
+// whether the message was moved and can be deleted
+func (c *consumer) exhausted(ctx context.Context, m *message, err error) bool {
+	var dlq *deadLetterError
+	if !errors.As(err, &dlq) {
+		return false
+	}
+
+	max := c.maxReceiveCount
+	if max <= 0 {
+		max = defaultMaxReceiveCount
+	}
+	if m.receiveCount() < max {
+		return false
+	}
+
+	return c.sendDeadLetter(ctx, m, dlq.queueURL, err)
+}
+
+// sendDeadLetter sends a copy of the message with the dead letter metadata to the queue
+func (c *consumer) sendDeadLetter(ctx context.Context, m *message, queueURL string, reason error) bool {
+	if _, err := c.sqs.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		MessageBody:       m.Message.Body,
+		MessageAttributes: deadLetterAttributes(m.Message.MessageAttributes, c.QueueURL, reason.Error(), time.Now()),
+		QueueUrl:          &queueURL,
+	}); err != nil {
+		c.logMessage(m, ErrPublish.Context(err).Error(), aws.StringValue(m.MessageId))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,108 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestDeadLetterMetadata(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		decodeErrorAction: DecodeErrorDeadLetter, deadLetterQueueURL: "dlq",
+		handlers: map[string]Handler{
+			"post_created": func(ctx context.Context, msg Message) error {
+				var s sample
+				return msg.Decode(&s)
+			},
+		}}
+
+	m.add("queue", "post_created", "not json")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	dead := newMessage(m.queues["dlq"][0])
+	if dead.Attribute(deadLetterSourceAttribute) != "queue" || dead.Attribute(deadLetterReasonAttribute) == "" || dead.Route() != "post_created" {
+		t.Fatalf("expected the dead letter metadata along with the original attributes, got %v", dead.MessageAttributes)
+	}
+	if _, err := time.Parse(time.RFC3339, dead.Attribute(deadLetterTimestampAttribute)); err != nil {
+		t.Errorf("expected the dead letter timestamp, got %v", err)
+	}
+
+	if _, err := c.RedriveDLQ(context.TODO(), "dlq"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	redriven := newMessage(m.queues["queue"][0])
+	if _, _, ok := redriven.AttributeRaw(deadLetterSourceAttribute); ok || redriven.Route() != "post_created" {
+		t.Errorf("expected the dead letter metadata to be stripped, got %v", redriven.MessageAttributes)
+	}
+}
+
+func TestDeadLetterAttributesLimit(t *testing.T) {
+	original := map[string]*sqs.MessageAttributeValue{}
+	for i := 0; i < 8; i++ {
+		original["attr-"+strconv.Itoa(i)] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("v")}
+	}
+
+	attrs := deadLetterAttributes(original, "queue", "failed", time.Now())
+	if len(attrs) != maxMessageAttributes {
+		t.Fatalf("expected the attributes to stay within the limit, got %d", len(attrs))
+	}
+
+	if _, ok := attrs[deadLetterTimestampAttribute]; ok {
+		t.Error("expected the timestamp to be left out first")
+	}
+}
+
+func TestWithDeadLetterQueue(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, maxReceiveCount: 3}
+	failed := func(ctx context.Context, msg Message) error { return errors.New("billing down") }
+	c.RegisterHandler("invoice_created", failed, WithDeadLetterQueue("billing-dlq"))
+	c.RegisterHandler("post_created", failed)
+
+	for _, route := range []string{"invoice_created", "invoice_created", "post_created"} {
+		m.add("queue", route, "{}")
+	}
+	m.queues["queue"][0].Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("3")
+	m.queues["queue"][1].Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("2")
+	m.queues["queue"][2].Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("3")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(m.sent) != 1 || *m.sent[0].QueueUrl != "billing-dlq" || *m.sent[0].MessageAttributes[deadLetterReasonAttribute].StringValue != "billing down" {
+		t.Fatalf("expected only the exhausted invoice to be dead-lettered, got %v", m.sent)
+	}
+
+	if len(m.deleted) != 1 || m.deleted[0] != "receipt-1" {
+		t.Errorf("expected the dead-lettered message to be deleted, got %v", m.deleted)
+	}
+}
+
+func TestWithDeadLetterQueueDecodeError(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		decodeErrorAction: DecodeErrorDeadLetter, deadLetterQueueURL: "dlq"}
+	decode := func(ctx context.Context, msg Message) error {
+		var s sample
+		return msg.Decode(&s)
+	}
+	c.RegisterHandler("invoice_created", decode, WithDeadLetterQueue("billing-dlq"))
+	c.RegisterHandler("post_created", decode)
+
+	m.add("queue", "invoice_created", "not json")
+	m.add("queue", "post_created", "not json")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	targets := map[string]bool{}
+	for _, in := range m.sent {
+		targets[*in.QueueUrl] = true
+	}
+	if len(m.sent) != 2 || !targets["billing-dlq"] || !targets["dlq"] {
+		t.Errorf("expected the per type and the global dead letter queue, got %v", targets)
+	}
+}
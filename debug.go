@@ -0,0 +1,93 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	// maxPeekReceives bounds the receives of PeekMessage, so it inspects at most 100 messages
+	maxPeekReceives = 10
+	// peekVisibilityTimeout keeps peeked messages from being received twice during a search, they are released right after
+	peekVisibilityTimeout = 30
+)
+
+// prepare turns a received message into the message handed to the handlers, the reverse of what the publisher did to it.
+// An offloaded body is only downloaded once it is needed, see loadBody, so S3 requests do not hold up the receive loop
+func (c *consumer) prepare(m *sqs.Message) *message {
+	msg := newMessage(m)
+	msg.correlationID = msg.Attribute(correlationAttribute(c.correlationAttribute))
+	if msg.Attribute(offloadSizeAttribute) != "" {
+		msg.load = func(ctx context.Context) {
+			msg.download(ctx, c.s3)
+			c.unpack(msg)
+		}
+		return msg
+	}
+
+	c.unpack(msg)
+	return msg
+}
+
+// unpack decompresses the body and restores the attributes that overflowed into it
+func (c *consumer) unpack(msg *message) {
+	msg.decompress()
+	msg.mergeOverflow()
+	if ct := msg.Attribute(contentTypeAttribute); ct != "" {
+		msg.contentType, msg.codec = ct, c.codecs[ct]
+	}
+}
+
+// PeekMessage fetches a message of the consumer's queue without processing it, e.g. to reproduce a handler bug with
+// ProcessOne. It looks for the message with the SQS or SNS message ID, an empty ID returns the first message received.
+// Every message received while searching is made visible again before it returns, they are invisible to the live
+// consumers for the duration of the search and their receive count goes up, which counts towards the redrive policy.
+// ErrMessageNotFound is returned if the message is not among the first 100 received
+func (c *consumer) PeekMessage(ctx context.Context, messageID string) (Message, error) {
+	var seen []*string
+	defer func() { c.releaseMessages(c.QueueURL, seen) }()
+
+	for i := 0; i < maxPeekReceives; i++ {
+		input := c.receiveInput()
+		input.VisibilityTimeout = aws.Int64(peekVisibilityTimeout)
+		output, err := c.sqs.ReceiveMessageWithContext(ctx, input)
+		if err != nil {
+			return nil, ErrGetMessage.Context(err)
+		}
+
+		if len(output.Messages) == 0 {
+			break
+		}
+
+		for _, m := range output.Messages {
+			seen = append(seen, m.ReceiptHandle)
+			msg := c.prepare(m)
+			if messageID == "" || messageID == aws.StringValue(m.MessageId) || messageID == msg.dedupID() {
+				c.resolveRoute(msg)
+				return msg, nil
+			}
+		}
+	}
+
+	return nil, ErrMessageNotFound.Context(fmt.Errorf("message id %q", messageID))
+}
+
+// ProcessOne runs the handler of the message's route the way the consumer would, e.g. for a message fetched with
+// PeekMessage or a sqstesting.StubMessage. It only calls the handler, the message is not deleted, its visibility is not
+// extended and neither the stats nor the hooks of the live consumer are affected. It returns ErrNoRoute without a
+// registered handler and the error of the handler otherwise
+func (c *consumer) ProcessOne(ctx context.Context, m Message) error {
+	h, ok := c.handlers[m.Route()]
+	if !ok {
+		return ErrNoRoute.Context(fmt.Errorf("no handler for %q", m.Route()))
+	}
+
+	if id := m.CorrelationID(); id != "" {
+		ctx = ContextWithCorrelationID(ctx, id)
+	}
+
+	return h(ctx, m)
+}
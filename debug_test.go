@@ -0,0 +1,72 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPeekMessage(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, *m.add("queue", "post_created", `{"val":"post"}`).MessageId)
+	}
+
+	msg, err := c.PeekMessage(context.TODO(), ids[1])
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if msg.Route() != "post_created" || *msg.(*message).MessageId != ids[1] {
+		t.Errorf("expected the message with the id, got %s", *msg.(*message).MessageId)
+	}
+
+	if len(m.visibilities) != 2 || len(m.deleted) != 0 {
+		t.Errorf("expected the received messages to be released, got %v and %d deletes", m.visibilities, len(m.deleted))
+	}
+
+	for _, v := range m.visibilities {
+		if v != 0 {
+			t.Errorf("expected the messages to be visible right away, got %d", v)
+		}
+	}
+
+	if _, err := c.PeekMessage(context.TODO(), "missing"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("expected %v, got %v", ErrMessageNotFound, err)
+	}
+}
+
+func TestProcessOne(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+
+	var got sample
+	failed := errors.New("handler bug")
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error {
+		if err := m.Decode(&got); err != nil {
+			return err
+		}
+		return failed
+	})
+
+	m.add("queue", "post_created", `{"val":"post"}`)
+	msg, err := c.PeekMessage(context.TODO(), "")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if err := c.ProcessOne(context.TODO(), msg); !errors.Is(err, failed) {
+		t.Errorf("expected the error of the handler, got %v", err)
+	}
+
+	if got.Val != "post" || len(m.deleted) != 0 || c.Stats().Failed != 0 {
+		t.Errorf("expected the handler to run without affecting the consumer, got %+v, %d deletes, %+v", got, len(m.deleted), c.Stats())
+	}
+
+	if err := c.ProcessOne(context.TODO(), newMessage(m.add("queue", "post_deleted", "{}"))); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected %v without a handler, got %v", ErrNoRoute, err)
+	}
+}
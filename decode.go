@@ -0,0 +1,69 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DecodeErrorAction determines what happens to a message whose handler returned a decode error
+type DecodeErrorAction int
+
+const (
+	// DecodeErrorRedeliver leaves the message in the queue like any other failure, it is redelivered until the queue's
+	// redrive policy moves it to the DLQ
+	DecodeErrorRedeliver DecodeErrorAction = iota
+	// DecodeErrorDrop deletes the message
+	DecodeErrorDrop
+	// DecodeErrorDeadLetter moves the message to Config.DeadLetterQueueURL right away, or to the queue of the handler's
+	// WithDeadLetterQueue
+	DecodeErrorDeadLetter
+)
+
+// decodeErr wraps a decoding failure so the consumer can tell it apart from other handler errors
+func decodeErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return ErrDecode.Context(err)
+}
+
+// decodeFailed applies the decode error action when the handler failed to decode the message, it reports whether the
+// message was removed from the queue
+func (c *consumer) decodeFailed(ctx context.Context, m *message, err error) bool {
+	if !errors.Is(err, ErrDecode) {
+		return false
+	}
+
+	if c.onDecodeError != nil {
+		c.onDecodeError(m, err)
+	}
+
+	switch c.decodeErrorAction {
+	case DecodeErrorDrop:
+		return true
+	case DecodeErrorDeadLetter:
+		return c.sendDeadLetter(ctx, m, c.deadLetterQueue(err), err)
+	}
+
+	return false
+}
+
+// resolveDeadLetterQueue looks up the url of Config.DeadLetterQueue for DecodeErrorDeadLetter
+func (c *consumer) resolveDeadLetterQueue(conf Config) error {
+	if conf.OnDecodeErrorAction != DecodeErrorDeadLetter || c.deadLetterQueueURL != "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s-%s", conf.Env, conf.DeadLetterQueue)
+	o, err := c.sqs.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: &name})
+	if err != nil {
+		return ErrQueueURL.Context(err)
+	}
+
+	c.deadLetterQueueURL = *o.QueueUrl
+	return nil
+}
@@ -0,0 +1,54 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDecodeErrorAction(t *testing.T) {
+	run := func(action DecodeErrorAction) (*mockSQS, []error) {
+		m := newMockSQS()
+		var reported []error
+		c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+			decodeErrorAction: action, deadLetterQueueURL: "dlq",
+			onDecodeError: func(msg Message, err error) { reported = append(reported, err) },
+			handlers: map[string]Handler{
+				"post_created": func(ctx context.Context, msg Message) error {
+					var s sample
+					return msg.Decode(&s)
+				},
+				"failed": func(ctx context.Context, msg Message) error { return errors.New("failed") },
+			}}
+
+		m.add("queue", "post_created", "not json")
+		m.add("queue", "failed", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+		return m, reported
+	}
+
+	t.Run("redeliver", func(t *testing.T) {
+		m, reported := run(DecodeErrorRedeliver)
+		if len(m.deleted) != 0 || len(reported) != 1 || !errors.Is(reported[0], ErrDecode) {
+			t.Errorf("expected the message to stay in the queue, got %d deleted and %v reported", len(m.deleted), reported)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		m, _ := run(DecodeErrorDrop)
+		if len(m.deleted) != 1 || m.deleted[0] != "receipt-1" {
+			t.Errorf("expected only the malformed message to be deleted, got %v", m.deleted)
+		}
+	})
+
+	t.Run("dead_letter", func(t *testing.T) {
+		m, _ := run(DecodeErrorDeadLetter)
+		if len(m.deleted) != 1 || len(m.queues["dlq"]) != 1 || *m.queues["dlq"][0].Body != "not json" {
+			t.Errorf("expected the malformed message to be moved to the dlq, got %d deleted and %d in the dlq", len(m.deleted), len(m.queues["dlq"]))
+		}
+	})
+
+	if err := (Config{OnDecodeErrorAction: DecodeErrorDeadLetter}).Validate(); err == nil {
+		t.Error("expected DecodeErrorDeadLetter without a dead letter queue to be rejected")
+	}
+}
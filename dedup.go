@@ -0,0 +1,129 @@
+package gosqs
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// maxDedupEntries bounds the number of message IDs remembered by the in-memory dedup, the oldest are evicted first
+const maxDedupEntries = 10000
+
+// dedupEntry is a remembered message ID, done is false while the message is being processed
+type dedupEntry struct {
+	id   string
+	seen time.Time
+	done bool
+}
+
+// dedup remembers the IDs of recently processed messages so duplicate deliveries within the window can be skipped.
+// It only protects a single consumer process. A nil dedup is disabled
+type dedup struct {
+	mu sync.Mutex
+
+	window  time.Duration
+	max     int
+	now     func() time.Time
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// newDedup creates an in-memory dedup from the config, it returns nil if the dedup is disabled
+func newDedup(c Config) *dedup {
+	if c.InMemoryDedupWindow <= 0 {
+		return nil
+	}
+
+	return &dedup{
+		window:  c.InMemoryDedupWindow,
+		max:     maxDedupEntries,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// claim reports whether the message should be processed, it returns false if the ID is being processed or was processed
+// within the window
+func (d *dedup) claim(id string) bool {
+	if d == nil || id == "" {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.expire(now)
+
+	if _, ok := d.entries[id]; ok {
+		return false
+	}
+
+	d.entries[id] = d.order.PushBack(&dedupEntry{id: id, seen: now})
+	for d.order.Len() > d.max {
+		d.remove(d.order.Front())
+	}
+
+	return true
+}
+
+// release completes a claim. A processed ID is remembered for the window, a failed one is forgotten so the redelivery
+// of the message is processed again
+func (d *dedup) release(id string, processed bool) {
+	if d == nil || id == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, ok := d.entries[id]
+	if !ok {
+		return
+	}
+
+	if !processed {
+		d.remove(el)
+		return
+	}
+
+	e := el.Value.(*dedupEntry)
+	e.seen = d.now()
+	e.done = true
+	d.order.MoveToBack(el)
+}
+
+// expire removes the processed IDs that are older than the window, the lock must be held
+func (d *dedup) expire(now time.Time) {
+	for el := d.order.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*dedupEntry)
+		if now.Sub(e.seen) < d.window {
+			break
+		}
+		if e.done {
+			d.remove(el)
+		}
+		el = next
+	}
+}
+
+// remove forgets an ID, the lock must be held
+func (d *dedup) remove(el *list.Element) {
+	d.order.Remove(el)
+	delete(d.entries, el.Value.(*dedupEntry).id)
+}
+
+// dedupID returns the ID duplicates are detected by, SNS deliveries use the SNS message ID as every delivery is a
+// separate SQS message
+func (m *message) dedupID() string {
+	if m.envelope != nil && m.envelope.MessageId != "" {
+		return m.envelope.MessageId
+	}
+
+	if m.MessageId == nil {
+		return ""
+	}
+	return *m.MessageId
+}
@@ -0,0 +1,101 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInMemoryDedup(t *testing.T) {
+	notification := `{"Type":"Notification","MessageId":"sns-1","TopicArn":"arn:aws:sns:local:000000000000:dev-post-worker","Message":"{}"}`
+
+	t.Run("skips_sns_duplicates", func(t *testing.T) {
+		m := newMockSQS()
+		var calls int
+		c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+			dedup: newDedup(Config{InMemoryDedupWindow: time.Minute}),
+			handlers: map[string]Handler{
+				"post_published": func(ctx context.Context, msg Message) error { calls++; return nil },
+			}}
+
+		m.add("queue", "post_published", notification)
+		m.add("queue", "post_published", notification)
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if calls != 1 {
+			t.Errorf("expected the handler to be called once, got %d", calls)
+		}
+
+		if len(m.deleted) != 2 {
+			t.Errorf("expected both deliveries to be deleted, got %v", m.deleted)
+		}
+	})
+
+	t.Run("processes_redelivery_after_failure", func(t *testing.T) {
+		m := newMockSQS()
+		var calls int
+		c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+			dedup: newDedup(Config{InMemoryDedupWindow: time.Minute}),
+			handlers: map[string]Handler{
+				"post_published": func(ctx context.Context, msg Message) error {
+					calls++
+					if calls == 1 {
+						return errors.New("failed")
+					}
+					return nil
+				},
+			}}
+
+		msg := m.add("queue", "post_published", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+		m.queues["queue"] = append(m.queues["queue"], msg)
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if calls != 2 {
+			t.Errorf("expected the redelivery to be processed, got %d calls", calls)
+		}
+	})
+
+	t.Run("expires_after_window", func(t *testing.T) {
+		now := time.Now()
+		d := newDedup(Config{InMemoryDedupWindow: time.Minute})
+		d.now = func() time.Time { return now }
+
+		if !d.claim("1") {
+			t.Fatal("expected the first delivery to be claimed")
+		}
+
+		if d.claim("1") {
+			t.Fatal("expected a delivery in progress to be a duplicate")
+		}
+
+		d.release("1", true)
+		if d.claim("1") {
+			t.Fatal("expected a processed delivery to be a duplicate")
+		}
+
+		now = now.Add(time.Minute)
+		if !d.claim("1") {
+			t.Fatal("expected the ID to be forgotten after the window")
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		d := newDedup(Config{InMemoryDedupWindow: time.Minute})
+		d.max = 2
+
+		for _, id := range []string{"1", "2", "3"} {
+			d.claim(id)
+			d.release(id, true)
+		}
+
+		if !d.claim("1") {
+			t.Error("expected the oldest ID to be evicted")
+		}
+
+		if d.claim("3") {
+			t.Error("expected the newest ID to be remembered")
+		}
+	})
+}
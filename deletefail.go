@@ -0,0 +1,56 @@
+package gosqs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// DeleteFailure describes a message that could not be deleted after it was processed, SQS redelivers it once its
+// visibility timeout expires and the handler runs again
+type DeleteFailure struct {
+	// Route is the event the message was routed with, e.g. post_created
+	Route string
+	// MessageID is the SQS message ID
+	MessageID string
+	// Err is the error of the delete
+	Err error
+	// PartialBatch is true when the batch delete of an atomic batch succeeded but this entry failed, Code then holds the
+	// SQS failure code of the entry, e.g. ReceiptHandleIsInvalid
+	PartialBatch bool
+	Code         string
+}
+
+// DeleteFailureHookFunc receives every failed delete. It is called from the worker goroutines and must be safe for
+// concurrent use
+type DeleteFailureHookFunc func(DeleteFailure)
+
+// deleteBatchRetries is how often the failed entries of a batch delete are retried, the SDK retryer only retries a batch
+// request that fails as a whole
+const deleteBatchRetries = 3
+
+// deleteFailed logs a failed delete and reports it to the OnDeleteFailure hook
+func (c *consumer) deleteFailed(m *message, f DeleteFailure) {
+	f.Route = m.Route()
+	f.MessageID = aws.StringValue(m.MessageId)
+	if f.PartialBatch {
+		c.Logger().Println(ErrUnableToDelete.Error(), f.MessageID, f.Code)
+	} else {
+		c.Logger().Println(f.Err.Error(), f.MessageID)
+	}
+
+	if c.onDeleteFailure != nil {
+		c.onDeleteFailure(f)
+	}
+}
+
+// batchRetryDelay returns the delay before retrying the failed entries of a batch delete
+func batchRetryDelay(attempt int) time.Duration {
+	return fullJitter(retryBaseDelay, retryMaxDelay, attempt)
+}
+
+// batchEntryErr is the error of a failed entry of a batch delete
+func batchEntryErr(code, message string) error {
+	return ErrUnableToDelete.Context(fmt.Errorf("%s: %s", code, message))
+}
@@ -0,0 +1,93 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// failingDeletes fails the deletes of the configured receipt handles
+type failingDeletes struct {
+	*mockSQS
+	mu sync.Mutex
+	// receipt handles whose single delete fails
+	fail map[string]bool
+	// receipt handles whose batch entry fails the given number of times, sender faults fail every time
+	batchFailures map[string]int
+	senderFault   map[string]bool
+	batchCalls    int
+}
+
+func (m *failingDeletes) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	if m.fail[*in.ReceiptHandle] {
+		return nil, errors.New("access denied")
+	}
+	return m.mockSQS.DeleteMessage(in)
+}
+
+func (m *failingDeletes) DeleteMessageBatch(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batchCalls++
+
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, e := range in.Entries {
+		if m.senderFault[*e.ReceiptHandle] {
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: e.Id, Code: aws.String("ReceiptHandleIsInvalid"), SenderFault: aws.Bool(true)})
+			continue
+		}
+		if m.batchFailures[*e.ReceiptHandle] > 0 {
+			m.batchFailures[*e.ReceiptHandle]--
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InternalError"), SenderFault: aws.Bool(false)})
+			continue
+		}
+		m.mockSQS.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: in.QueueUrl, ReceiptHandle: e.ReceiptHandle})
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: e.Id})
+	}
+	return out, nil
+}
+
+func TestOnDeleteFailure(t *testing.T) {
+	ok := func(ctx context.Context, msg Message) error { return nil }
+
+	t.Run("delete", func(t *testing.T) {
+		m := &failingDeletes{mockSQS: newMockSQS(), fail: map[string]bool{"receipt-1": true}}
+		var failures []DeleteFailure
+		c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+			onDeleteFailure: func(f DeleteFailure) { failures = append(failures, f) },
+			handlers:        map[string]Handler{"post_created": ok}}
+
+		m.add("queue", "post_created", "{}")
+		m.add("queue", "post_created", "{}")
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if len(failures) != 1 || failures[0].MessageID != "1" || failures[0].Route != "post_created" || failures[0].PartialBatch || !errors.Is(failures[0].Err, ErrUnableToDelete) {
+			t.Errorf("expected the failed delete to be reported, got %+v", failures)
+		}
+	})
+
+	t.Run("partial_batch", func(t *testing.T) {
+		m := &failingDeletes{mockSQS: newMockSQS(), batchFailures: map[string]int{"receipt-1": 1}, senderFault: map[string]bool{"receipt-2": true}}
+		var failures []DeleteFailure
+		c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, atomicBatches: true,
+			onDeleteFailure: func(f DeleteFailure) { failures = append(failures, f) },
+			handlers:        map[string]Handler{"post_created": ok}}
+
+		for i := 0; i < 3; i++ {
+			m.add("queue", "post_created", "{}")
+		}
+		c.poll(func(msg *message) { c.run(msg) })
+
+		if len(failures) != 1 || failures[0].MessageID != "2" || !failures[0].PartialBatch || failures[0].Code != "ReceiptHandleIsInvalid" {
+			t.Errorf("expected only the sender fault to be reported, got %+v", failures)
+		}
+
+		if m.batchCalls != 2 || len(m.deleted) != 2 {
+			t.Errorf("expected the internal error to be retried, got %d calls and %v deleted", m.batchCalls, m.deleted)
+		}
+	})
+}
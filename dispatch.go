@@ -0,0 +1,220 @@
+package gosqs
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DispatchStrategy determines how received messages are distributed across the worker pool
+type DispatchStrategy int
+
+const (
+	// DispatchDefault uses DispatchByGroup for FIFO queues and when Config.OrderingKey is set, DispatchAny otherwise
+	DispatchDefault DispatchStrategy = iota
+	// DispatchAny hands each message to the next idle worker, messages may be processed in any order
+	DispatchAny
+	// DispatchByGroup processes messages that share a MessageGroupId serially, preserving their order, while messages of
+	// different groups are processed in parallel. A group occupies at most one worker so a busy group cannot starve the others.
+	// This is best-effort fairness among the received messages, SQS decides which groups are delivered.
+	// Messages without a group are handed to any idle worker
+	DispatchByGroup
+)
+
+// dispatchStrategy resolves the default strategy for the consumer's queue
+func (c *consumer) dispatchStrategy() DispatchStrategy {
+	if c.strategy != DispatchDefault {
+		return c.strategy
+	}
+
+	if strings.HasSuffix(c.QueueURL, ".fifo") || c.orderingKey != nil {
+		return DispatchByGroup
+	}
+
+	return DispatchAny
+}
+
+// startWorkers starts the worker pool and returns the function used to hand received messages to the workers
+func (c *consumer) startWorkers() func(*message) {
+	// a single worker runs in the receive loop so a batch is processed in arrival order before the next receive
+	if c.workerPool == 1 {
+		return func(m *message) {
+			if err := c.run(m); err != nil {
+				c.logMessage(m, err.Error())
+			}
+		}
+	}
+
+	if c.dispatchStrategy() != DispatchByGroup {
+		jobs := make(chan *message)
+		for w := 1; w <= c.workerPool; w++ {
+			go c.worker(w, jobs)
+		}
+
+		return func(m *message) {
+			jobs <- m
+		}
+	}
+
+	// a group has at most one message with a worker at a time, later messages of the group wait in arrival order without
+	// blocking the dispatch of other groups
+	jobs := make(chan *message)
+	groups := newGroupQueue(c.maxOrderingKeys)
+	for w := 1; w <= c.workerPool; w++ {
+		go c.groupWorker(w, jobs, groups)
+	}
+
+	warn := c.manyGroupsWarning()
+	return func(m *message) {
+		m.group = c.groupKey(m)
+		warn(m)
+		if groups.hold(m) {
+			return
+		}
+
+		jobs <- m
+	}
+}
+
+// manyGroups is the number of distinct message groups after which a FIFO queue that is not in high throughput mode
+// becomes the bottleneck, SQS then limits the throughput of the whole queue rather than of each group
+const manyGroups = 100
+
+// manyGroupsWarning returns a function that counts the distinct message groups received from a FIFO queue and logs a
+// single warning once there are manyGroups of them, unless Config.HighThroughputFIFO is set
+func (c *consumer) manyGroupsWarning() func(*message) {
+	if c.highThroughputFIFO || !isFIFO(c.QueueURL) {
+		return func(*message) {}
+	}
+
+	// the dispatch function is only called from the receive loop, the set needs no lock
+	seen := map[string]struct{}{}
+	return func(m *message) {
+		if seen == nil {
+			return
+		}
+
+		seen[m.groupID()] = struct{}{}
+		if len(seen) >= manyGroups {
+			seen = nil
+			c.Logger().Println("FIFO queue receives messages of many groups but HighThroughputFIFO is not set, throughput is limited per queue rather than per group", c.QueueURL)
+		}
+	}
+}
+
+// defaultMaxOrderingKeys is used when MaxOrderingKeys is not configured
+const defaultMaxOrderingKeys = 1000
+
+// groupQueue tracks the groups that have a message with a worker and holds back their later messages
+type groupQueue struct {
+	mu      sync.Mutex
+	pending map[string][]*message
+	// max bounds the active groups, released is signalled whenever a group finishes
+	max      int
+	released *sync.Cond
+}
+
+func newGroupQueue(max int) *groupQueue {
+	if max <= 0 {
+		max = defaultMaxOrderingKeys
+	}
+
+	q := &groupQueue{pending: map[string][]*message{}, max: max}
+	q.released = sync.NewCond(&q.mu)
+	return q
+}
+
+// hold queues the message if its group is already being processed and marks the group as active otherwise. Once the
+// maximum of active groups is reached it blocks until a group finishes, pausing the receive loop
+func (q *groupQueue) hold(m *message) bool {
+	key := m.group
+	if key == "" {
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if waiting, ok := q.pending[key]; ok {
+			q.pending[key] = append(waiting, m)
+			return true
+		}
+
+		if len(q.pending) < q.max {
+			break
+		}
+		q.released.Wait()
+	}
+
+	q.pending[key] = nil
+	return false
+}
+
+// next returns the next held message of the group, or nil once the group has no more messages and is no longer active
+func (q *groupQueue) next(key string) *message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	waiting := q.pending[key]
+	if len(waiting) == 0 {
+		delete(q.pending, key)
+		q.released.Broadcast()
+		return nil
+	}
+
+	q.pending[key] = waiting[1:]
+	return waiting[0]
+}
+
+// groupWorker processes a message and then every held message of its group before taking new work, keeping a busy group
+// on a single worker while the other workers serve the remaining groups
+func (c *consumer) groupWorker(id int, messages <-chan *message, groups *groupQueue) {
+	for m := range messages {
+		key := m.group
+		for m != nil {
+			if err := c.run(m); err != nil {
+				c.logMessage(m, err.Error())
+			}
+
+			if key == "" {
+				break
+			}
+			m = groups.next(key)
+		}
+	}
+}
+
+// groupKey returns the key messages are serialized by, the MessageGroupId unless an OrderingKey is configured
+func (c *consumer) groupKey(m *message) string {
+	if c.orderingKey != nil {
+		return c.orderingKey(m)
+	}
+
+	return m.groupID()
+}
+
+// groupID returns the MessageGroupId of messages received from a FIFO queue
+func (m *message) groupID() string {
+	if v, ok := m.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]; ok && v != nil {
+		return *v
+	}
+
+	return ""
+}
+
+// sortByPublishTime orders received messages by their PublishTime, or their SentTime when they were not delivered in an SNS
+// envelope. Messages with neither keep their relative order ahead of the others
+func sortByPublishTime(received []*message) {
+	at := func(m *message) time.Time {
+		if t := m.PublishTime(); !t.IsZero() {
+			return t
+		}
+		return m.SentTime()
+	}
+
+	sort.SliceStable(received, func(i, j int) bool { return at(received[i]).Before(at(received[j])) })
+}
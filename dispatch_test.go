@@ -0,0 +1,267 @@
+package gosqs
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestDispatchStrategy(t *testing.T) {
+	if s := (&consumer{QueueURL: "http://localhost:4100/dev-post-worker.fifo"}).dispatchStrategy(); s != DispatchByGroup {
+		t.Errorf("expected FIFO queues to dispatch by group, got %d", s)
+	}
+
+	if s := (&consumer{QueueURL: "http://localhost:4100/dev-post-worker"}).dispatchStrategy(); s != DispatchAny {
+		t.Errorf("expected standard queues to dispatch to any worker, got %d", s)
+	}
+}
+
+func TestDispatchByGroup(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue.fifo", workerPool: 4, logger: &testLogger{}}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	processed := map[string][]int{}
+	c.RegisterHandler("ordered", func(ctx context.Context, msg Message) error {
+		defer wg.Done()
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+
+		var seq int
+		msg.Decode(&seq)
+
+		mu.Lock()
+		processed[msg.Attribute("group")] = append(processed[msg.Attribute("group")], seq)
+		mu.Unlock()
+		return nil
+	})
+
+	dispatch := c.startWorkers()
+	total := 20
+	wg.Add(total * 2)
+	for i := 0; i < total; i++ {
+		for _, group := range []string{"a", "b"} {
+			raw := m.add("queue.fifo", "ordered", strconv.Itoa(i))
+			raw.Attributes[sqs.MessageSystemAttributeNameMessageGroupId] = aws.String(group)
+			raw.MessageAttributes["group"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(group)}
+			dispatch(newMessage(raw))
+		}
+	}
+	wg.Wait()
+
+	for _, group := range []string{"a", "b"} {
+		seqs := processed[group]
+		if len(seqs) != total {
+			t.Fatalf("expected %d messages for group %s, got %d", total, group, len(seqs))
+		}
+
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("group %s processed out of order, got %v", group, seqs)
+			}
+		}
+	}
+}
+
+func TestDispatchByGroupFairness(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue.fifo", workerPool: 2, logger: &testLogger{}}
+
+	release := make(chan struct{})
+	done := make(chan string, 20)
+	c.RegisterHandler("event", func(ctx context.Context, msg Message) error {
+		if msg.Attribute("group") == "hot" {
+			<-release
+		}
+		done <- msg.Attribute("group")
+		return nil
+	})
+
+	dispatch := c.startWorkers()
+	send := func(group string) {
+		raw := m.add("queue.fifo", "event", "{}")
+		raw.Attributes[sqs.MessageSystemAttributeNameMessageGroupId] = aws.String(group)
+		raw.MessageAttributes["group"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(group)}
+		dispatch(newMessage(raw))
+	}
+
+	// the hot group holds one worker, its later messages must not block the dispatch of the other group
+	for i := 0; i < 10; i++ {
+		send("hot")
+	}
+	send("cold")
+
+	select {
+	case g := <-done:
+		if g != "cold" {
+			t.Fatalf("expected the cold group to be processed first, got %s", g)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the cold group was starved by the hot group")
+	}
+
+	close(release)
+	for i := 0; i < 10; i++ {
+		if g := <-done; g != "hot" {
+			t.Fatalf("unexpected group %s", g)
+		}
+	}
+}
+
+func TestOrderingKey(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", workerPool: 4, logger: &testLogger{},
+		orderingKey: func(msg Message) string { return msg.Attribute("user") }}
+
+	if s := c.dispatchStrategy(); s != DispatchByGroup {
+		t.Fatalf("expected an ordering key to dispatch by group, got %d", s)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	processed := map[string][]int{}
+	c.RegisterHandler("ordered", func(ctx context.Context, msg Message) error {
+		defer wg.Done()
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+
+		var seq int
+		msg.Decode(&seq)
+
+		mu.Lock()
+		processed[msg.Attribute("user")] = append(processed[msg.Attribute("user")], seq)
+		mu.Unlock()
+		return nil
+	})
+
+	dispatch := c.startWorkers()
+	total := 20
+	wg.Add(total * 2)
+	for i := 0; i < total; i++ {
+		for _, user := range []string{"a", "b"} {
+			raw := m.add("queue", "ordered", strconv.Itoa(i))
+			raw.MessageAttributes["user"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(user)}
+			dispatch(newMessage(raw))
+		}
+	}
+	wg.Wait()
+
+	for _, user := range []string{"a", "b"} {
+		for i, seq := range processed[user] {
+			if seq != i {
+				t.Fatalf("user %s processed out of order, got %v", user, processed[user])
+			}
+		}
+	}
+}
+
+func TestMaxOrderingKeys(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", workerPool: 2, logger: &testLogger{}, maxOrderingKeys: 1,
+		orderingKey: func(msg Message) string { return msg.Attribute("user") }}
+
+	release := make(chan struct{})
+	done := make(chan string, 2)
+	c.RegisterHandler("event", func(ctx context.Context, msg Message) error {
+		if msg.Attribute("user") == "a" {
+			<-release
+		}
+		done <- msg.Attribute("user")
+		return nil
+	})
+
+	dispatch := c.startWorkers()
+	send := func(user string) {
+		raw := m.add("queue", "event", "{}")
+		raw.MessageAttributes["user"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(user)}
+		dispatch(newMessage(raw))
+	}
+
+	send("a")
+	dispatched := make(chan struct{})
+	go func() { send("b"); close(dispatched) }()
+
+	select {
+	case <-dispatched:
+		t.Fatal("expected the dispatch of a second key to wait for the first key")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-dispatched
+	if a, b := <-done, <-done; a != "a" || b != "b" {
+		t.Fatalf("unexpected processing order %s, %s", a, b)
+	}
+}
+
+func TestSingleWorker(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", workerPool: 1, logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+
+	var running int32
+	var processed []int
+	c.RegisterHandler("ordered", func(ctx context.Context, msg Message) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n != 1 {
+			t.Errorf("expected a single handler at a time, got %d", n)
+		}
+		time.Sleep(time.Duration(rand.Intn(2)) * time.Millisecond)
+
+		var seq int
+		msg.Decode(&seq)
+		processed = append(processed, seq)
+		return nil
+	})
+
+	total := 25
+	for i := 0; i < total; i++ {
+		m.add("queue", "ordered", strconv.Itoa(i))
+	}
+
+	dispatch := c.startWorkers()
+	for receives := 1; len(processed) < total; receives++ {
+		before := len(processed)
+		c.poll(dispatch)
+
+		// every message of a receive is processed before the next receive
+		if len(m.inflight) != 0 || len(processed) == before {
+			t.Fatalf("expected receive %d to be fully processed, got %d in flight", receives, len(m.inflight))
+		}
+	}
+
+	for i, seq := range processed {
+		if seq != i {
+			t.Fatalf("expected strict arrival order, got %v", processed)
+		}
+	}
+}
+
+func TestManyGroupsWarning(t *testing.T) {
+	m := newMockSQS()
+	for _, highThroughput := range []bool{false, true} {
+		logger := &testLogger{}
+		c := &consumer{sqs: m, QueueURL: "queue.fifo", logger: logger, highThroughputFIFO: highThroughput}
+
+		warn := c.manyGroupsWarning()
+		for i := 0; i < manyGroups*2; i++ {
+			raw := m.add("queue.fifo", "ordered", "1")
+			raw.Attributes[sqs.MessageSystemAttributeNameMessageGroupId] = aws.String(strconv.Itoa(i))
+			warn(newMessage(raw))
+		}
+
+		want := 1
+		if highThroughput {
+			want = 0
+		}
+		if len(logger.lines) != want {
+			t.Errorf("expected %d warnings with HighThroughputFIFO %v, got %v", want, highThroughput, logger.lines)
+		}
+	}
+}
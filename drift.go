@@ -0,0 +1,123 @@
+package gosqs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// QueueDrift is a queue attribute whose live value differs from the consumer's config
+type QueueDrift struct {
+	// name of the attribute, e.g. VisibilityTimeout or RedrivePolicy.maxReceiveCount
+	Attribute string
+	// value derived from the config
+	Configured string
+	// value set on the queue, empty if the attribute is not set
+	Live string
+}
+
+// String formats the drift for logging
+func (d QueueDrift) String() string {
+	return fmt.Sprintf("%s: configured %q, queue has %q", d.Attribute, d.Configured, d.Live)
+}
+
+// checkDrift compares the queue's VisibilityTimeout and RedrivePolicy, and the throughput attributes of a high
+// throughput FIFO queue, with the config and reports any difference to OnQueueDrift, or logs it when no hook is
+// configured. The queue is never updated
+func (c *consumer) checkDrift(conf Config) error {
+	highThroughput := conf.HighThroughputFIFO && isFIFO(c.QueueURL)
+	names := []*string{
+		aws.String(sqs.QueueAttributeNameVisibilityTimeout),
+		aws.String(sqs.QueueAttributeNameRedrivePolicy),
+	}
+	if highThroughput {
+		names = append(names, aws.String(deduplicationScopeAttribute), aws.String(fifoThroughputLimitAttribute))
+	}
+
+	o, err := c.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{QueueUrl: &c.QueueURL, AttributeNames: names})
+	if err != nil {
+		return ErrQueueAttributes.Context(err)
+	}
+
+	drifts := queueDrifts(conf, c.VisibilityTimeout, o.Attributes)
+	if highThroughput {
+		drifts = append(drifts, highThroughputDrifts(o.Attributes)...)
+	}
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	if conf.OnQueueDrift != nil {
+		conf.OnQueueDrift(drifts)
+		return nil
+	}
+
+	for _, d := range drifts {
+		c.Logger().Println("queue attribute drift", c.QueueURL, d.String())
+	}
+	return nil
+}
+
+// queueDrifts lists the attributes that differ between the config and the live queue. The RedrivePolicy is only
+// compared when a dead letter queue is configured
+func queueDrifts(conf Config, visibility int, attrs map[string]*string) []QueueDrift {
+	var drifts []QueueDrift
+
+	want := strconv.Itoa(visibility)
+	if got := aws.StringValue(attrs[sqs.QueueAttributeNameVisibilityTimeout]); got != want {
+		drifts = append(drifts, QueueDrift{Attribute: sqs.QueueAttributeNameVisibilityTimeout, Configured: want, Live: got})
+	}
+
+	if conf.DeadLetterQueueARN == "" && conf.DeadLetterQueue == "" {
+		return drifts
+	}
+
+	// SQS returns maxReceiveCount as a number while gosqs sets it as a string, fmt.Sprint formats both alike
+	var policy map[string]interface{}
+	if raw := aws.StringValue(attrs[sqs.QueueAttributeNameRedrivePolicy]); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &policy)
+	}
+	live := func(key string) string {
+		if v, ok := policy[key]; ok {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	target := live("deadLetterTargetArn")
+	name := fmt.Sprintf("%s-%s", conf.Env, conf.DeadLetterQueue)
+	switch {
+	case conf.DeadLetterQueueARN != "" && target != conf.DeadLetterQueueARN:
+		drifts = append(drifts, QueueDrift{Attribute: "RedrivePolicy.deadLetterTargetArn", Configured: conf.DeadLetterQueueARN, Live: target})
+	case conf.DeadLetterQueueARN == "" && !strings.HasSuffix(target, ":"+name):
+		drifts = append(drifts, QueueDrift{Attribute: "RedrivePolicy.deadLetterTargetArn", Configured: name, Live: target})
+	}
+
+	count := conf.MaxReceiveCount
+	if count <= 0 {
+		count = defaultMaxReceiveCount
+	}
+	if got := live("maxReceiveCount"); got != strconv.Itoa(count) {
+		drifts = append(drifts, QueueDrift{Attribute: "RedrivePolicy.maxReceiveCount", Configured: strconv.Itoa(count), Live: got})
+	}
+
+	return drifts
+}
+
+// highThroughputDrifts lists the attributes of a FIFO queue that keep it from high throughput mode
+func highThroughputDrifts(attrs map[string]*string) []QueueDrift {
+	var drifts []QueueDrift
+	for name, want := range map[string]string{deduplicationScopeAttribute: "messageGroup", fifoThroughputLimitAttribute: "perMessageGroupId"} {
+		if got := aws.StringValue(attrs[name]); got != want {
+			drifts = append(drifts, QueueDrift{Attribute: name, Configured: want, Live: got})
+		}
+	}
+
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Attribute < drifts[j].Attribute })
+	return drifts
+}
@@ -0,0 +1,71 @@
+package gosqs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestCheckDrift(t *testing.T) {
+	m := newMockSQS()
+	m.attributes = map[string]*string{
+		"VisibilityTimeout": aws.String("60"),
+		"RedrivePolicy":     aws.String(`{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:000000000000:dev-other","maxReceiveCount":5}`),
+	}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30}
+
+	var got []QueueDrift
+	conf := Config{Env: "dev", DeadLetterQueue: "dead-letters", OnQueueDrift: func(d []QueueDrift) { got = d }}
+	if err := c.checkDrift(conf); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(got) != 2 || got[0].Attribute != "VisibilityTimeout" || got[0].Live != "60" || got[1].Attribute != "RedrivePolicy.deadLetterTargetArn" {
+		t.Fatalf("expected the visibility timeout and dead letter target to drift, got %v", got)
+	}
+
+	if len(m.setInputs) != 0 {
+		t.Error("expected the queue not to be updated")
+	}
+
+	m.attributes["VisibilityTimeout"] = aws.String("30")
+	m.attributes["RedrivePolicy"] = aws.String(`{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:000000000000:dev-dead-letters","maxReceiveCount":"5"}`)
+	got = nil
+	if err := c.checkDrift(conf); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if got != nil {
+		t.Errorf("expected no drift, got %v", got)
+	}
+}
+
+func TestCheckDriftHighThroughputFIFO(t *testing.T) {
+	m := newMockSQS()
+	m.attributes = map[string]*string{
+		"VisibilityTimeout":  aws.String("30"),
+		"DeduplicationScope": aws.String("queue"),
+	}
+	c := &consumer{sqs: m, QueueURL: "queue.fifo", logger: &testLogger{}, VisibilityTimeout: 30}
+
+	var got []QueueDrift
+	conf := Config{HighThroughputFIFO: true, OnQueueDrift: func(d []QueueDrift) { got = d }}
+	if err := c.checkDrift(conf); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(got) != 2 || got[0].Attribute != "DeduplicationScope" || got[0].Live != "queue" || got[1].Attribute != "FifoThroughputLimit" {
+		t.Fatalf("expected both throughput attributes to drift, got %v", got)
+	}
+
+	m.attributes["DeduplicationScope"] = aws.String("messageGroup")
+	m.attributes["FifoThroughputLimit"] = aws.String("perMessageGroupId")
+	got = nil
+	if err := c.checkDrift(conf); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if got != nil {
+		t.Errorf("expected no drift, got %v", got)
+	}
+}
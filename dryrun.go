@@ -0,0 +1,93 @@
+package gosqs
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// dryRun hands the inputs of the calls that would send a message to a hook instead of AWS and returns synthetic message IDs
+type dryRun struct {
+	hook  func(input interface{})
+	count uint64
+}
+
+// newDryRun creates the dry run recorder, inputs are logged with the configured redaction when the config has no DryRunHook
+func newDryRun(c Config) *dryRun {
+	d := &dryRun{hook: c.DryRunHook}
+	if d.hook == nil {
+		logger := c.Logger
+		r := newRedactor(c)
+		d.hook = func(input interface{}) {
+			logger.Println("dry run:", r.input(input))
+		}
+	}
+
+	return d
+}
+
+// record passes the input to the hook and returns a synthetic message ID
+func (d *dryRun) record(input interface{}) *string {
+	d.hook(input)
+	return aws.String(fmt.Sprintf("dry-run-%d", atomic.AddUint64(&d.count, 1)))
+}
+
+// dryRunSNS wraps the sns client, calls that do not send messages are passed through
+type dryRunSNS struct {
+	snsiface.SNSAPI
+	*dryRun
+}
+
+func (d *dryRunSNS) Publish(in *sns.PublishInput) (*sns.PublishOutput, error) {
+	return &sns.PublishOutput{MessageId: d.record(in)}, nil
+}
+
+func (d *dryRunSNS) PublishWithContext(ctx aws.Context, in *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	return d.Publish(in)
+}
+
+// dryRunS3 wraps the s3 client, offloaded bodies are not uploaded and the hook receives the message with its pointer
+type dryRunS3 struct {
+	s3iface.S3API
+}
+
+func (d *dryRunS3) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	return &s3.PutObjectOutput{}, nil
+}
+
+// dryRunSQS wraps the sqs client, calls that do not send messages are passed through
+type dryRunSQS struct {
+	sqsiface.SQSAPI
+	*dryRun
+}
+
+func (d *dryRunSQS) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	return &sqs.SendMessageOutput{MessageId: d.record(in)}, nil
+}
+
+func (d *dryRunSQS) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	return d.SendMessage(in)
+}
+
+func (d *dryRunSQS) SendMessageBatch(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+	d.hook(in)
+
+	out := &sqs.SendMessageBatchOutput{}
+	for _, e := range in.Entries {
+		id := aws.String(fmt.Sprintf("dry-run-%d", atomic.AddUint64(&d.count, 1)))
+		out.Successful = append(out.Successful, &sqs.SendMessageBatchResultEntry{Id: e.Id, MessageId: id})
+	}
+	return out, nil
+}
+
+func (d *dryRunSQS) SendMessageBatchWithContext(ctx aws.Context, in *sqs.SendMessageBatchInput, opts ...request.Option) (*sqs.SendMessageBatchOutput, error) {
+	return d.SendMessageBatch(in)
+}
@@ -0,0 +1,56 @@
+package gosqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestDryRun(t *testing.T) {
+	var inputs []interface{}
+	conf := Config{
+		Region:     "local",
+		Key:        "key",
+		Secret:     "secret",
+		Hostname:   "http://localhost:4100",
+		TopicARN:   "arn:aws:sns:local:000000000000:todolist-dev",
+		DryRun:     true,
+		DryRunHook: func(input interface{}) { inputs = append(inputs, input) },
+	}
+	p, err := NewPublisher(conf)
+	if err != nil {
+		t.Fatalf("error creating publisher, got %v", err)
+	}
+
+	id, err := p.Publish(context.TODO(), "post_created", &sample{Val: "a"})
+	if err != nil || id != "dry-run-1" {
+		t.Fatalf("expected a synthetic message id, got %q, %v", id, err)
+	}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", &sample{}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	res, err := p.PublishBatch(context.TODO(), "post-worker", []BatchEntry{{Event: "post_created", Body: &sample{}}, {Event: "post_created", Body: &sample{}}})
+	if err != nil || res[1].MessageID != "dry-run-4" {
+		t.Fatalf("expected synthetic ids for every entry, got %+v, %v", res, err)
+	}
+
+	if len(inputs) != 3 {
+		t.Fatalf("expected 3 recorded inputs, got %d", len(inputs))
+	}
+
+	if in, ok := inputs[0].(*sns.PublishInput); !ok || *in.Message != `{"val":"a"}` || *in.MessageAttributes["route"].StringValue != "post_created" {
+		t.Errorf("unexpected publish input, got %+v", inputs[0])
+	}
+
+	if _, ok := inputs[1].(*sqs.SendMessageInput); !ok {
+		t.Errorf("expected a send message input, got %T", inputs[1])
+	}
+
+	if _, ok := inputs[2].(*sqs.SendMessageBatchInput); !ok {
+		t.Errorf("expected a send message batch input, got %T", inputs[2])
+	}
+}
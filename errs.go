@@ -34,6 +34,18 @@ func (e *SQSError) Error() string {
 	return e.Err
 }
 
+// Unwrap returns the contextual error
+func (e *SQSError) Unwrap() error {
+	return e.contextErr
+}
+
+// Is reports whether the target is the same gosqs error, ignoring the contextual error, so errors.Is matches errors created
+// with Context
+func (e *SQSError) Is(target error) bool {
+	t, ok := target.(*SQSError)
+	return ok && t.Err == e.Err
+}
+
 // Context is used for creating a new instance of the error with the contextual error attached
 func (e *SQSError) Context(err error) *SQSError {
 	ctxErr := new(SQSError)
@@ -65,6 +77,15 @@ var ErrUnableToExtend = newSQSErr("unable to extend message processing time")
 // ErrQueueURL undefined queueURL
 var ErrQueueURL = newSQSErr("undefined queueURL")
 
+// ErrQueueAttributes unable to read or update the queue attributes
+var ErrQueueAttributes = newSQSErr("unable to sync queue attributes")
+
+// ErrCreateResource unable to create the queue or topic
+var ErrCreateResource = newSQSErr("unable to create resource")
+
+// ErrTagResource unable to tag the queue or topic
+var ErrTagResource = newSQSErr("unable to tag resource")
+
 // ErrMarshal unable to marshal request
 var ErrMarshal = newSQSErr("unable to marshal request")
 
@@ -77,6 +98,9 @@ var ErrNoRoute = newSQSErr("message received without a route")
 // ErrGetMessage fires when a request to retrieve messages from sqs fails
 var ErrGetMessage = newSQSErr("unable to retrieve message")
 
+// ErrThrottled fires when SQS throttles a request to retrieve messages
+var ErrThrottled = newSQSErr("receive throttled by sqs")
+
 // ErrMessageProcessing occurs when a message has exceeded the consumption time limit set by aws SQS
 var ErrMessageProcessing = newSQSErr("processing time exceeding limit")
 
@@ -85,3 +109,108 @@ var ErrBodyOverflow = newSQSErr("message surpasses sqs limit of 262144, please t
 
 // ErrPublish If there is an error publishing a message. gosqs will wait 10 seconds and try again up to the configured retry count
 var ErrPublish = newSQSErr("message publish failure. Retrying...")
+
+// ErrDedupConflict a FIFO message can either provide a deduplication id or rely on content based deduplication, not both
+var ErrDedupConflict = newSQSErr("deduplication id cannot be combined with content based deduplication")
+
+// ErrDedupRequired messages sent to a FIFO queue or topic require a deduplication id or content based deduplication
+var ErrDedupRequired = newSQSErr("fifo messages require a deduplication id or content based deduplication")
+
+// ErrBatchPublish one or more entries of a batch could not be published, the batch result holds the error of each entry
+var ErrBatchPublish = newSQSErr("batch publish failure")
+
+// ErrPublisherClosed a message was sent in the background after the publisher was closed
+var ErrPublisherClosed = newSQSErr("publisher is closed, message dropped")
+
+// ErrInvalidConfig the configuration contains a value that cannot be used
+var ErrInvalidConfig = newSQSErr("invalid configuration")
+
+// ErrSlowHandler a handler ran longer than the configured SlowHandlerThreshold
+var ErrSlowHandler = newSQSErr("handler exceeded the slow handler threshold")
+
+// ErrReadBody the message body could not be read
+var ErrReadBody = newSQSErr("unable to read message body")
+
+// ErrQuarantine a message could not be handed to the quarantine sink
+var ErrQuarantine = newSQSErr("unable to quarantine message")
+
+// ErrUnknownContentType no codec is registered for the content-type attribute of a message
+var ErrUnknownContentType = newSQSErr("no codec registered for content type")
+
+// ErrHandlerPanic a handler panicked while processing a message
+var ErrHandlerPanic = newSQSErr("handler panicked")
+
+// ErrDecode the message body could not be decoded
+var ErrDecode = newSQSErr("unable to decode message")
+
+// ErrDuplicateMessage a message was skipped because it was already processed within the InMemoryDedupWindow
+var ErrDuplicateMessage = newSQSErr("duplicate message skipped")
+
+// ErrNotDeferrable the message cannot be acknowledged after its handler returned
+var ErrNotDeferrable = newSQSErr("message cannot be deferred")
+
+// ErrAckExpired a deferred message was not acknowledged within the ManualAckMaxHold and will be redelivered
+var ErrAckExpired = newSQSErr("deferred message was not acknowledged in time")
+
+// ErrAckFinished a deferred message was already acknowledged, or its handler returned an error
+var ErrAckFinished = newSQSErr("deferred message is already finished")
+
+// ErrMessageStructure the protocol payloads of WithMessageStructureJSON have no "default" key
+var ErrMessageStructure = newSQSErr(`message structure requires a "default" payload`)
+
+// ErrInvalidSignature the signature of an SNS delivery could not be verified
+var ErrInvalidSignature = newSQSErr("invalid SNS signature")
+
+// ErrNoReplyTo the message a reply was sent for has no ReplyTo queue
+var ErrNoReplyTo = newSQSErr("message has no reply to queue")
+
+// ErrSchemaVersion there is no handler for the schema version of a message
+var ErrSchemaVersion = newSQSErr("unsupported schema version")
+
+// ErrMessageTooLarge the body and attributes of a message exceed the limit of 262144 bytes, see MessageTooLargeError
+var ErrMessageTooLarge = newSQSErr("message too large")
+
+// ErrWarmup the consumer's WarmupFunc failed
+var ErrWarmup = newSQSErr("consumer warmup failed")
+
+// ErrBodyAttribute an attribute set with WithAttributeFromBody could not be read from the body
+var ErrBodyAttribute = newSQSErr("unable to read attribute from message body")
+
+// ErrCredentials a request failed because the aws credentials expired, are invalid or could not be retrieved
+var ErrCredentials = newSQSErr("aws credentials rejected")
+
+// ErrAttributeOverflow a message has more attributes than SQS accepts and its body cannot carry the rest
+var ErrAttributeOverflow = newSQSErr("message attributes exceed the limit and cannot overflow into the body")
+
+// ErrMissingHandlers no handler is registered for some of the message types passed to RequireHandlers
+var ErrMissingHandlers = newSQSErr("no handler registered for message types")
+
+// ErrGroupRequired messages sent to a FIFO queue or topic require a message group id
+var ErrGroupRequired = newSQSErr("fifo messages require a message group id")
+
+// ErrNotFIFO message group and deduplication options were set on a message sent to a standard queue or topic
+var ErrNotFIFO = newSQSErr("message group and deduplication options require a fifo queue or topic")
+
+// ErrRetry a ResultHandler asked for the message to be redelivered without giving a reason
+var ErrRetry = newSQSErr("handler requested a retry")
+
+// ErrNoDeadLetterQueue a handler returned ResultDeadLetter but neither WithDeadLetterQueue nor DeadLetterQueueURL is set
+var ErrNoDeadLetterQueue = newSQSErr("no dead letter queue url for the message")
+
+// ErrFailover publishing to the topic failed and the message was published to Config.FailoverTopicARN instead
+var ErrFailover = newSQSErr("publish failed over to the failover topic")
+
+// ErrOffload a body could not be uploaded to or downloaded from the S3 bucket of Config.OffloadBucket
+var ErrOffload = newSQSErr("unable to offload the message body to s3")
+
+// ErrMessageNotFound PeekMessage did not receive a message with the message ID
+var ErrMessageNotFound = newSQSErr("message not found in the queue")
+
+// ErrQueueTags the tags of the queue could not be read
+var ErrQueueTags = newSQSErr("unable to list the tags of the queue")
+
+// ErrInvalidSchema a schema passed to RegisterSchema could not be parsed
+var ErrInvalidSchema = newSQSErr("invalid json schema")
+
+// ErrSchemaValidation the body of a message does not match the schema registered for its type with RegisterSchema
+var ErrSchemaValidation = newSQSErr("message does not match its schema")
@@ -0,0 +1,28 @@
+package gosqs
+
+import "github.com/aws/aws-sdk-go/aws"
+
+// AckRequest is a message the consumer is done with, it is handed to Config.ExternalAck instead of being deleted
+type AckRequest struct {
+	// QueueURL is the queue the message was received from
+	QueueURL string
+	// MessageID is the SQS message ID
+	MessageID string
+	// ReceiptHandle deletes the message, it is valid until the message is received again
+	ReceiptHandle string
+	// Route is the route of the message
+	Route string
+}
+
+// ExternalAckFunc receives the messages the consumer would have deleted
+type ExternalAckFunc func(a AckRequest)
+
+// ackExternally hands a message to ExternalAck instead of deleting it
+func (c *consumer) ackExternally(m *message) {
+	c.externalAck(AckRequest{
+		QueueURL:      c.QueueURL,
+		MessageID:     aws.StringValue(m.MessageId),
+		ReceiptHandle: aws.StringValue(m.ReceiptHandle),
+		Route:         m.Route(),
+	})
+}
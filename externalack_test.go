@@ -0,0 +1,58 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestExternalAck(t *testing.T) {
+	m := newMockSQS()
+	var mu sync.Mutex
+	var acks []AckRequest
+	external := func(a AckRequest) {
+		mu.Lock()
+		acks = append(acks, a)
+		mu.Unlock()
+	}
+
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, externalAck: external}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return nil })
+	c.RegisterHandler("post_failed", func(ctx context.Context, m Message) error { return errors.New("failed") })
+
+	processed := m.add("queue", "post_created", "{}")
+	m.add("queue", "post_failed", "{}")
+	m.add("queue", "post_unknown", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(m.deleted) != 0 {
+		t.Errorf("expected the consumer not to delete any message, got %v", m.deleted)
+	}
+
+	if len(acks) != 2 || acks[0] != (AckRequest{QueueURL: "queue", MessageID: *processed.MessageId, ReceiptHandle: *processed.ReceiptHandle, Route: "post_created"}) {
+		t.Errorf("expected the processed messages to be handed over, got %+v", acks)
+	}
+
+	for _, a := range acks {
+		if a.Route == "post_failed" {
+			t.Errorf("expected the failed message to be redelivered, got %+v", a)
+		}
+	}
+}
+
+func TestExternalAckAtomicBatch(t *testing.T) {
+	m := newMockSQS()
+	var acks []AckRequest
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, atomicBatches: true,
+		externalAck: func(a AckRequest) { acks = append(acks, a) }}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return nil })
+
+	m.add("queue", "post_created", "{}")
+	m.add("queue", "post_created", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(acks) != 2 || len(m.deleted) != 0 {
+		t.Errorf("expected the completed batch to be handed over, got %d acks and %d deletes", len(acks), len(m.deleted))
+	}
+}
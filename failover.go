@@ -0,0 +1,119 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// FailoverPolicy selects the failures of the primary topic that make the publisher retry against the failover topic
+type FailoverPolicy int
+
+const (
+	// FailoverOnError publishes to the failover topic whenever publishing to the primary topic fails
+	FailoverOnError FailoverPolicy = iota
+	// FailoverOnTimeout publishes to the failover topic only when the primary region times out or cannot be reached, errors
+	// returned by SNS, e.g. authorization or validation errors, are returned as is
+	FailoverOnTimeout
+)
+
+// defaultFailoverTimeout keeps a primary region that does not respond from blocking Publish until the caller's deadline
+const defaultFailoverTimeout = 5 * time.Second
+
+// WithAcceptedRegion stores the region of the topic that accepted the message into region once Publish or PublishReader
+// returns, the region of Config.FailoverTopicARN when the message was published after a failover
+func WithAcceptedRegion(region *string) PublishOption {
+	return func(o *publishOptions) {
+		o.acceptedRegion = region
+	}
+}
+
+// topicARNRegion returns the region of an SNS topic ARN
+func topicARNRegion(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[3]
+}
+
+// failoverRegion returns the region of the failover topic
+func (c Config) failoverRegion() string {
+	if c.FailoverRegion != "" {
+		return c.FailoverRegion
+	}
+	return topicARNRegion(c.FailoverTopicARN)
+}
+
+// publishSNS publishes the input to the topic and, if that fails according to the FailoverPolicy, to the failover topic.
+// It returns the region of the topic that accepted the message
+func (p *publisher) publishSNS(ctx context.Context, input *sns.PublishInput) (*sns.PublishOutput, string, error) {
+	primary := ctx
+	if p.failoverSNS != nil && p.failoverTimeout > 0 {
+		var cancel context.CancelFunc
+		primary, cancel = context.WithTimeout(ctx, p.failoverTimeout)
+		defer cancel()
+	}
+
+	resp, err := p.sns.PublishWithContext(primary, input)
+	if err == nil {
+		return resp, p.region, nil
+	}
+
+	if p.failoverSNS == nil || ctx.Err() != nil || !p.shouldFailover(primary, err) {
+		return nil, "", err
+	}
+
+	failover := *input
+	failover.TopicArn = &p.failoverARN
+	resp, failoverErr := p.failoverSNS.PublishWithContext(ctx, &failover)
+	if failoverErr != nil {
+		// the primary error is wrapped so callers can inspect it, the failover error is only part of the message
+		return nil, "", ErrPublish.Context(fmt.Errorf("%s: %w, failover %s: %v", p.region, err, p.failoverRegion, failoverErr))
+	}
+
+	p.Logger().Println(ErrFailover.Context(err).Error(), "published to", p.failoverARN, "message id", aws.StringValue(resp.MessageId))
+	return resp, p.failoverRegion, nil
+}
+
+// shouldFailover reports whether the error of the primary topic calls for the failover topic
+func (p *publisher) shouldFailover(primary context.Context, err error) bool {
+	if p.failoverPolicy == FailoverOnError {
+		return true
+	}
+
+	return isUnreachable(primary, err)
+}
+
+// isUnreachable reports whether the request timed out or could not be sent at all
+func isUnreachable(ctx context.Context, err error) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case request.ErrCodeRequestError, request.ErrCodeResponseTimeout, request.CanceledErrorCode:
+			return true
+		}
+		if orig := aerr.OrigErr(); orig != nil && errors.As(orig, &netErr) {
+			return true
+		}
+	}
+
+	return false
+}
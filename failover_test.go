@@ -0,0 +1,132 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// hangingSNS does not respond until the request is cancelled
+type hangingSNS struct {
+	mockSNS
+}
+
+func (h *hangingSNS) PublishWithContext(ctx aws.Context, in *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	<-ctx.Done()
+	return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+}
+
+func TestPublishFailover(t *testing.T) {
+	newPublisher := func(primary, failover snsiface.SNSAPI, policy FailoverPolicy) *publisher {
+		return &publisher{sns: primary, logger: &testLogger{}, arn: "arn:aws:sns:us-east-1:000000000000:orders", region: "us-east-1",
+			failoverSNS: failover, failoverARN: "arn:aws:sns:us-west-2:000000000000:orders", failoverRegion: "us-west-2",
+			failoverPolicy: policy, failoverTimeout: 20 * time.Millisecond}
+	}
+
+	t.Run("on_error", func(t *testing.T) {
+		primary, failover := &mockSNS{publishErr: errors.New("unavailable")}, &mockSNS{}
+		p := newPublisher(primary, failover, FailoverOnError)
+
+		var region string
+		if _, err := p.Publish(context.TODO(), "order_created", &sample{}, WithAcceptedRegion(&region)); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if region != "us-west-2" || len(failover.published) != 1 || *failover.published[0].TopicArn != p.failoverARN {
+			t.Errorf("expected the message to be published to the failover topic, got %q, %v", region, failover.published)
+		}
+
+		if *primary.published[0].TopicArn != p.arn {
+			t.Errorf("expected the input of the primary topic to be left untouched, got %s", *primary.published[0].TopicArn)
+		}
+	})
+
+	t.Run("primary", func(t *testing.T) {
+		failover := &mockSNS{}
+		p := newPublisher(&mockSNS{}, failover, FailoverOnError)
+
+		var region string
+		if _, err := p.Publish(context.TODO(), "order_created", &sample{}, WithAcceptedRegion(&region)); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if region != "us-east-1" || len(failover.published) != 0 {
+			t.Errorf("expected the primary topic to accept the message, got %q", region)
+		}
+	})
+
+	t.Run("on_timeout", func(t *testing.T) {
+		failover := &mockSNS{}
+		rejected := &mockSNS{publishErr: awserr.New("AuthorizationError", "not authorized", nil)}
+		if _, err := newPublisher(rejected, failover, FailoverOnTimeout).Publish(context.TODO(), "order_created", &sample{}); !errors.Is(err, ErrPublish) {
+			t.Errorf("expected errors returned by SNS to fail the publish, got %v", err)
+		}
+
+		if len(failover.published) != 0 {
+			t.Fatalf("expected no failover for errors returned by SNS, got %d", len(failover.published))
+		}
+
+		var region string
+		if _, err := newPublisher(&hangingSNS{}, failover, FailoverOnTimeout).Publish(context.TODO(), "order_created", &sample{}, WithAcceptedRegion(&region)); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if region != "us-west-2" {
+			t.Errorf("expected a timeout to fail over, got %q", region)
+		}
+	})
+
+	t.Run("both_fail", func(t *testing.T) {
+		primaryErr := awserr.New("InternalError", "primary down", nil)
+		p := newPublisher(&mockSNS{publishErr: primaryErr}, &mockSNS{publishErr: errors.New("failover down")}, FailoverOnError)
+		_, err := p.Publish(context.TODO(), "order_created", &sample{})
+		if !errors.Is(err, ErrPublish) || !strings.Contains(err.Error(), "primary down") || !strings.Contains(err.Error(), "failover down") {
+			t.Errorf("expected both errors, got %v", err)
+		}
+
+		var aerr awserr.Error
+		if !errors.Is(err, primaryErr) || !errors.As(err, &aerr) || aerr.Code() != "InternalError" {
+			t.Errorf("expected the error of the primary topic to be wrapped, got %v", err)
+		}
+
+		if strings.Count(err.Error(), ErrPublish.Error()) != 1 {
+			t.Errorf("expected a single %v, got %v", ErrPublish, err)
+		}
+	})
+
+	t.Run("background", func(t *testing.T) {
+		failover := &mockSNS{}
+		p := newPublisher(&mockSNS{publishErr: errors.New("unavailable")}, failover, FailoverOnError)
+		if err := p.send(&sample{}, "order_created"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if len(failover.published) != 1 {
+			t.Errorf("expected background sends to fail over, got %d", len(failover.published))
+		}
+	})
+}
+
+func TestFailoverConfig(t *testing.T) {
+	if err := (Config{FailoverTopicARN: "orders"}).Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected an invalid failover ARN to be rejected, got %v", err)
+	}
+
+	c := Config{FailoverTopicARN: "arn:aws:sns:eu-west-1:000000000000:orders"}
+	if r := c.failoverRegion(); r != "eu-west-1" {
+		t.Errorf("expected the region of the ARN, got %q", r)
+	}
+
+	c.FailoverRegion = "eu-central-1"
+	if r := c.failoverRegion(); r != "eu-central-1" {
+		t.Errorf("expected the configured region, got %q", r)
+	}
+}
@@ -0,0 +1,143 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// PublishFailure describes a message sent in the background that could not be delivered
+type PublishFailure struct {
+	Event string
+	Err   error
+}
+
+// FlushError is returned by Flush when messages sent in the background could not be delivered
+type FlushError struct {
+	Failures []PublishFailure
+}
+
+// Error is used for implementing the error interface
+func (e *FlushError) Error() string {
+	return fmt.Sprintf("%s: %d messages could not be delivered", ErrPublish.Err, len(e.Failures))
+}
+
+// inflight tracks the messages that are being sent in the background
+type inflight struct {
+	mu       sync.Mutex
+	pending  int
+	idle     chan struct{}
+	closed   bool
+	failures []PublishFailure
+}
+
+// start registers a background send, it returns false once the publisher is closed
+func (f *inflight) start() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return false
+	}
+
+	if f.pending == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.pending++
+	return true
+}
+
+// isClosed reports whether the publisher has been closed
+func (f *inflight) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// done completes a background send, recording its failures
+func (f *inflight) done(failures ...PublishFailure) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = append(f.failures, failures...)
+
+	f.pending--
+	if f.pending == 0 {
+		close(f.idle)
+	}
+}
+
+// wait blocks until there are no pending sends and returns the failures collected so far
+func (f *inflight) wait(ctx context.Context) error {
+	f.mu.Lock()
+	idle := f.idle
+	pending := f.pending
+	f.mu.Unlock()
+
+	if pending > 0 {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	f.mu.Lock()
+	failures := f.failures
+	f.failures = nil
+	f.mu.Unlock()
+
+	if len(failures) > 0 {
+		return &FlushError{Failures: failures}
+	}
+
+	return nil
+}
+
+// async sends a message in the background while keeping track of it for Flush
+func (p *publisher) async(event string, send func() error) {
+	p.asyncEntries(event, func() []PublishFailure {
+		if err := send(); err != nil {
+			return []PublishFailure{{Event: event, Err: err}}
+		}
+		return nil
+	})
+}
+
+// asyncEntries is async for several messages sent together, e.g. an auto batch, reporting a failure per message
+func (p *publisher) asyncEntries(event string, send func() []PublishFailure) {
+	if !p.inflight.start() {
+		p.Logger().Println(ErrPublisherClosed.Error(), event)
+		return
+	}
+
+	go func() {
+		p.inflight.done(send()...)
+	}()
+}
+
+// Flush blocks until every message sent in the background has been delivered or has failed, or until the context is cancelled.
+// A *FlushError lists the messages that could not be delivered since the last Flush
+func (p *publisher) Flush(ctx context.Context) error {
+	p.batcher.flushAll()
+	return p.inflight.wait(ctx)
+}
+
+// Close flushes the publisher, messages sent in the background after Close are dropped. Publish and PublishTo are not affected
+func (p *publisher) Close(ctx context.Context) error {
+	p.batcher.flushAll()
+
+	p.inflight.mu.Lock()
+	p.inflight.closed = true
+	p.inflight.mu.Unlock()
+
+	return p.Flush(ctx)
+}
+
+// Logger accesses the logging field or applies a default logger
+func (p *publisher) Logger() Logger {
+	if p.logger == nil {
+		return &defaultLogger{}
+	}
+	return p.logger
+}
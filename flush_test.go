@@ -0,0 +1,83 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFlush(t *testing.T) {
+	publishRetryDelay = 0
+	defer func() { publishRetryDelay = 10 * time.Second }()
+
+	t.Run("waits_for_background_sends", func(t *testing.T) {
+		s := &mockSNS{}
+		p := &publisher{sns: s, arn: "arn:aws:sns:local:000000000000:dev-post-worker", logger: &testLogger{}}
+
+		for i := 0; i < 5; i++ {
+			p.Create(&sample{})
+		}
+
+		if err := p.Flush(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(s.published) != 5 {
+			t.Fatalf("expected 5 published messages, got %d", len(s.published))
+		}
+	})
+
+	t.Run("reports_failures_once", func(t *testing.T) {
+		s := &mockSNS{publishErr: errors.New("unavailable")}
+		p := &publisher{sns: s, arn: "arn:aws:sns:local:000000000000:dev-post-worker", logger: &testLogger{}}
+
+		p.Delete(&sample{})
+
+		var ferr *FlushError
+		if err := p.Flush(context.Background()); !errors.As(err, &ferr) {
+			t.Fatalf("expected a FlushError, got %v", err)
+		}
+
+		if len(ferr.Failures) != 1 || ferr.Failures[0].Event != "sample_deleted" {
+			t.Fatalf("unexpected failures: %+v", ferr.Failures)
+		}
+
+		if len(s.published) != maxRetryCount+1 {
+			t.Fatalf("expected %d attempts, got %d", maxRetryCount+1, len(s.published))
+		}
+
+		if err := p.Flush(context.Background()); err != nil {
+			t.Fatalf("expected failures to be cleared, got %v", err)
+		}
+	})
+
+	t.Run("context_cancelled", func(t *testing.T) {
+		p := &publisher{logger: &testLogger{}}
+		release := make(chan struct{})
+		p.async("blocked", func() error { <-release; return nil })
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := p.Flush(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("close_drops_later_sends", func(t *testing.T) {
+		s := &mockSNS{}
+		l := &testLogger{}
+		p := &publisher{sns: s, arn: "arn:aws:sns:local:000000000000:dev-post-worker", logger: l}
+
+		if err := p.Close(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		p.Create(&sample{})
+		if len(s.published) != 0 || len(l.lines) != 1 {
+			t.Fatalf("expected the message to be dropped and logged, got %d published and %d log lines", len(s.published), len(l.lines))
+		}
+	})
+}
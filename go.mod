@@ -2,4 +2,4 @@ module github.com/qhenkart/gosqs
 
 go 1.15
 
-require github.com/aws/aws-sdk-go v1.34.13
+require github.com/aws/aws-sdk-go v1.36.30
@@ -0,0 +1,84 @@
+package gosqs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Heartbeat is emitted by the receive loop every HeartbeatInterval as proof that it is still running
+type Heartbeat struct {
+	// QueueURL is the queue of the consumer
+	QueueURL string
+	// Elapsed is the time since the previous heartbeat, or since the consumer started
+	Elapsed time.Duration
+	// Processed is the number of messages whose handler succeeded since the previous heartbeat
+	Processed int
+	// Failed is the number of messages whose handler returned an error since the previous heartbeat
+	Failed int
+	// InFlight is the number of received messages that have not finished processing
+	InFlight int
+	// Empty is true if the last successful receive returned no messages
+	Empty bool
+}
+
+// HeartbeatFunc is called with every heartbeat of the receive loop
+type HeartbeatFunc func(h Heartbeat)
+
+// heartbeat keeps what the next heartbeat is measured against
+type heartbeat struct {
+	mu        sync.Mutex
+	last      time.Time
+	processed int
+	failed    int
+	empty     bool
+}
+
+// received records the number of messages of a successful receive
+func (h *heartbeat) received(messages int) {
+	h.mu.Lock()
+	h.empty = messages == 0
+	h.mu.Unlock()
+}
+
+// beat emits a heartbeat once HeartbeatInterval has passed since the previous one, it is called by the receive loop after
+// every poll so heartbeats stop when the loop hangs
+func (c *consumer) beat(now time.Time) {
+	if c.heartbeatInterval <= 0 {
+		return
+	}
+
+	s := c.stats.snapshot()
+	c.heartbeat.mu.Lock()
+	if c.heartbeat.last.IsZero() {
+		c.heartbeat.last, c.heartbeat.processed, c.heartbeat.failed = now, s.Processed, s.Failed
+	}
+
+	elapsed := now.Sub(c.heartbeat.last)
+	if elapsed < c.heartbeatInterval {
+		c.heartbeat.mu.Unlock()
+		return
+	}
+
+	h := Heartbeat{
+		QueueURL:  c.QueueURL,
+		Elapsed:   elapsed,
+		Processed: s.Processed - c.heartbeat.processed,
+		Failed:    s.Failed - c.heartbeat.failed,
+		InFlight:  s.InFlight,
+		Empty:     c.heartbeat.empty,
+	}
+	c.heartbeat.last, c.heartbeat.processed, c.heartbeat.failed = now, s.Processed, s.Failed
+	c.heartbeat.mu.Unlock()
+
+	if c.onHeartbeat != nil {
+		c.onHeartbeat(h)
+		return
+	}
+
+	state := "busy"
+	if h.Empty {
+		state = "empty"
+	}
+	c.Logger().Println(fmt.Sprintf("consumer alive, %d processed and %d failed since last heartbeat, queue %s", h.Processed, h.Failed, state), c.QueueURL)
+}
@@ -0,0 +1,59 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	m := newMockSQS()
+	var beats []Heartbeat
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		heartbeatInterval: time.Minute, onHeartbeat: func(h Heartbeat) { beats = append(beats, h) }}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return nil })
+	c.RegisterHandler("post_failed", func(ctx context.Context, m Message) error { return errors.New("failed") })
+
+	start := time.Now()
+	c.beat(start)
+
+	m.add("queue", "post_created", "{}")
+	m.add("queue", "post_created", "{}")
+	m.add("queue", "post_failed", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+	c.beat(start.Add(30 * time.Second))
+	if len(beats) != 0 {
+		t.Fatalf("expected no heartbeat before the interval, got %v", beats)
+	}
+
+	c.beat(start.Add(time.Minute))
+	if len(beats) != 1 || beats[0].Processed != 2 || beats[0].Failed != 1 || beats[0].Empty || beats[0].Elapsed != time.Minute {
+		t.Fatalf("expected a heartbeat of the busy queue, got %+v", beats)
+	}
+
+	c.poll(func(msg *message) { c.run(msg) })
+	c.beat(start.Add(2 * time.Minute))
+	if len(beats) != 2 || beats[1].Processed != 0 || !beats[1].Empty || beats[1].QueueURL != "queue" {
+		t.Errorf("expected a heartbeat of the empty queue, got %+v", beats[1:])
+	}
+}
+
+func TestHeartbeatLog(t *testing.T) {
+	logger := &testLogger{}
+	c := &consumer{QueueURL: "queue", logger: logger, heartbeatInterval: time.Minute}
+	c.heartbeat.received(0)
+
+	start := time.Now()
+	c.beat(start)
+	c.beat(start.Add(time.Minute))
+
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0][0].(string), "queue empty") {
+		t.Errorf("expected the heartbeat to be logged without OnHeartbeat, got %v", logger.lines)
+	}
+
+	if err := (Config{HeartbeatInterval: -time.Second}).Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected a negative HeartbeatInterval to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package gosqs
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// idleTracker measures how long consecutive receives returned no messages
+type idleTracker struct {
+	mu    sync.Mutex
+	since time.Time
+	fired bool
+}
+
+// trackIdle records a successful receive and calls OnIdle once the queue has been empty for IdleAfter. It is called once
+// per idle period, the next received message starts a new one
+func (c *consumer) trackIdle(now time.Time, messages int) {
+	if c.onIdle == nil || c.idleAfter <= 0 {
+		return
+	}
+
+	c.idle.mu.Lock()
+	if messages > 0 {
+		c.idle.since, c.idle.fired = time.Time{}, false
+		c.idle.mu.Unlock()
+		return
+	}
+
+	if c.idle.since.IsZero() {
+		c.idle.since = now
+	}
+
+	d := now.Sub(c.idle.since)
+	fire := !c.idle.fired && d >= c.idleAfter
+	if fire {
+		c.idle.fired = true
+	}
+	c.idle.mu.Unlock()
+
+	if fire {
+		c.onIdle(d)
+	}
+}
+
+// QueueDepth is the approximate number of messages of the consumer's queue
+type QueueDepth struct {
+	// Visible messages are waiting to be received
+	Visible int
+	// InFlight messages were received and are neither deleted nor visible again
+	InFlight int
+	// Delayed messages are not visible yet because of a delivery delay
+	Delayed int
+}
+
+// queueDepthAttributes are the queue attributes QueueDepth is read from
+var queueDepthAttributes = []*string{
+	aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages),
+	aws.String(sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible),
+	aws.String(sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed),
+}
+
+// QueueDepth returns the approximate number of messages of the consumer's queue. SQS updates the numbers with a delay of
+// about a minute
+func (c *consumer) QueueDepth() (QueueDepth, error) {
+	o, err := c.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{QueueUrl: &c.QueueURL, AttributeNames: queueDepthAttributes})
+	if err != nil {
+		return QueueDepth{}, ErrQueueAttributes.Context(err)
+	}
+
+	count := func(name string) int {
+		n, _ := strconv.Atoi(aws.StringValue(o.Attributes[name]))
+		return n
+	}
+
+	return QueueDepth{
+		Visible:  count(sqs.QueueAttributeNameApproximateNumberOfMessages),
+		InFlight: count(sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible),
+		Delayed:  count(sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed),
+	}, nil
+}
@@ -0,0 +1,57 @@
+package gosqs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestOnIdle(t *testing.T) {
+	var idle []time.Duration
+	c := &consumer{idleAfter: time.Minute, onIdle: func(d time.Duration) { idle = append(idle, d) }}
+
+	start := time.Now()
+	c.trackIdle(start, 0)
+	c.trackIdle(start.Add(30*time.Second), 0)
+	if len(idle) != 0 {
+		t.Fatalf("expected no call before IdleAfter, got %v", idle)
+	}
+
+	c.trackIdle(start.Add(time.Minute), 0)
+	c.trackIdle(start.Add(2*time.Minute), 0)
+	if len(idle) != 1 || idle[0] != time.Minute {
+		t.Fatalf("expected a single call once the queue was empty for IdleAfter, got %v", idle)
+	}
+
+	c.trackIdle(start.Add(3*time.Minute), 1)
+	c.trackIdle(start.Add(4*time.Minute), 0)
+	c.trackIdle(start.Add(5*time.Minute), 0)
+	if len(idle) != 2 || idle[1] != time.Minute {
+		t.Errorf("expected a received message to start a new idle period, got %v", idle)
+	}
+
+	if err := (Config{OnIdle: func(time.Duration) {}}).Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected OnIdle without IdleAfter to be rejected, got %v", err)
+	}
+}
+
+func TestQueueDepth(t *testing.T) {
+	m := newMockSQS()
+	m.attributes = map[string]*string{
+		sqs.QueueAttributeNameApproximateNumberOfMessages:           aws.String("12"),
+		sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible: aws.String("3"),
+	}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+
+	depth, err := c.QueueDepth()
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if depth != (QueueDepth{Visible: 12, InFlight: 3}) {
+		t.Errorf("unexpected depth, got %+v", depth)
+	}
+}
@@ -0,0 +1,43 @@
+package gosqs
+
+import (
+	"context"
+	"time"
+)
+
+// InFlightMessage identifies a message while its handler runs
+type InFlightMessage struct {
+	MessageID string
+	Route     string
+	Started   time.Time
+}
+
+// InFlightTracker is notified when a handler starts and ends, e.g. to maintain a registry of the messages currently being
+// processed. End is called exactly once for every Start, also when the handler panics, in which case err is ErrHandlerPanic
+// and the panic continues afterwards. Both are called from the worker goroutines and must be safe for concurrent use
+type InFlightTracker interface {
+	Start(m InFlightMessage)
+	End(m InFlightMessage, err error)
+}
+
+// handle runs the handler and reports it to the in flight tracker
+func (c *consumer) handle(ctx context.Context, h Handler, m *message) error {
+	if c.tracker == nil {
+		return h(ctx, m)
+	}
+
+	info := InFlightMessage{MessageID: stringValue(m.MessageId), Route: m.Route(), Started: time.Now()}
+	c.tracker.Start(info)
+
+	ended := false
+	defer func() {
+		if !ended {
+			c.tracker.End(info, ErrHandlerPanic)
+		}
+	}()
+
+	err := h(ctx, m)
+	ended = true
+	c.tracker.End(info, err)
+	return err
+}
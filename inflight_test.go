@@ -0,0 +1,56 @@
+package gosqs
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type recordingTracker struct {
+	mu      sync.Mutex
+	started []InFlightMessage
+	ended   []error
+}
+
+func (r *recordingTracker) Start(m InFlightMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = append(r.started, m)
+}
+
+func (r *recordingTracker) End(m InFlightMessage, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = append(r.ended, err)
+}
+
+func TestInFlightTracker(t *testing.T) {
+	tracker := &recordingTracker{}
+	c := &consumer{tracker: tracker}
+	m := newMessage(newMockSQS().add("queue", "post_created", "{}"))
+
+	if err := c.handle(context.TODO(), func(ctx context.Context, msg Message) error { return nil }, m); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("expected the panic to continue")
+			}
+		}()
+		c.handle(context.TODO(), func(ctx context.Context, msg Message) error { panic("boom") }, m)
+	}()
+
+	if len(tracker.started) != 2 || len(tracker.ended) != 2 {
+		t.Fatalf("expected start and end to be paired, got %d starts and %d ends", len(tracker.started), len(tracker.ended))
+	}
+
+	if tracker.started[0].MessageID != "1" || tracker.started[0].Route != "post_created" || tracker.started[0].Started.IsZero() {
+		t.Errorf("unexpected in flight message, got %+v", tracker.started[0])
+	}
+
+	if tracker.ended[0] != nil || tracker.ended[1] != ErrHandlerPanic {
+		t.Errorf("unexpected results, got %v", tracker.ended)
+	}
+}
@@ -0,0 +1,291 @@
+package gosqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// maxSchemaViolations bounds the violations reported for a message, they end up in the dead-letter-reason attribute
+const maxSchemaViolations = 10
+
+// jsonSchema is the subset of JSON Schema checked by RegisterSchema
+type jsonSchema struct {
+	Type                 json.RawMessage        `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+
+	// annotations are accepted but do not affect the validation
+	SchemaURI   json.RawMessage `json:"$schema"`
+	ID          json.RawMessage `json:"$id"`
+	Comment     json.RawMessage `json:"$comment"`
+	Title       json.RawMessage `json:"title"`
+	Description json.RawMessage `json:"description"`
+	Default     json.RawMessage `json:"default"`
+	Examples    json.RawMessage `json:"examples"`
+
+	types      []string
+	additional *jsonSchema
+	closed     bool
+	pattern    *regexp.Regexp
+}
+
+// parseSchema parses and compiles a schema, keywords outside the supported subset are rejected as a schema relying on them
+// would accept bodies it is meant to reject
+func parseSchema(b []byte) (*jsonSchema, error) {
+	var s jsonSchema
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&s); err != nil {
+		if name := strings.TrimPrefix(err.Error(), "json: unknown field "); name != err.Error() {
+			return nil, fmt.Errorf("unsupported keyword %s", name)
+		}
+		return nil, err
+	}
+
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// compile resolves the keywords that accept more than one form, e.g. a type name or a list of them
+func (s *jsonSchema) compile() error {
+	if len(s.Type) > 0 {
+		var name string
+		if err := json.Unmarshal(s.Type, &name); err == nil {
+			s.types = []string{name}
+		} else if err := json.Unmarshal(s.Type, &s.types); err != nil {
+			return fmt.Errorf("type must be a string or a list of strings")
+		}
+	}
+
+	if len(s.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(s.AdditionalProperties, &allowed); err == nil {
+			s.closed = !allowed
+		} else {
+			additional, err := parseSchema(s.AdditionalProperties)
+			if err != nil {
+				return fmt.Errorf("additionalProperties: %v", err)
+			}
+			s.additional = additional
+		}
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern: %v", err)
+		}
+		s.pattern = re
+	}
+
+	for name, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return fmt.Errorf("properties.%s: %v", name, err)
+		}
+	}
+
+	if s.Items != nil {
+		if err := s.Items.compile(); err != nil {
+			return fmt.Errorf("items: %v", err)
+		}
+	}
+	return nil
+}
+
+// jsonType returns the JSON Schema type of a decoded value, whole numbers are integers
+func jsonType(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// validate appends the violations of the value at path to errs
+func (s *jsonSchema) validate(v interface{}, path string, errs *[]string) {
+	if len(*errs) >= maxSchemaViolations {
+		return
+	}
+
+	add := func(format string, args ...interface{}) {
+		if len(*errs) < maxSchemaViolations {
+			*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	got := jsonType(v)
+	if len(s.types) > 0 {
+		var ok bool
+		for _, t := range s.types {
+			if t == got || (t == "number" && got == "integer") {
+				ok = true
+			}
+		}
+		if !ok {
+			add("expected %s, got %s", strings.Join(s.types, " or "), got)
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		var ok bool
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				ok = true
+			}
+		}
+		if !ok {
+			add("value is not one of the allowed values")
+		}
+	}
+
+	switch val := v.(type) {
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			add("%v is less than the minimum of %v", val, *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			add("%v is greater than the maximum of %v", val, *s.Maximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			add("shorter than %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			add("longer than %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			add("does not match the pattern %s", s.Pattern)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			add("fewer than %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			add("more than %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				add("missing required field %q", name)
+			}
+		}
+
+		// sorted so the violations are reported in the same order for every delivery
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			field := path + "." + name
+			if p, ok := s.Properties[name]; ok {
+				p.validate(val[name], field, errs)
+			} else if s.additional != nil {
+				s.additional.validate(val[name], field, errs)
+			} else if s.closed {
+				add("unexpected field %q", name)
+			}
+		}
+	}
+}
+
+// RegisterSchema registers the JSON schema the body of messages of the type must match, it is checked before the handler
+// runs. Messages that do not match are passed to Config.OnValidationError and moved to Config.DeadLetterQueueURL with the
+// violations as the dead-letter-reason, without a dead letter queue they are redelivered like any other failure.
+//
+// The schema supports the keywords type, properties, required, additionalProperties, items, enum, minimum, maximum,
+// minLength, maxLength, pattern, minItems and maxItems and the annotations $schema, $id, $comment, title, description,
+// default and examples. Other keywords, e.g. $ref, allOf or format, fail with ErrInvalidSchema. Only JSON bodies are
+// validated, messages of another content-type are handled as is
+func (c *consumer) RegisterSchema(msgType string, schema []byte) error {
+	s, err := parseSchema(schema)
+	if err != nil {
+		return ErrInvalidSchema.Context(fmt.Errorf("%s: %v", msgType, err))
+	}
+
+	if c.schemas == nil {
+		c.schemas = make(map[string]*jsonSchema)
+	}
+	c.schemas[msgType] = s
+	return nil
+}
+
+// validateSchema checks the body of the message against the schema registered for its route
+func (c *consumer) validateSchema(m *message) error {
+	s, ok := c.schemas[m.Route()]
+	if !ok || m.bodyErr != nil || (m.contentType != "" && !strings.Contains(m.contentType, "json")) {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(m.body(), &v); err != nil {
+		return ErrSchemaValidation.Context(fmt.Errorf("%s: invalid JSON: %v", m.Route(), err))
+	}
+
+	var errs []string
+	s.validate(v, "$", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return ErrSchemaValidation.Context(fmt.Errorf("%s: %s", m.Route(), strings.Join(errs, "; ")))
+}
+
+// rejectInvalid passes a message that failed validation to OnValidationError and moves it to the dead letter queue, it
+// reports whether the message was moved and can be deleted
+func (c *consumer) rejectInvalid(ctx context.Context, m *message, err error) bool {
+	if c.onValidationError != nil {
+		c.onValidationError(m, err)
+	} else {
+		c.logMessage(m, err.Error(), aws.StringValue(m.MessageId))
+	}
+
+	if c.deadLetterQueueURL == "" {
+		c.logMessage(m, ErrNoDeadLetterQueue.Error(), m.Route(), aws.StringValue(m.MessageId))
+		return false
+	}
+
+	return c.sendDeadLetter(ctx, m, c.deadLetterQueueURL, err)
+}
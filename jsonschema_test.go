@@ -0,0 +1,114 @@
+package gosqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "total"],
+	"properties": {
+		"id": {"type": "string", "minLength": 1},
+		"total": {"type": "number", "minimum": 0},
+		"status": {"enum": ["open", "paid"]},
+		"items": {"type": "array", "items": {"type": "object", "required": ["sku"], "additionalProperties": false,
+			"properties": {"sku": {"type": "string"}, "qty": {"type": "integer"}}}}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := parseSchema([]byte(orderSchema))
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"valid", `{"id":"1","total":9.5,"status":"paid","items":[{"sku":"a","qty":2}]}`, nil},
+		{"missing field", `{"id":"1"}`, []string{`$: missing required field "total"`}},
+		{"wrong type", `{"id":1,"total":"9"}`, []string{"$.id: expected string, got integer", "$.total: expected number, got string"}},
+		{"enum and minimum", `{"id":"1","total":-1,"status":"closed"}`, []string{"$.status: value is not one of the allowed values", "$.total: -1 is less than the minimum of 0"}},
+		{"nested", `{"id":"1","total":1,"items":[{"qty":1.5,"color":"red"}]}`, []string{`$.items[0]: missing required field "sku"`, `$.items[0]: unexpected field "color"`, "$.items[0].qty: expected integer, got number"}},
+		{"not an object", `[]`, []string{"$: expected object, got array"}},
+	}
+
+	for _, tt := range tests {
+		var v interface{}
+		if err := json.Unmarshal([]byte(tt.body), &v); err != nil {
+			t.Fatalf("%s: invalid body, got %v", tt.name, err)
+		}
+
+		var errs []string
+		s.validate(v, "$", &errs)
+		if strings.Join(errs, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, errs)
+		}
+	}
+
+	if _, err := parseSchema([]byte(`{"type": 1}`)); err == nil {
+		t.Errorf("expected an invalid type to be rejected")
+	}
+
+	for _, schema := range []string{`{"$ref": "#/definitions/order"}`, `{"properties": {"id": {"format": "uuid"}}}`,
+		`{"items": {"oneOf": []}}`, `{"additionalProperties": {"allOf": []}}`} {
+		if _, err := parseSchema([]byte(schema)); err == nil || !strings.Contains(err.Error(), "unsupported keyword") {
+			t.Errorf("expected the unsupported keyword of %s to be rejected, got %v", schema, err)
+		}
+	}
+
+	if _, err := parseSchema([]byte(`{"$schema": "http://json-schema.org/draft-07/schema#", "title": "order", "description": "an order"}`)); err != nil {
+		t.Errorf("expected the annotations to be accepted, got %v", err)
+	}
+}
+
+func TestRegisterSchema(t *testing.T) {
+	m := newMockSQS()
+	var rejected []error
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		deadLetterQueueURL: "dlq", onValidationError: func(m Message, err error) { rejected = append(rejected, err) }}
+
+	if err := c.RegisterSchema("order_created", []byte(`{"type":`)); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("expected %v, got %v", ErrInvalidSchema, err)
+	}
+
+	if err := c.RegisterSchema("order_created", []byte(orderSchema)); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	var handled int
+	c.RegisterHandler("order_created", func(ctx context.Context, m Message) error {
+		handled++
+		return nil
+	})
+
+	m.add("queue", "order_created", `{"id":"1","total":10}`)
+	m.add("queue", "order_created", `{"id":"2","total":"10"}`)
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if handled != 1 || len(m.deleted) != 2 {
+		t.Fatalf("expected only the valid message to be handled and both to be deleted, got %d handled, %d deletes", handled, len(m.deleted))
+	}
+
+	if len(rejected) != 1 || !errors.Is(rejected[0], ErrSchemaValidation) {
+		t.Fatalf("expected OnValidationError to be called with %v, got %v", ErrSchemaValidation, rejected)
+	}
+
+	dlq := m.queues["dlq"]
+	if len(dlq) != 1 || !strings.Contains(*dlq[0].MessageAttributes[deadLetterReasonAttribute].StringValue, "$.total: expected number, got string") {
+		t.Errorf("expected the invalid message to be dead-lettered with the violations, got %v", dlq)
+	}
+
+	c.deadLetterQueueURL = ""
+	m.add("queue", "order_created", `{"total":10}`)
+	c.poll(func(msg *message) { c.run(msg) })
+	if handled != 1 || len(m.deleted) != 2 {
+		t.Errorf("expected the invalid message to be redelivered without a dead letter queue, got %d handled, %d deletes", handled, len(m.deleted))
+	}
+}
@@ -0,0 +1,162 @@
+// Package memory provides an in-memory transport for gosqs, e.g. for unit tests and local development without AWS or an
+// emulator. Queues and topics live in the process and are lost when it exits
+//
+//	transport := memory.New()
+//	transport.CreateQueue("dev-post-worker")
+//	transport.Subscribe("todolist-dev", "dev-post-worker", false)
+//
+//	conf := gosqs.Config{Env: "dev", TopicPrefix: "todolist", Transport: transport}
+//
+// Queues support visibility timeouts, delays, FIFO message groups and the RedrivePolicy attribute, a message that is
+// received more often than its maxReceiveCount is moved to the dead letter queue. FIFO deduplication, message retention
+// and permissions are not emulated. Only the SQS and SNS operations used by gosqs are implemented, the others panic
+package memory
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+const (
+	// Region is the region of the queue and topic ARNs
+	Region = "memory"
+	// AccountID is the account of the queue and topic ARNs
+	AccountID = "000000000000"
+)
+
+// defaultVisibilityTimeout is the VisibilityTimeout attribute of created queues
+const defaultVisibilityTimeout = "30"
+
+// defaultWaitTimeSeconds is the ReceiveMessageWaitTimeSeconds attribute of created queues, receives long poll so an idle
+// consumer does not spin
+const defaultWaitTimeSeconds = "1"
+
+// pollInterval is how often a long poll checks for visible messages
+const pollInterval = 10 * time.Millisecond
+
+// Transport holds the queues and topics, it satisfies gosqs.Transport and is safe for concurrent use
+type Transport struct {
+	mu     sync.Mutex
+	queues map[string]*queue
+	topics map[string][]subscription
+	nextID int
+
+	now func() time.Time
+	sqs *sqsClient
+	sns *snsClient
+}
+
+// New creates an empty transport
+func New() *Transport {
+	t := &Transport{queues: map[string]*queue{}, topics: map[string][]subscription{}, now: time.Now}
+	t.sqs = &sqsClient{t: t}
+	t.sns = &snsClient{t: t}
+	return t
+}
+
+// SQS returns the in-memory SQS client
+func (t *Transport) SQS() sqsiface.SQSAPI {
+	return t.sqs
+}
+
+// SNS returns the in-memory SNS client
+func (t *Transport) SNS() snsiface.SNSAPI {
+	return t.sns
+}
+
+// CreateQueue creates the queue if it does not exist and returns its url
+func (t *Transport) CreateQueue(name string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.createQueue(name, nil).url
+}
+
+// Subscribe delivers the messages published to the topic to the queue, both are created if they do not exist. Without
+// raw delivery the messages are wrapped in an SNS envelope like SNS does
+func (t *Transport) Subscribe(topic, queueName string, raw bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.createQueue(queueName, nil)
+	t.topics[topic] = append(t.topics[topic], subscription{queue: queueName, raw: raw})
+}
+
+// Len returns the number of messages in the queue, including messages that are in flight or delayed
+func (t *Transport) Len(queueName string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q, ok := t.queues[queueName]
+	if !ok {
+		return 0
+	}
+	return len(q.messages)
+}
+
+// createQueue returns the queue, creating it with the attributes if it does not exist. The lock must be held
+func (t *Transport) createQueue(name string, attributes map[string]*string) *queue {
+	if q, ok := t.queues[name]; ok {
+		return q
+	}
+
+	q := &queue{
+		name: name,
+		url:  fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", Region, AccountID, name),
+		attributes: map[string]string{
+			"QueueArn":                      queueARN(name),
+			"VisibilityTimeout":             defaultVisibilityTimeout,
+			"ReceiveMessageWaitTimeSeconds": defaultWaitTimeSeconds,
+			"DelaySeconds":                  "0",
+		},
+	}
+	for k, v := range attributes {
+		if v != nil {
+			q.attributes[k] = *v
+		}
+	}
+
+	t.queues[name] = q
+	return q
+}
+
+// queue returns the queue of the url, the lock must be held
+func (t *Transport) queue(url string) (*queue, bool) {
+	q, ok := t.queues[url[strings.LastIndex(url, "/")+1:]]
+	return q, ok
+}
+
+// id returns a new message ID or receipt handle, the lock must be held
+func (t *Transport) id(prefix string) string {
+	t.nextID++
+	return prefix + "-" + strconv.Itoa(t.nextID)
+}
+
+// queueARN returns the ARN of the queue
+func queueARN(name string) string {
+	return fmt.Sprintf("arn:aws:sqs:%s:%s:%s", Region, AccountID, name)
+}
+
+// topicARN returns the ARN of the topic
+func topicARN(name string) string {
+	return fmt.Sprintf("arn:aws:sns:%s:%s:%s", Region, AccountID, name)
+}
+
+// nameOf returns the queue or topic name of an ARN
+func nameOf(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+// md5Of returns the hex encoded MD5 digest SQS reports for message bodies
+func md5Of(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/qhenkart/gosqs"
+)
+
+type post struct {
+	Val string `json:"val"`
+}
+
+func TestTransport(t *testing.T) {
+	transport := New()
+	transport.Subscribe("todolist-dev", "dev-post-worker", false)
+
+	conf := gosqs.Config{Env: "dev", TopicPrefix: "todolist", Transport: transport}
+	pub, err := gosqs.NewPublisher(conf)
+	if err != nil {
+		t.Fatalf("unable to create the publisher, got %v", err)
+	}
+
+	c, err := gosqs.NewConsumer(conf, "post-worker")
+	if err != nil {
+		t.Fatalf("unable to create the consumer, got %v", err)
+	}
+
+	received := make(chan string, 2)
+	c.RegisterHandler("post_published", func(ctx context.Context, m gosqs.Message) error {
+		var p post
+		if err := m.Decode(&p); err != nil {
+			return err
+		}
+		received <- p.Val
+		return nil
+	})
+
+	if _, err := pub.Publish(context.TODO(), "post_published", post{Val: "published"}); err != nil {
+		t.Fatalf("unable to publish, got %v", err)
+	}
+	if _, err := pub.PublishTo(context.TODO(), "post-worker", "post_published", post{Val: "direct"}); err != nil {
+		t.Fatalf("unable to send, got %v", err)
+	}
+
+	go c.Consume()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the messages to be handled")
+		}
+	}
+
+	if err := c.Shutdown(context.TODO()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if n := transport.Len("dev-post-worker"); n != 0 {
+		t.Errorf("expected the handled messages to be deleted, got %d", n)
+	}
+}
+
+func TestVisibilityAndDeadLetter(t *testing.T) {
+	transport := New()
+	now := time.Now()
+	transport.now = func() time.Time { return now }
+
+	dlq := transport.CreateQueue("dev-dead-letters")
+	client := transport.SQS()
+	created, _ := client.CreateQueue(&sqs.CreateQueueInput{QueueName: aws.String("dev-post-worker"), Attributes: map[string]*string{
+		sqs.QueueAttributeNameRedrivePolicy: aws.String(`{"deadLetterTargetArn":"` + queueARN("dev-dead-letters") + `","maxReceiveCount":"2"}`),
+	}})
+	url := created.QueueUrl
+
+	client.SendMessage(&sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String("{}")})
+	receive := func() []*sqs.Message {
+		out, err := client.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: url, WaitTimeSeconds: aws.Int64(0)})
+		if err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+		return out.Messages
+	}
+
+	first := receive()
+	if len(first) != 1 || len(receive()) != 0 {
+		t.Fatal("expected the message to be hidden for the visibility timeout")
+	}
+
+	if _, err := client.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{QueueUrl: url, ReceiptHandle: first[0].ReceiptHandle, VisibilityTimeout: aws.Int64(0)}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	second := receive()
+	if len(second) != 1 || *second[0].Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] != "2" {
+		t.Fatalf("expected the message to be received again, got %v", second)
+	}
+
+	if _, err := client.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: url, ReceiptHandle: first[0].ReceiptHandle}); err == nil {
+		t.Error("expected the receipt handle of the first receive to be invalid")
+	}
+
+	now = now.Add(31 * time.Second)
+	if len(receive()) != 0 {
+		t.Fatal("expected the message to exceed the max receive count")
+	}
+
+	if transport.Len("dev-post-worker") != 0 || transport.Len("dev-dead-letters") != 1 {
+		t.Errorf("expected the message to be moved to %s", dlq)
+	}
+}
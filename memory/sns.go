@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// subscription delivers the messages of a topic to a queue
+type subscription struct {
+	queue string
+	raw   bool
+}
+
+// envelope is the notification SNS delivers to queues without raw message delivery
+type envelope struct {
+	Type              string
+	MessageId         string
+	TopicArn          string
+	Subject           string `json:",omitempty"`
+	Message           string
+	Timestamp         string
+	SignatureVersion  string
+	Signature         string
+	SigningCertURL    string
+	UnsubscribeURL    string
+	MessageAttributes map[string]envelopeAttribute `json:",omitempty"`
+}
+
+type envelopeAttribute struct {
+	Type  string
+	Value string
+}
+
+// snsClient implements the SNS operations used by gosqs on top of the transport. Topics are identified by the name in
+// their ARN, regardless of its region and account
+type snsClient struct {
+	snsiface.SNSAPI
+	t *Transport
+}
+
+func (c *snsClient) CreateTopic(in *sns.CreateTopicInput) (*sns.CreateTopicOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	name := aws.StringValue(in.Name)
+	if _, ok := c.t.topics[name]; !ok {
+		c.t.topics[name] = nil
+	}
+	return &sns.CreateTopicOutput{TopicArn: aws.String(topicARN(name))}, nil
+}
+
+func (c *snsClient) TagResource(in *sns.TagResourceInput) (*sns.TagResourceOutput, error) {
+	return &sns.TagResourceOutput{}, nil
+}
+
+func (c *snsClient) Publish(in *sns.PublishInput) (*sns.PublishOutput, error) {
+	return c.PublishWithContext(context.Background(), in)
+}
+
+// PublishWithContext delivers the message to every queue subscribed to the topic. A JSON message structure delivers the
+// "sqs" payload, or the "default" payload if there is none
+func (c *snsClient) PublishWithContext(ctx aws.Context, in *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	arn := aws.StringValue(in.TopicArn)
+	subscriptions, ok := c.t.topics[nameOf(arn)]
+	if !ok {
+		return nil, awserr.New(sns.ErrCodeNotFoundException, "topic does not exist: "+arn, nil)
+	}
+
+	body := aws.StringValue(in.Message)
+	if aws.StringValue(in.MessageStructure) == "json" {
+		var payloads map[string]string
+		if err := json.Unmarshal([]byte(body), &payloads); err != nil {
+			return nil, awserr.New(sns.ErrCodeInvalidParameterException, "invalid message structure", err)
+		}
+
+		body = payloads["default"]
+		if p, ok := payloads["sqs"]; ok {
+			body = p
+		}
+	}
+
+	id := c.t.id("notification")
+	for _, s := range subscriptions {
+		q, ok := c.t.queues[s.queue]
+		if !ok {
+			continue
+		}
+
+		if s.raw {
+			c.t.send(q, body, sqsAttributes(in.MessageAttributes), nil, aws.StringValue(in.MessageGroupId), 0)
+			continue
+		}
+
+		env := envelope{
+			Type:             "Notification",
+			MessageId:        id,
+			TopicArn:         arn,
+			Subject:          aws.StringValue(in.Subject),
+			Message:          body,
+			Timestamp:        c.t.now().UTC().Format("2006-01-02T15:04:05.000Z"),
+			SignatureVersion: "1",
+			UnsubscribeURL:   "https://sns." + Region + ".amazonaws.com/?Action=Unsubscribe",
+		}
+		for k, v := range in.MessageAttributes {
+			if env.MessageAttributes == nil {
+				env.MessageAttributes = map[string]envelopeAttribute{}
+			}
+			env.MessageAttributes[k] = envelopeAttribute{Type: aws.StringValue(v.DataType), Value: aws.StringValue(v.StringValue)}
+		}
+
+		b, err := json.Marshal(env)
+		if err != nil {
+			return nil, err
+		}
+		c.t.send(q, string(b), nil, nil, aws.StringValue(in.MessageGroupId), 0)
+	}
+
+	return &sns.PublishOutput{MessageId: aws.String(id)}, nil
+}
+
+// sqsAttributes converts the attributes of a raw delivery
+func sqsAttributes(in map[string]*sns.MessageAttributeValue) map[string]*sqs.MessageAttributeValue {
+	if len(in) == 0 {
+		return nil
+	}
+
+	out := make(map[string]*sqs.MessageAttributeValue, len(in))
+	for k, v := range in {
+		out[k] = &sqs.MessageAttributeValue{DataType: v.DataType, StringValue: v.StringValue, BinaryValue: v.BinaryValue}
+	}
+	return out
+}
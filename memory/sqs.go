@@ -0,0 +1,465 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// queue holds the messages of a queue in the order they were sent
+type queue struct {
+	name       string
+	url        string
+	attributes map[string]string
+	tags       map[string]string
+	messages   []*entry
+}
+
+// entry is a message stored in a queue
+type entry struct {
+	msg       sqs.Message
+	group     string
+	sent      time.Time
+	visibleAt time.Time
+	receives  int
+	// handle is the receipt handle of the latest receive, it is empty until the message is received
+	handle string
+}
+
+// inFlight reports whether the message was received and its visibility timeout has not expired
+func (e *entry) inFlight(now time.Time) bool {
+	return e.handle != "" && now.Before(e.visibleAt)
+}
+
+func (q *queue) int(name string) int64 {
+	v, _ := strconv.ParseInt(q.attributes[name], 10, 64)
+	return v
+}
+
+func (q *queue) fifo() bool {
+	return strings.HasSuffix(q.name, ".fifo")
+}
+
+// find returns the index of the in flight message of the receipt handle
+func (q *queue) find(handle string) int {
+	for i, e := range q.messages {
+		if e.handle == handle {
+			return i
+		}
+	}
+	return -1
+}
+
+// redrive returns the dead letter queue name and max receive count of the queue's RedrivePolicy
+func (q *queue) redrive() (string, int, bool) {
+	raw, ok := q.attributes[sqs.QueueAttributeNameRedrivePolicy]
+	if !ok || raw == "" {
+		return "", 0, false
+	}
+
+	var policy map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return "", 0, false
+	}
+
+	arn, _ := policy["deadLetterTargetArn"].(string)
+	var max int
+	switch v := policy["maxReceiveCount"].(type) {
+	case string:
+		max, _ = strconv.Atoi(v)
+	case float64:
+		max = int(v)
+	}
+
+	return nameOf(arn), max, arn != "" && max > 0
+}
+
+// sqsClient implements the SQS operations used by gosqs on top of the transport
+type sqsClient struct {
+	sqsiface.SQSAPI
+	t *Transport
+}
+
+func queueDoesNotExist(url string) error {
+	return awserr.New(sqs.ErrCodeQueueDoesNotExist, "the specified queue does not exist: "+url, nil)
+}
+
+func (c *sqsClient) CreateQueue(in *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q := c.t.createQueue(aws.StringValue(in.QueueName), in.Attributes)
+	q.tag(in.Tags)
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(q.url)}, nil
+}
+
+func (c *sqsClient) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queues[aws.StringValue(in.QueueName)]
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueName))
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String(q.url)}, nil
+}
+
+func (c *sqsClient) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	attrs := make(map[string]*string, len(q.attributes)+1)
+	for k, v := range q.attributes {
+		attrs[k] = aws.String(v)
+	}
+	attrs[sqs.QueueAttributeNameApproximateNumberOfMessages] = aws.String(strconv.Itoa(len(q.messages)))
+	return &sqs.GetQueueAttributesOutput{Attributes: attrs}, nil
+}
+
+func (c *sqsClient) SetQueueAttributes(in *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	for k, v := range in.Attributes {
+		q.attributes[k] = aws.StringValue(v)
+	}
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+// tag adds the tags to the queue
+func (q *queue) tag(tags map[string]*string) {
+	if q.tags == nil {
+		q.tags = map[string]string{}
+	}
+	for k, v := range tags {
+		q.tags[k] = aws.StringValue(v)
+	}
+}
+
+func (c *sqsClient) TagQueue(in *sqs.TagQueueInput) (*sqs.TagQueueOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	q.tag(in.Tags)
+	return &sqs.TagQueueOutput{}, nil
+}
+
+func (c *sqsClient) ListQueueTagsWithContext(ctx aws.Context, in *sqs.ListQueueTagsInput, opts ...request.Option) (*sqs.ListQueueTagsOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+	return &sqs.ListQueueTagsOutput{Tags: aws.StringMap(q.tags)}, nil
+}
+
+func (c *sqsClient) PurgeQueue(in *sqs.PurgeQueueInput) (*sqs.PurgeQueueOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	q.messages = nil
+	return &sqs.PurgeQueueOutput{}, nil
+}
+
+func (c *sqsClient) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	return c.SendMessageWithContext(context.Background(), in)
+}
+
+func (c *sqsClient) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	system := map[string]*string{}
+	for k, v := range in.MessageSystemAttributes {
+		system[k] = v.StringValue
+	}
+
+	delay := q.int(sqs.QueueAttributeNameDelaySeconds)
+	if in.DelaySeconds != nil {
+		delay = *in.DelaySeconds
+	}
+
+	e := c.t.send(q, aws.StringValue(in.MessageBody), in.MessageAttributes, system, aws.StringValue(in.MessageGroupId), time.Duration(delay)*time.Second)
+	return &sqs.SendMessageOutput{MessageId: e.msg.MessageId, MD5OfMessageBody: e.msg.MD5OfBody}, nil
+}
+
+func (c *sqsClient) SendMessageBatch(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+	return c.SendMessageBatchWithContext(context.Background(), in)
+}
+
+func (c *sqsClient) SendMessageBatchWithContext(ctx aws.Context, in *sqs.SendMessageBatchInput, opts ...request.Option) (*sqs.SendMessageBatchOutput, error) {
+	out := &sqs.SendMessageBatchOutput{}
+	for _, entry := range in.Entries {
+		sent, err := c.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+			QueueUrl:                in.QueueUrl,
+			MessageBody:             entry.MessageBody,
+			MessageAttributes:       entry.MessageAttributes,
+			MessageSystemAttributes: entry.MessageSystemAttributes,
+			MessageGroupId:          entry.MessageGroupId,
+			DelaySeconds:            entry.DelaySeconds,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		out.Successful = append(out.Successful, &sqs.SendMessageBatchResultEntry{Id: entry.Id, MessageId: sent.MessageId, MD5OfMessageBody: sent.MD5OfMessageBody})
+	}
+	return out, nil
+}
+
+// send appends a message to the queue, the lock must be held
+func (t *Transport) send(q *queue, body string, attributes map[string]*sqs.MessageAttributeValue, system map[string]*string, group string, delay time.Duration) *entry {
+	now := t.now()
+	attrs := map[string]*string{
+		sqs.MessageSystemAttributeNameSentTimestamp: aws.String(strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)),
+	}
+	for k, v := range system {
+		attrs[k] = v
+	}
+	if group != "" {
+		attrs[sqs.MessageSystemAttributeNameMessageGroupId] = aws.String(group)
+	}
+
+	e := &entry{
+		msg: sqs.Message{
+			MessageId:         aws.String(t.id("message")),
+			Body:              aws.String(body),
+			MD5OfBody:         aws.String(md5Of(body)),
+			MessageAttributes: attributes,
+			Attributes:        attrs,
+		},
+		group:     group,
+		sent:      now,
+		visibleAt: now.Add(delay),
+	}
+	q.messages = append(q.messages, e)
+	return e
+}
+
+func (c *sqsClient) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	return c.ReceiveMessageWithContext(context.Background(), in)
+}
+
+// ReceiveMessageWithContext long polls for the queue's ReceiveMessageWaitTimeSeconds unless the input sets WaitTimeSeconds
+func (c *sqsClient) ReceiveMessageWithContext(ctx aws.Context, in *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	c.t.mu.Lock()
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		c.t.mu.Unlock()
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	wait := q.int(sqs.QueueAttributeNameReceiveMessageWaitTimeSeconds)
+	c.t.mu.Unlock()
+
+	if in.WaitTimeSeconds != nil {
+		wait = *in.WaitTimeSeconds
+	}
+
+	deadline := time.NewTimer(time.Duration(wait) * time.Second)
+	defer deadline.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if messages := c.t.receive(q, in); len(messages) > 0 || wait <= 0 {
+			return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+		case <-deadline.C:
+			return &sqs.ReceiveMessageOutput{}, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// receive claims up to MaxNumberOfMessages visible messages. Messages that exceed the maxReceiveCount of the RedrivePolicy
+// are moved to the dead letter queue and messages of a FIFO group with a message in flight are held back
+func (t *Transport) receive(q *queue, in *sqs.ReceiveMessageInput) []*sqs.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	max := int(aws.Int64Value(in.MaxNumberOfMessages))
+	if max <= 0 {
+		max = 1
+	}
+
+	visibility := q.int(sqs.QueueAttributeNameVisibilityTimeout)
+	if in.VisibilityTimeout != nil {
+		visibility = *in.VisibilityTimeout
+	}
+
+	now := t.now()
+	dlq, maxReceives, redrive := q.redrive()
+	blocked := map[string]bool{}
+
+	var out []*sqs.Message
+	kept := q.messages[:0]
+	for _, e := range q.messages {
+		if len(out) >= max || now.Before(e.visibleAt) || (q.fifo() && blocked[e.group]) {
+			if q.fifo() && e.group != "" {
+				blocked[e.group] = true
+			}
+			kept = append(kept, e)
+			continue
+		}
+
+		if redrive && e.receives >= maxReceives {
+			if target, ok := t.queues[dlq]; ok {
+				e.handle = ""
+				e.visibleAt = now
+				target.messages = append(target.messages, e)
+				continue
+			}
+		}
+
+		e.receives++
+		e.handle = t.id("handle")
+		e.visibleAt = now.Add(time.Duration(visibility) * time.Second)
+		e.msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String(strconv.Itoa(e.receives))
+		if e.receives == 1 {
+			e.msg.Attributes[sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp] = aws.String(strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10))
+		}
+		if q.fifo() && e.group != "" {
+			blocked[e.group] = true
+		}
+
+		msg := e.msg
+		msg.ReceiptHandle = aws.String(e.handle)
+		msg.Attributes = copyStrings(e.msg.Attributes)
+		out = append(out, &msg)
+		kept = append(kept, e)
+	}
+	q.messages = kept
+
+	return out
+}
+
+func copyStrings(in map[string]*string) map[string]*string {
+	out := make(map[string]*string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func receiptHandleIsInvalid(handle string) error {
+	return awserr.New(sqs.ErrCodeReceiptHandleIsInvalid, "the receipt handle is not valid: "+handle, nil)
+}
+
+func (c *sqsClient) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	return c.DeleteMessageWithContext(context.Background(), in)
+}
+
+func (c *sqsClient) DeleteMessageWithContext(ctx aws.Context, in *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	i := q.find(aws.StringValue(in.ReceiptHandle))
+	if i < 0 {
+		return nil, receiptHandleIsInvalid(aws.StringValue(in.ReceiptHandle))
+	}
+
+	q.messages = append(q.messages[:i], q.messages[i+1:]...)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (c *sqsClient) DeleteMessageBatch(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, entry := range in.Entries {
+		if _, err := c.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: in.QueueUrl, ReceiptHandle: entry.ReceiptHandle}); err != nil {
+			out.Failed = append(out.Failed, batchError(entry.Id, err))
+			continue
+		}
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+	return out, nil
+}
+
+func (c *sqsClient) ChangeMessageVisibility(in *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+
+	q, ok := c.t.queue(aws.StringValue(in.QueueUrl))
+	if !ok {
+		return nil, queueDoesNotExist(aws.StringValue(in.QueueUrl))
+	}
+
+	now := c.t.now()
+	i := q.find(aws.StringValue(in.ReceiptHandle))
+	if i < 0 {
+		return nil, receiptHandleIsInvalid(aws.StringValue(in.ReceiptHandle))
+	}
+
+	e := q.messages[i]
+	if !e.inFlight(now) {
+		return nil, awserr.New(sqs.ErrCodeMessageNotInflight, "the message is not in flight", nil)
+	}
+
+	e.visibleAt = now.Add(time.Duration(aws.Int64Value(in.VisibilityTimeout)) * time.Second)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (c *sqsClient) ChangeMessageVisibilityBatch(in *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	out := &sqs.ChangeMessageVisibilityBatchOutput{}
+	for _, entry := range in.Entries {
+		if _, err := c.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{QueueUrl: in.QueueUrl, ReceiptHandle: entry.ReceiptHandle, VisibilityTimeout: entry.VisibilityTimeout}); err != nil {
+			out.Failed = append(out.Failed, batchError(entry.Id, err))
+			continue
+		}
+		out.Successful = append(out.Successful, &sqs.ChangeMessageVisibilityBatchResultEntry{Id: entry.Id})
+	}
+	return out, nil
+}
+
+// batchError reports a failed batch entry
+func batchError(id *string, err error) *sqs.BatchResultErrorEntry {
+	code := "InternalError"
+	if aerr, ok := err.(awserr.Error); ok {
+		code = aerr.Code()
+	}
+	return &sqs.BatchResultErrorEntry{Id: id, Code: aws.String(code), Message: aws.String(err.Error()), SenderFault: aws.Bool(true)}
+}
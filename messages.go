@@ -1,9 +1,17 @@
 package gosqs
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sqs"
 )
 
@@ -11,23 +19,162 @@ import (
 type Message interface {
 	// Route returns the event name that is used for routing within a worker, e.g. post_published
 	Route() string
-	// Decode will unmarshal the message into a supplied output using json
+	// Decode will unmarshal the message into a supplied output using json. Failures wrap ErrDecode, returning them from the
+	// handler applies Config.OnDecodeErrorAction
 	Decode(out interface{}) error
+	// DecodeContext will unmarshal the message like Decode but stops with the context's error once it is cancelled
+	DecodeContext(ctx context.Context, out interface{}) error
+	// RawBody returns the body without decoding it, unwrapped from the SNS envelope and decompressed. Failures wrap ErrDecode
+	RawBody() ([]byte, error)
 	// DecodeModified is used for decoding the modification message, it will populate the body with the actual message and a
 	// map[string]interface{} to view original values from that message
 	DecodeModified(out interface{}, changes interface{}) error
 	// Attribute will return the custom attribute that was sent through out the request.
 	Attribute(key string) string
+	// AttributeRaw will return the full data type along with the value of the custom attribute, e.g "String.Array".
+	// ok is false if the attribute was not sent with the request
+	AttributeRaw(key string) (dataType string, value string, ok bool)
+	// SentTime returns the time the message was sent to the queue, it is the zero time if SentTimestamp was not received
+	SentTime() time.Time
+	// PublishTime returns the Timestamp of the SNS envelope, when SNS accepted the message. It is the zero time for raw
+	// deliveries, messages sent to the queue directly and envelopes with a missing or malformed Timestamp
+	PublishTime() time.Time
+	// FirstReceiveTime returns when the message was first received from the queue, it is the zero time if
+	// ApproximateFirstReceiveTimestamp was not received
+	FirstReceiveTime() time.Time
+	// TraceHeaders returns the propagation headers that were sent with gosqs.WithTraceHeaders, or nil if there are none
+	TraceHeaders() map[string]string
+	// Subject returns the Subject of a message delivered through SNS without raw message delivery, or an empty string
+	Subject() string
+	// TraceHeader returns the AWSTraceHeader system attribute holding the X-Ray trace context, or an empty string
+	TraceHeader() string
+	// CorrelationID returns the correlation ID the message was sent with, or an empty string
+	CorrelationID() string
+	// ReplyTo returns the url of the queue the sender expects a reply on, or an empty string. See WithReplyTo
+	ReplyTo() string
+	// SchemaVersion returns the schema version the message was sent with, or an empty string. See WithSchemaVersion
+	SchemaVersion() string
+	// TopicARN returns the ARN of the topic a message was delivered from without raw message delivery, or an empty string
+	TopicARN() string
+	// SNSEnvelope returns the full SNS envelope of a message delivered through SNS without raw message delivery, e.g. for
+	// audit logging. ok is false for raw deliveries and messages sent to the queue directly
+	SNSEnvelope() (envelope *SNSEnvelope, ok bool)
 }
 
 // message serves as a wrapper for sqs.Message as well as controls the error handling channel
 type message struct {
+	// decodeTime is the total time spent in Decode in nanoseconds, it is first to keep it aligned for atomic operations
+	decodeTime int64
 	*sqs.Message
-	err chan error
+	err   chan error
+	route string
+	// envelope is set when the message was delivered through SNS without raw message delivery, envelopeBody is the
+	// envelope as received for verifying its signature
+	envelope     *SNSEnvelope
+	envelopeBody string
+
+	correlationID string
+	// codec decodes the body of messages with a content-type attribute, JSON is used when the message has none
+	contentType string
+	codec       Codec
+	// visibility is the timeout set from Config.RouteVisibility after the receive, 0 if the message kept the consumer's
+	visibility int
+	// receivedAt is when the receive returned the message
+	receivedAt time.Time
+	// bodyErr is set when an offloaded body could not be downloaded or a compressed body could not be decompressed
+	bodyErr error
+	// group is the key DispatchByGroup serializes the message by
+	group string
+	// batch is set when the consumer withholds deletes until every message received together succeeded
+	batch *receivedBatch
+	// probe is set on the message received by a half-open circuit breaker
+	probe bool
+	// load downloads an offloaded body, it runs once when the body is first needed
+	load     func(context.Context)
+	loadOnce sync.Once
+	// owner is the consumer running the handler, pending is set once the handler deferred the message with gosqs.Defer
+	owner   *consumer
+	pending *PendingMessage
+}
+
+// SNSEnvelope is the JSON document SNS wraps around a message when raw message delivery is disabled
+type SNSEnvelope struct {
+	Type string
+	// MessageId is assigned by SNS and is the same for every delivery of a notification
+	MessageId        string
+	TopicArn         string
+	Subject          string
+	Message          string
+	Timestamp        time.Time
+	SignatureVersion string
+	Signature        string
+	SigningCertURL   string
+	UnsubscribeURL   string
+	// MessageAttributes holds the attributes the message was published with
+	MessageAttributes map[string]SNSEnvelopeAttribute
+}
+
+// SNSEnvelopeAttribute is a message attribute of the SNS envelope
+type SNSEnvelopeAttribute struct {
+	Type  string
+	Value string
 }
 
 func newMessage(m *sqs.Message) *message {
-	return &message{m, make(chan error, 1)}
+	msg := &message{Message: m, err: make(chan error, 1)}
+	msg.unwrap()
+
+	if r, ok := msg.MessageAttributes["route"]; ok && r.StringValue != nil {
+		msg.route = *r.StringValue
+	}
+
+	return msg
+}
+
+// unwrap replaces the SNS envelope with the message it carries, keeping the subject and moving the SNS message attributes
+// onto the message. Attributes sent on the SQS message itself take precedence. The underlying sqs.Message is copied so the
+// original remains untouched
+func (m *message) unwrap() {
+	if m.Body == nil || !strings.HasPrefix(strings.TrimSpace(*m.Body), "{") {
+		return
+	}
+
+	// the Timestamp is parsed separately so a malformed one does not keep the message from being unwrapped
+	var raw struct {
+		SNSEnvelope
+		Timestamp string
+	}
+	if err := json.Unmarshal([]byte(*m.Body), &raw); err != nil || raw.Type != "Notification" || raw.TopicArn == "" {
+		return
+	}
+
+	env := raw.SNSEnvelope
+	if ts, err := time.Parse(time.RFC3339Nano, raw.Timestamp); err == nil {
+		env.Timestamp = ts
+	}
+
+	cp := *m.Message
+	cp.Body = &env.Message
+	cp.MessageAttributes = make(map[string]*sqs.MessageAttributeValue, len(m.MessageAttributes)+len(env.MessageAttributes))
+	for k, v := range env.MessageAttributes {
+		cp.MessageAttributes[k] = &sqs.MessageAttributeValue{DataType: aws.String(v.Type), StringValue: aws.String(v.Value)}
+	}
+	for k, v := range m.MessageAttributes {
+		cp.MessageAttributes[k] = v
+	}
+
+	m.envelopeBody = *m.Body
+	m.Message = &cp
+	m.envelope = &env
+}
+
+// loadBody downloads an offloaded body if it was not yet, the context bounds the download. The consumer loads the body
+// before the handler runs, the accessors of the body load it for messages that are not run by the consumer, e.g. a
+// message of PeekMessage
+func (m *message) loadBody(ctx context.Context) {
+	if m.load != nil {
+		m.loadOnce.Do(func() { m.load(ctx) })
+	}
 }
 
 func (m *message) body() []byte {
@@ -36,12 +183,88 @@ func (m *message) body() []byte {
 
 // Route returns the event name that is used for routing within a worker, e.g. post_published
 func (m *message) Route() string {
-	return *m.MessageAttributes["route"].StringValue
+	return m.route
 }
 
-// Decode will unmarshal the message into a supplied output using json
+// routeFromBody reads the route from a top level string field of the JSON body
+func (m *message) routeFromBody(field string) string {
+	m.loadBody(context.Background())
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(m.body(), &fields); err != nil {
+		return ""
+	}
+
+	var route string
+	if err := json.Unmarshal(fields[field], &route); err != nil {
+		return ""
+	}
+
+	return route
+}
+
+// Decode will unmarshal the message into a supplied output using json, or the codec registered for the message's content-type
 func (m *message) Decode(out interface{}) error {
-	return json.Unmarshal(m.body(), &out)
+	defer m.timeDecode(time.Now())
+	return m.decode(out)
+}
+
+// decode is Decode without timing it
+func (m *message) decode(out interface{}) error {
+	m.loadBody(context.Background())
+	if m.bodyErr != nil {
+		return decodeErr(m.bodyErr)
+	}
+
+	if m.contentType != "" {
+		if m.codec == nil {
+			return ErrDecode.Context(ErrUnknownContentType.Context(fmt.Errorf("%s", m.contentType)))
+		}
+		return decodeErr(m.codec.Unmarshal(m.body(), out))
+	}
+
+	return decodeErr(json.Unmarshal(m.body(), &out))
+}
+
+// DecodeContext will unmarshal the message like Decode but stops with the context's error once it is cancelled, keeping a
+// slow decode of a large body from holding the worker past the handler's deadline
+func (m *message) DecodeContext(ctx context.Context, out interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer m.timeDecode(time.Now())
+	m.loadBody(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if m.contentType != "" || m.bodyErr != nil {
+		return m.decode(out)
+	}
+
+	if err := json.NewDecoder(&contextReader{ctx: ctx, r: bytes.NewReader(m.body())}).Decode(&out); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return decodeErr(err)
+	}
+
+	return nil
+}
+
+// contextReader fails reads once the context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
 }
 
 // DecodeModified is used for decoding the modification message, it will populate the body with the actual message and a
@@ -79,10 +302,109 @@ func (m *message) Success(ctx context.Context) error {
 
 // Attribute will return the attrubute that was sent with the request.
 func (m *message) Attribute(key string) string {
-	id, ok := m.MessageAttributes[key]
-	if !ok {
+	_, val, _ := m.AttributeRaw(key)
+	return val
+}
+
+// AttributeRaw will return the full data type along with the value of the custom attribute, e.g "String.Array".
+// ok is false if the attribute was not sent with the request
+func (m *message) AttributeRaw(key string) (string, string, bool) {
+	attr, ok := m.MessageAttributes[key]
+	if !ok || attr == nil {
+		return "", "", false
+	}
+
+	var dataType, val string
+	if attr.DataType != nil {
+		dataType = *attr.DataType
+	}
+
+	if attr.StringValue != nil {
+		val = *attr.StringValue
+	}
+
+	return dataType, val, true
+}
+
+// SentTime returns the time the message was sent to the queue, it is the zero time if SentTimestamp was not received
+func (m *message) SentTime() time.Time {
+	return m.systemTime(sqs.MessageSystemAttributeNameSentTimestamp)
+}
+
+// PublishTime returns the Timestamp of the SNS envelope, it is the zero time if the message was not delivered in an envelope
+// or the Timestamp is missing or malformed
+func (m *message) PublishTime() time.Time {
+	if m.envelope == nil {
+		return time.Time{}
+	}
+	return m.envelope.Timestamp
+}
+
+// TraceHeader returns the AWSTraceHeader system attribute holding the X-Ray trace context, or an empty string
+func (m *message) TraceHeader() string {
+	if v, ok := m.Attributes[sqs.MessageSystemAttributeNameAwstraceHeader]; ok && v != nil {
+		return *v
+	}
+
+	return ""
+}
+
+// FirstReceiveTime returns when the message was first received from the queue, it is the zero time if
+// ApproximateFirstReceiveTimestamp was not received
+func (m *message) FirstReceiveTime() time.Time {
+	return m.systemTime(sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp)
+}
+
+// systemTime parses a system attribute holding epoch milliseconds
+func (m *message) systemTime(name string) time.Time {
+	v, ok := m.Attributes[name]
+	if !ok || v == nil {
+		return time.Time{}
+	}
+
+	ms, err := strconv.ParseInt(*v, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// TraceHeaders returns the propagation headers that were sent with gosqs.WithTraceHeaders, or nil if there are none
+func (m *message) TraceHeaders() map[string]string {
+	var headers map[string]string
+	for k, v := range m.MessageAttributes {
+		if !strings.HasPrefix(k, traceHeaderPrefix) || v == nil || v.StringValue == nil {
+			continue
+		}
+
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[strings.TrimPrefix(k, traceHeaderPrefix)] = *v.StringValue
+	}
+
+	return headers
+}
+
+// Subject returns the Subject of a message delivered through SNS without raw message delivery, or an empty string
+func (m *message) Subject() string {
+	if m.envelope == nil {
 		return ""
 	}
+	return m.envelope.Subject
+}
+
+// TopicARN returns the ARN of the topic a message was delivered from without raw message delivery, or an empty string
+func (m *message) TopicARN() string {
+	if m.envelope == nil {
+		return ""
+	}
+	return m.envelope.TopicArn
+}
 
-	return *id.StringValue
+// SNSEnvelope returns the SNS envelope of a message delivered through SNS without raw message delivery, ok is false for
+// raw deliveries and messages sent to the queue directly
+func (m *message) SNSEnvelope() (envelope *SNSEnvelope, ok bool) {
+	return m.envelope, m.envelope != nil
 }
@@ -0,0 +1,148 @@
+package gosqs
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestAttributeRaw(t *testing.T) {
+	dt := "String.Array"
+	val := `["a","b"]`
+	body := "{}"
+	m := newMessage(&sqs.Message{
+		Body: &body,
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"tags": {DataType: &dt, StringValue: &val},
+		},
+	})
+
+	gotType, gotVal, ok := m.AttributeRaw("tags")
+	if !ok || gotType != dt || gotVal != val {
+		t.Errorf("unexpected result, expected (%s, %s, true), got (%s, %s, %v)", dt, val, gotType, gotVal, ok)
+	}
+
+	if m.Attribute("tags") != val {
+		t.Errorf("unexpected attribute value, expected %s, got %s", val, m.Attribute("tags"))
+	}
+
+	if _, _, ok := m.AttributeRaw("missing"); ok {
+		t.Error("expected missing attribute to return false")
+	}
+}
+
+func TestSNSEnvelope(t *testing.T) {
+	t.Run("unwraps_notification", func(t *testing.T) {
+		body := `{"Type":"Notification","TopicArn":"arn:aws:sns:local:000000000000:todolist-dev","Subject":"urgent","Message":"{\"val\":\"a\"}","MessageAttributes":{"route":{"Type":"String","Value":"post_created"}}}`
+		raw := &sqs.Message{Body: &body}
+		m := newMessage(raw)
+
+		if m.Subject() != "urgent" {
+			t.Errorf("unexpected subject, got %q", m.Subject())
+		}
+
+		if m.Route() != "post_created" {
+			t.Errorf("expected the route from the envelope, got %q", m.Route())
+		}
+
+		var out sample
+		if err := m.Decode(&out); err != nil || out.Val != "a" {
+			t.Errorf("expected the inner message to be decoded, got %+v, %v", out, err)
+		}
+
+		if *raw.Body != body {
+			t.Error("expected the received message to remain untouched")
+		}
+	})
+
+	t.Run("exposes_envelope", func(t *testing.T) {
+		body := `{"Type":"Notification","MessageId":"sns-1","TopicArn":"arn:aws:sns:local:000000000000:todolist-dev","Message":"{}","Timestamp":"2021-03-01T12:00:00.000Z","SignatureVersion":"1","Signature":"c2ln","SigningCertURL":"https://sns.us-east-1.amazonaws.com/cert.pem","UnsubscribeURL":"https://sns.us-east-1.amazonaws.com/unsubscribe"}`
+		m := newMessage(&sqs.Message{Body: &body})
+
+		env, ok := m.SNSEnvelope()
+		if !ok {
+			t.Fatal("expected the envelope of an SNS delivery")
+		}
+
+		if env.MessageId != "sns-1" || env.Signature != "c2ln" || env.SigningCertURL != "https://sns.us-east-1.amazonaws.com/cert.pem" || env.SignatureVersion != "1" {
+			t.Errorf("unexpected envelope, got %+v", env)
+		}
+
+		if !env.Timestamp.Equal(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected timestamp, got %s", env.Timestamp)
+		}
+	})
+
+	t.Run("raw_delivery", func(t *testing.T) {
+		body := `{"Type":"Notification","val":"a"}`
+		m := newMessage(&sqs.Message{Body: &body})
+
+		if m.Subject() != "" || string(m.body()) != body {
+			t.Errorf("expected the body to be left as is, got %s", m.body())
+		}
+
+		if _, ok := m.SNSEnvelope(); ok {
+			t.Error("expected no envelope for a raw delivery")
+		}
+	})
+}
+
+func TestDecodeContext(t *testing.T) {
+	body := `{"val":"a"}`
+	m := newMessage(&sqs.Message{Body: &body})
+
+	var out sample
+	if err := m.DecodeContext(context.Background(), &out); err != nil || out.Val != "a" {
+		t.Fatalf("unexpected result, got %+v, %v", out, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.DecodeContext(ctx, &out); err != context.Canceled {
+		t.Errorf("expected the cancellation error, got %v", err)
+	}
+}
+
+func TestPublishTime(t *testing.T) {
+	envelope := func(timestamp string) *message {
+		body := `{"Type":"Notification","TopicArn":"arn:aws:sns:local:000000000000:todolist-dev","Message":"{}","Timestamp":` + timestamp + `,"MessageAttributes":{"route":{"Type":"String","Value":"post_created"}}}`
+		return newMessage(&sqs.Message{Body: &body})
+	}
+
+	if got := envelope(`"2021-03-01T12:00:00.123Z"`).PublishTime(); !got.Equal(time.Date(2021, 3, 1, 12, 0, 0, 123000000, time.UTC)) {
+		t.Errorf("unexpected publish time, got %s", got)
+	}
+
+	malformed := envelope(`"yesterday"`)
+	if !malformed.PublishTime().IsZero() || malformed.Route() != "post_created" {
+		t.Errorf("expected a malformed timestamp to be ignored while the message is unwrapped, got %s, %q", malformed.PublishTime(), malformed.Route())
+	}
+
+	if raw := newMessage(&sqs.Message{Body: aws.String("{}")}); !raw.PublishTime().IsZero() {
+		t.Errorf("expected the zero time for raw deliveries, got %s", raw.PublishTime())
+	}
+}
+
+func TestSortByPublishTime(t *testing.T) {
+	m := newMockSQS()
+	for _, ts := range []string{"2021-03-01T12:00:03Z", "2021-03-01T12:00:01Z", "2021-03-01T12:00:02Z"} {
+		raw := m.add("queue", "", `{"Type":"Notification","TopicArn":"arn:aws:sns:local:000000000000:todolist-dev","Message":"`+ts+`","Timestamp":"`+ts+`","MessageAttributes":{"route":{"Type":"String","Value":"post_created"}}}`)
+		delete(raw.MessageAttributes, "route")
+	}
+	direct := m.add("queue", "post_created", "direct")
+	direct.Attributes[sqs.MessageSystemAttributeNameSentTimestamp] = aws.String(strconv.FormatInt(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), 10))
+
+	var order []string
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, sortByPublishTime: true}
+	c.poll(func(msg *message) { order = append(order, string(msg.body())) })
+
+	want := []string{"direct", "2021-03-01T12:00:01Z", "2021-03-01T12:00:02Z", "2021-03-01T12:00:03Z"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("expected the messages in publish order, got %v", order)
+	}
+}
@@ -0,0 +1,56 @@
+package gosqs
+
+import (
+	"time"
+)
+
+// MessageMetrics describes a single handler run, it is passed to the MetricsHook
+type MessageMetrics struct {
+	// Route is the event the message was routed with, e.g. post_created
+	Route string
+	// MessageID is the SQS message ID
+	MessageID string
+	// Duration is how long the handler ran
+	Duration time.Duration
+	// Slow is true when the handler exceeded Config.SlowHandlerThreshold
+	Slow bool
+	// Err is the error returned by the handler, nil if it succeeded
+	Err error
+	// QueueWait is the time between sending and the first receive of the message, it is 0 if SentTimestamp or
+	// ApproximateFirstReceiveTimestamp were not received
+	QueueWait time.Duration
+	// RedeliveryDelay is the time between the first receive and the start of this handler run, it is about 0 for the first
+	// delivery and grows with every redelivery
+	RedeliveryDelay time.Duration
+}
+
+// MetricsHookFunc receives the metrics of every handler run. It is called from the worker goroutines and must be safe for
+// concurrent use, slow hooks delay the processing of the next message
+type MetricsHookFunc func(MessageMetrics)
+
+// observe reports a finished handler run to the metrics hook and logs slow handlers
+func (c *consumer) observe(m *message, d time.Duration, err error) {
+	var id string
+	if m.MessageId != nil {
+		id = *m.MessageId
+	}
+
+	slow := c.slowHandler > 0 && d > c.slowHandler
+	if slow {
+		c.logMessage(m, ErrSlowHandler.Error(), m.Route(), id, d)
+	}
+
+	if c.metricsHook == nil {
+		return
+	}
+
+	metrics := MessageMetrics{Route: m.Route(), MessageID: id, Duration: d, Slow: slow, Err: err}
+	if first := m.FirstReceiveTime(); !first.IsZero() {
+		if sent := m.SentTime(); !sent.IsZero() {
+			metrics.QueueWait = first.Sub(sent)
+		}
+		metrics.RedeliveryDelay = time.Since(first) - d
+	}
+
+	c.metricsHook(metrics)
+}
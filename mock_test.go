@@ -0,0 +1,340 @@
+package gosqs
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// mockSQS is an in-memory stand-in for the sqs client, used by tests that do not need the emulator.
+// Only the methods the tests exercise are overridden, calling any other method will panic
+type mockSQS struct {
+	sqsiface.SQSAPI
+
+	mu         sync.Mutex
+	attributes map[string]*string
+	setInputs  []*sqs.SetQueueAttributesInput
+
+	// queues holds the visible messages of each queue url, received messages are moved to inflight until deleted
+	queues       map[string][]*sqs.Message
+	inflight     map[string]*sqs.Message
+	deleted      []string
+	sent         []*sqs.SendMessageInput
+	visibilities map[string]int64
+	// visibilityBatches counts the ChangeMessageVisibilityBatch calls
+	visibilityBatches int
+	receives          int
+	nextID            int
+
+	// receiveErrs are returned by the next ReceiveMessage calls in order
+	receiveErrs []error
+
+	// failBatch makes SendMessageBatch report the entries with these ids as failed
+	failBatch map[string]bool
+
+	// urls maps existing queue names to their url, unknown names return QueueDoesNotExist
+	urls    map[string]string
+	lookups []*sqs.GetQueueUrlInput
+	created []*sqs.CreateQueueInput
+	tagged  []*sqs.TagQueueInput
+	// queueTags are returned by ListQueueTags
+	queueTags map[string]string
+}
+
+func newMockSQS() *mockSQS {
+	return &mockSQS{
+		queues:       map[string][]*sqs.Message{},
+		inflight:     map[string]*sqs.Message{},
+		visibilities: map[string]int64{},
+	}
+}
+
+// add places a new message on the queue and returns it so tests can adjust it further
+func (m *mockSQS) add(queueURL, route, body string) *sqs.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	id := strconv.Itoa(m.nextID)
+	msg := &sqs.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String("receipt-" + id),
+		Body:          aws.String(body),
+		Attributes:    map[string]*string{},
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"route": {DataType: aws.String("String"), StringValue: aws.String(route)},
+		},
+	}
+	m.queues[queueURL] = append(m.queues[queueURL], msg)
+	return msg
+}
+
+func (m *mockSQS) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	m.lookups = append(m.lookups, in)
+	u, ok := m.urls[*in.QueueName]
+	if !ok {
+		return nil, awserr.New(sqs.ErrCodeQueueDoesNotExist, "queue does not exist", nil)
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: &u}, nil
+}
+
+func (m *mockSQS) CreateQueue(in *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+	m.created = append(m.created, in)
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String("http://localhost:4100/" + *in.QueueName)}, nil
+}
+
+func (m *mockSQS) TagQueue(in *sqs.TagQueueInput) (*sqs.TagQueueOutput, error) {
+	m.tagged = append(m.tagged, in)
+	return &sqs.TagQueueOutput{}, nil
+}
+
+func (m *mockSQS) ListQueueTagsWithContext(ctx aws.Context, in *sqs.ListQueueTagsInput, opts ...request.Option) (*sqs.ListQueueTagsOutput, error) {
+	return &sqs.ListQueueTagsOutput{Tags: aws.StringMap(m.queueTags)}, nil
+}
+
+func (m *mockSQS) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: m.attributes}, nil
+}
+
+func (m *mockSQS) SetQueueAttributes(in *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	m.setInputs = append(m.setInputs, in)
+	if m.attributes == nil {
+		m.attributes = map[string]*string{}
+	}
+	for k, v := range in.Attributes {
+		m.attributes[k] = v
+	}
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (m *mockSQS) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.receives++
+	if len(m.receiveErrs) > 0 {
+		err := m.receiveErrs[0]
+		m.receiveErrs = m.receiveErrs[1:]
+		return nil, err
+	}
+
+	max := 10
+	if in.MaxNumberOfMessages != nil {
+		max = int(*in.MaxNumberOfMessages)
+	}
+
+	q := m.queues[*in.QueueUrl]
+	if len(q) < max {
+		max = len(q)
+	}
+
+	out := make([]*sqs.Message, 0, max)
+	for _, msg := range q[:max] {
+		m.inflight[*msg.ReceiptHandle] = msg
+		out = append(out, requested(msg, in))
+	}
+	m.queues[*in.QueueUrl] = q[max:]
+
+	return &sqs.ReceiveMessageOutput{Messages: out}, nil
+}
+
+// requested returns a copy of the message with only the attributes named by the receive like SQS does, names ending in
+// .* request every attribute with the prefix. Receives without names return every attribute
+func requested(msg *sqs.Message, in *sqs.ReceiveMessageInput) *sqs.Message {
+	match := func(names []*string, name string) bool {
+		if names == nil {
+			return true
+		}
+
+		for _, n := range aws.StringValueSlice(names) {
+			if n == all || n == name || (strings.HasSuffix(n, ".*") && strings.HasPrefix(name, strings.TrimSuffix(n, ".*"))) {
+				return true
+			}
+		}
+		return false
+	}
+
+	cp := *msg
+	cp.Attributes = map[string]*string{}
+	for k, v := range msg.Attributes {
+		if match(in.AttributeNames, k) {
+			cp.Attributes[k] = v
+		}
+	}
+
+	cp.MessageAttributes = map[string]*sqs.MessageAttributeValue{}
+	for k, v := range msg.MessageAttributes {
+		if match(in.MessageAttributeNames, k) {
+			cp.MessageAttributes[k] = v
+		}
+	}
+	return &cp
+}
+
+// ReceiveMessageWithContext runs the complete handlers of the request options with a request ID of "request-<receives>"
+func (m *mockSQS) ReceiveMessageWithContext(ctx aws.Context, in *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	out, err := m.ReceiveMessage(in)
+
+	m.mu.Lock()
+	r := &request.Request{RequestID: "request-" + strconv.Itoa(m.receives)}
+	m.mu.Unlock()
+
+	r.ApplyOptions(opts...)
+	r.Handlers.Complete.Run(r)
+	return out, err
+}
+
+func (m *mockSQS) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.inflight, *in.ReceiptHandle)
+	m.deleted = append(m.deleted, *in.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (m *mockSQS) DeleteMessageWithContext(ctx aws.Context, in *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.DeleteMessage(in)
+}
+
+func (m *mockSQS) DeleteMessageBatch(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, e := range in.Entries {
+		m.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: in.QueueUrl, ReceiptHandle: e.ReceiptHandle})
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: e.Id})
+	}
+	return out, nil
+}
+
+func (m *mockSQS) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	m.mu.Lock()
+	m.sent = append(m.sent, in)
+	m.mu.Unlock()
+
+	var route string
+	if r, ok := in.MessageAttributes["route"]; ok && r.StringValue != nil {
+		route = *r.StringValue
+	}
+	msg := m.add(*in.QueueUrl, route, *in.MessageBody)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg.MessageAttributes = in.MessageAttributes
+	for k, v := range in.MessageSystemAttributes {
+		msg.Attributes[k] = v.StringValue
+	}
+	return &sqs.SendMessageOutput{MessageId: msg.MessageId}, nil
+}
+
+func (m *mockSQS) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	return m.SendMessage(in)
+}
+
+func (m *mockSQS) SendMessageBatch(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+	out := &sqs.SendMessageBatchOutput{}
+	for _, e := range in.Entries {
+		if m.failBatch[*e.Id] {
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InvalidParameterValue"), SenderFault: aws.Bool(true)})
+			continue
+		}
+
+		resp, _ := m.SendMessage(&sqs.SendMessageInput{QueueUrl: in.QueueUrl, MessageBody: e.MessageBody, MessageAttributes: e.MessageAttributes})
+		out.Successful = append(out.Successful, &sqs.SendMessageBatchResultEntry{Id: e.Id, MessageId: resp.MessageId})
+	}
+	return out, nil
+}
+
+func (m *mockSQS) SendMessageBatchWithContext(ctx aws.Context, in *sqs.SendMessageBatchInput, opts ...request.Option) (*sqs.SendMessageBatchOutput, error) {
+	return m.SendMessageBatch(in)
+}
+
+func (m *mockSQS) ChangeMessageVisibility(in *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.visibilities[*in.ReceiptHandle] = *in.VisibilityTimeout
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (m *mockSQS) ChangeMessageVisibilityBatch(in *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.visibilityBatches++
+	out := &sqs.ChangeMessageVisibilityBatchOutput{}
+	for _, e := range in.Entries {
+		if _, ok := m.inflight[*e.ReceiptHandle]; !ok {
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: e.Id, Code: aws.String(sqs.ErrCodeReceiptHandleIsInvalid), SenderFault: aws.Bool(true)})
+			continue
+		}
+
+		m.visibilities[*e.ReceiptHandle] = *e.VisibilityTimeout
+		out.Successful = append(out.Successful, &sqs.ChangeMessageVisibilityBatchResultEntry{Id: e.Id})
+	}
+	return out, nil
+}
+
+func (m *mockSQS) ChangeMessageVisibilityWithContext(ctx aws.Context, in *sqs.ChangeMessageVisibilityInput, opts ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return m.ChangeMessageVisibility(in)
+}
+
+// mockSNS records published messages in memory
+type mockSNS struct {
+	snsiface.SNSAPI
+
+	mu        sync.Mutex
+	published []*sns.PublishInput
+	created   []*sns.CreateTopicInput
+	tagged    []*sns.TagResourceInput
+
+	// publishErr is returned by every Publish call when set
+	publishErr error
+}
+
+func (m *mockSNS) CreateTopic(in *sns.CreateTopicInput) (*sns.CreateTopicOutput, error) {
+	m.created = append(m.created, in)
+	return &sns.CreateTopicOutput{TopicArn: aws.String("arn:aws:sns:local:000000000000:" + *in.Name)}, nil
+}
+
+func (m *mockSNS) TagResource(in *sns.TagResourceInput) (*sns.TagResourceOutput, error) {
+	m.tagged = append(m.tagged, in)
+	return &sns.TagResourceOutput{}, nil
+}
+
+func (m *mockSNS) Publish(in *sns.PublishInput) (*sns.PublishOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.published = append(m.published, in)
+	if m.publishErr != nil {
+		return nil, m.publishErr
+	}
+	return &sns.PublishOutput{MessageId: aws.String(strconv.Itoa(len(m.published)))}, nil
+}
+
+func (m *mockSNS) PublishWithContext(ctx aws.Context, in *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	return m.Publish(in)
+}
+
+// testLogger keeps logged lines in memory so tests can make assertions about them
+type testLogger struct {
+	mu    sync.Mutex
+	lines [][]interface{}
+}
+
+func (l *testLogger) Println(v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, v)
+}
@@ -0,0 +1,217 @@
+package gosqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	// offloadSizeAttribute carries the size of a body that was offloaded to S3, it is the attribute of the AWS extended
+	// client libraries so their messages can be consumed and ours can be consumed by them
+	offloadSizeAttribute = "ExtendedPayloadSize"
+	// offloadPointerClass is the first element of the pointer body of an offloaded message
+	offloadPointerClass = "software.amazon.payloadoffloading.PayloadS3Pointer"
+)
+
+// S3Transport is implemented by a Transport that also provides the S3 client used for offloaded bodies, see
+// Config.OffloadBucket. Consumers of a Transport without it cannot download offloaded bodies
+type S3Transport interface {
+	S3() s3iface.S3API
+}
+
+// offloadPointer is the location of an offloaded body
+type offloadPointer struct {
+	Bucket string `json:"s3BucketName"`
+	Key    string `json:"s3Key"`
+}
+
+// s3Client returns the S3 client for offloaded bodies, the client of the Transport when one is set
+func (c Config) s3Client() (s3iface.S3API, error) {
+	if c.Transport != nil {
+		if t, ok := c.Transport.(S3Transport); ok {
+			return t.S3(), nil
+		}
+		return nil, ErrOffload.Context(fmt.Errorf("the Transport does not provide an S3 client"))
+	}
+
+	if c.SessionProvider == nil {
+		c.SessionProvider = newSession
+	}
+
+	sess, err := c.SessionProvider(c)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.New(sess)
+	c.applyRequestHandlers(&client.Handlers)
+	return client, nil
+}
+
+// compressAll reports whether every body is compressed by the codec, a CompressThreshold compresses by size instead
+func (c Config) compressAll() bool {
+	return c.CompressBody && c.CompressThreshold <= 0
+}
+
+// offloadThreshold returns the message size above which a body is offloaded, at most the limit of SQS and SNS
+func (c Config) offloadThreshold() int {
+	if c.OffloadThreshold <= 0 || c.OffloadThreshold > maxBodySize {
+		return maxBodySize
+	}
+	return c.OffloadThreshold
+}
+
+// shrink compresses a body larger than CompressThreshold and then offloads it to S3 if the message is still larger than
+// OffloadThreshold. attrSize is the size of the attributes of the message, set adds an attribute to it
+func (p *publisher) shrink(ctx context.Context, body string, attrSize int, encoded bool, set func(name, dataType, value string)) (string, error) {
+	add := func(name, dataType, value string) {
+		set(name, dataType, value)
+		attrSize += len(name) + len(dataType) + len(value)
+	}
+
+	if p.compressThreshold > 0 && !encoded && len(body) > p.compressThreshold {
+		b, err := gzipBody([]byte(body))
+		if err != nil {
+			return "", ErrMarshal.Context(err)
+		}
+
+		if len(b) < len(body) {
+			body = string(b)
+			add(contentEncodingAttribute, DataTypeString.String(), gzipEncoding)
+		}
+	}
+
+	if p.offloadBucket == "" || len(body)+attrSize <= p.offloadThreshold {
+		return body, nil
+	}
+
+	pointer := offloadPointer{Bucket: p.offloadBucket, Key: p.offloadKeyPrefix + newAttemptID()}
+	if _, err := p.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: &pointer.Bucket,
+		Key:    &pointer.Key,
+		Body:   bytes.NewReader([]byte(body)),
+	}); err != nil {
+		return "", ErrOffload.Context(err)
+	}
+
+	b, err := json.Marshal([]interface{}{offloadPointerClass, pointer})
+	if err != nil {
+		return "", ErrMarshal.Context(err)
+	}
+
+	add(offloadSizeAttribute, DataTypeNumber.String(), strconv.Itoa(len(body)))
+	return string(b), nil
+}
+
+// shrinkSQS applies shrink to a message sent to a queue
+func (p *publisher) shrinkSQS(ctx context.Context, body *string, attrs map[string]*sqs.MessageAttributeValue) (*string, error) {
+	if p.compressThreshold <= 0 && p.offloadBucket == "" {
+		return body, nil
+	}
+
+	_, encoded := attrs[contentEncodingAttribute]
+	out, err := p.shrink(ctx, aws.StringValue(body), sqsMessageSize(nil, attrs), encoded, func(name, dataType, value string) {
+		attrs[name] = &sqs.MessageAttributeValue{DataType: aws.String(dataType), StringValue: aws.String(value)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// shrinkSNS applies shrink to a message published to the topic
+func (p *publisher) shrinkSNS(ctx context.Context, body *string, attrs map[string]*sns.MessageAttributeValue) (*string, error) {
+	if p.compressThreshold <= 0 && p.offloadBucket == "" {
+		return body, nil
+	}
+
+	_, encoded := attrs[contentEncodingAttribute]
+	out, err := p.shrink(ctx, aws.StringValue(body), snsMessageSize(nil, attrs), encoded, func(name, dataType, value string) {
+		attrs[name] = &sns.MessageAttributeValue{DataType: aws.String(dataType), StringValue: aws.String(value)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// download replaces the pointer body of an offloaded message with the body stored in S3, it runs before decompress as
+// bodies are compressed before they are offloaded. A body that cannot be downloaded fails to decode with ErrDecode
+func (m *message) download(ctx context.Context, client s3iface.S3API) {
+	if m.Attribute(offloadSizeAttribute) == "" || m.Body == nil {
+		return
+	}
+
+	var pointer []json.RawMessage
+	var location offloadPointer
+	if err := json.Unmarshal([]byte(*m.Body), &pointer); err != nil || len(pointer) != 2 || json.Unmarshal(pointer[1], &location) != nil {
+		m.bodyErr = ErrOffload.Context(fmt.Errorf("invalid S3 pointer"))
+		return
+	}
+
+	if client == nil {
+		m.bodyErr = ErrOffload.Context(fmt.Errorf("no S3 client to download %s/%s", location.Bucket, location.Key))
+		return
+	}
+
+	o, err := client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: &location.Bucket, Key: &location.Key})
+	if err != nil {
+		m.bodyErr = ErrOffload.Context(err)
+		return
+	}
+	defer o.Body.Close()
+
+	b, err := ioutil.ReadAll(o.Body)
+	if err != nil {
+		m.bodyErr = ErrOffload.Context(err)
+		return
+	}
+
+	body := string(b)
+	cp := *m.Message
+	cp.Body = &body
+	cp.MessageAttributes = make(map[string]*sqs.MessageAttributeValue, len(m.MessageAttributes))
+	for k, v := range m.MessageAttributes {
+		if k != offloadSizeAttribute {
+			cp.MessageAttributes[k] = v
+		}
+	}
+	m.Message = &cp
+}
+
+// loadBody downloads an offloaded body with the handler context before the handler runs. The visibility of the message
+// is not extended yet, so the download is bounded by its visibility timeout. It returns ErrOffload if the download ran
+// out of time, the message is redelivered
+func (c *consumer) loadBody(ctx context.Context, m *message) error {
+	if m.load == nil {
+		return nil
+	}
+
+	timeout := m.visibility
+	if timeout <= 0 {
+		timeout = c.VisibilityTimeout
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	m.loadBody(ctx)
+	if err := ctx.Err(); err != nil && m.bodyErr != nil {
+		return ErrOffload.Context(err)
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package gosqs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// mockS3 keeps the uploaded objects in memory
+type mockS3 struct {
+	s3iface.S3API
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *mockS3) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	b, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects == nil {
+		m.objects = map[string][]byte{}
+	}
+	m.objects[*in.Bucket+"/"+*in.Key] = b
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockS3) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.objects[*in.Bucket+"/"+*in.Key]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(b))}, nil
+}
+
+// randomText compresses to about a sixth of its size once gzipped and base64 encoded
+func randomText(n int) string {
+	const letters = "ab"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+func TestCompressAndOffload(t *testing.T) {
+	m, store := newMockSQS(), &mockS3{}
+	p := &publisher{sqs: m, sns: &mockSNS{}, s3: store, env: "dev", sqsURL: "", logger: &testLogger{},
+		compressThreshold: 1024, offloadBucket: "payloads", offloadKeyPrefix: "orders/", offloadThreshold: 4096}
+
+	bodies := map[string]string{
+		"small":      "small",
+		"compressed": strings.Repeat("a", 8000),
+		"offloaded":  randomText(60000),
+	}
+
+	for route, val := range bodies {
+		if _, err := p.PublishTo(context.TODO(), "queue", route, &sample{Val: val}); err != nil {
+			t.Fatalf("unexpected error publishing %s, got %v", route, err)
+		}
+	}
+
+	for _, in := range m.sent {
+		route := *in.MessageAttributes["route"].StringValue
+		_, compressed := in.MessageAttributes[contentEncodingAttribute]
+		_, offloaded := in.MessageAttributes[offloadSizeAttribute]
+		if compressed != (route != "small") || offloaded != (route == "offloaded") {
+			t.Errorf("unexpected transformation of %s, compressed %v, offloaded %v", route, compressed, offloaded)
+		}
+
+		if offloaded && !strings.HasPrefix(*in.MessageBody, `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"payloads","s3Key":"orders/`) {
+			t.Errorf("expected a pointer body, got %s", *in.MessageBody)
+		}
+	}
+
+	if len(store.objects) != 1 {
+		t.Fatalf("expected a single offloaded body, got %d", len(store.objects))
+	}
+
+	for _, b := range store.objects {
+		if len(b) >= 60000 {
+			t.Errorf("expected the body to be compressed before it was offloaded, got %d bytes", len(b))
+		}
+	}
+
+	c := &consumer{sqs: m, s3: store, QueueURL: "dev-queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+	received := map[string]string{}
+	for route := range bodies {
+		route := route
+		c.RegisterHandler(route, func(ctx context.Context, m Message) error {
+			var s sample
+			if err := m.Decode(&s); err != nil {
+				return err
+			}
+			received[route] = s.Val
+			return nil
+		})
+	}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	for route, val := range bodies {
+		if received[route] != val {
+			t.Errorf("expected the original body of %s, got %d bytes", route, len(received[route]))
+		}
+	}
+}
+
+func TestDownloadFailure(t *testing.T) {
+	m := newMockSQS()
+	msg := m.add("queue", "post_created", `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"payloads","s3Key":"missing"}]`)
+	msg.MessageAttributes[offloadSizeAttribute] = msg.MessageAttributes["route"]
+
+	var decodeErr error
+	c := &consumer{sqs: m, s3: &mockS3{}, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error {
+		decodeErr = m.Decode(&sample{})
+		return decodeErr
+	})
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if !errors.Is(decodeErr, ErrDecode) || !strings.Contains(decodeErr.Error(), "NoSuchKey") {
+		t.Errorf("expected the download error to fail decoding, got %v", decodeErr)
+	}
+
+	if len(m.deleted) != 0 {
+		t.Errorf("expected the message to be retried, got %d deletes", len(m.deleted))
+	}
+}
+
+// slowS3 counts the downloads and does not respond until the request is cancelled when hang is set
+type slowS3 struct {
+	*mockS3
+
+	gets int32
+	hang bool
+}
+
+func (s *slowS3) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	atomic.AddInt32(&s.gets, 1)
+	if s.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return s.mockS3.GetObjectWithContext(ctx, in, opts...)
+}
+
+// addOffloaded uploads the body and queues a message pointing to it
+func addOffloaded(m *mockSQS, store *mockS3, route, body string) {
+	store.objects = map[string][]byte{"payloads/" + route: []byte(body)}
+	msg := m.add("queue", route, `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"payloads","s3Key":"`+route+`"}]`)
+	msg.MessageAttributes[offloadSizeAttribute] = &sqs.MessageAttributeValue{DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(len(body)))}
+}
+
+func TestDownloadInWorker(t *testing.T) {
+	m, store := newMockSQS(), &slowS3{mockS3: &mockS3{}}
+	addOffloaded(m, store.mockS3, "post_created", `{"val":"post"}`)
+
+	var got sample
+	c := &consumer{sqs: m, s3: store, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return m.DecodeContext(ctx, &got) })
+
+	var received []*message
+	c.poll(func(msg *message) { received = append(received, msg) })
+	if len(received) != 1 || store.gets != 0 {
+		t.Fatalf("expected the receive loop to leave the download to the worker, got %d messages and %d downloads", len(received), store.gets)
+	}
+
+	if err := c.run(received[0]); err != nil || got.Val != "post" || store.gets != 1 {
+		t.Errorf("expected the worker to download the body once, got %v, %+v and %d downloads", err, got, store.gets)
+	}
+}
+
+func TestDownloadDeadline(t *testing.T) {
+	m, store := newMockSQS(), &slowS3{mockS3: &mockS3{}, hang: true}
+	addOffloaded(m, store.mockS3, "post_created", `{"val":"post"}`)
+
+	c := &consumer{sqs: m, s3: store, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+	var decodeErr error
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		decodeErr = m.DecodeContext(ctx, &sample{})
+		return decodeErr
+	})
+
+	msg, err := c.PeekMessage(context.TODO(), "")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if err := c.ProcessOne(context.TODO(), msg); !errors.Is(err, context.DeadlineExceeded) || !errors.Is(decodeErr, context.DeadlineExceeded) {
+		t.Errorf("expected the download to stop at the deadline of the handler, got %v", err)
+	}
+
+	c.VisibilityTimeout = 1
+	addOffloaded(m, store.mockS3, "post_created", `{"val":"post"}`)
+	start := time.Now()
+	c.poll(func(msg *message) {
+		if err := c.run(msg); !errors.Is(err, ErrOffload) {
+			t.Errorf("expected a download past the visibility timeout to fail with %v, got %v", ErrOffload, err)
+		}
+	})
+
+	if d := time.Since(start); d > 3*time.Second || len(m.deleted) != 0 {
+		t.Errorf("expected the message to be redelivered after the visibility timeout, got %v and %d deletes", d, len(m.deleted))
+	}
+}
+
+// s3Transport adds an S3 client to the transport of the tests
+type s3Transport struct {
+	*awsTransport
+	s3 s3iface.S3API
+}
+
+func (t *s3Transport) S3() s3iface.S3API {
+	return t.s3
+}
+
+func TestDownloadMinimalReceive(t *testing.T) {
+	m, store := newMockSQS(), &mockS3{}
+	addOffloaded(m, store, "post_created", `{"val":"post"}`)
+
+	conf := Config{QueueURL: "queue", Transport: &s3Transport{awsTransport: &awsTransport{sqs: m}, s3: store},
+		Logger: &testLogger{}, MinimalReceive: true}
+	cons, err := NewConsumer(conf, "post-worker")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	c := cons.(*consumer)
+	var got sample
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return m.Decode(&got) })
+	c.poll(func(msg *message) {
+		if err := c.run(msg); err != nil {
+			t.Errorf("unexpected error, got %v", err)
+		}
+	})
+
+	if got.Val != "post" {
+		t.Errorf("expected the offloaded body to be downloaded, got %+v", got)
+	}
+}
+
+// blockingS3 holds uploads until release is closed
+type blockingS3 struct {
+	*mockS3
+	release chan struct{}
+}
+
+func (s *blockingS3) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	<-s.release
+	return s.mockS3.PutObjectWithContext(ctx, in, opts...)
+}
+
+func TestMessageOffloadAsync(t *testing.T) {
+	m, store := newMockSQS(), &blockingS3{mockS3: &mockS3{}, release: make(chan struct{})}
+	p := &publisher{sqs: m, sns: &mockSNS{}, s3: store, env: "dev", logger: &testLogger{},
+		offloadBucket: "payloads", offloadThreshold: 1024}
+
+	returned := make(chan struct{})
+	go func() {
+		p.Message("queue", "post_created", &sample{Val: strings.Repeat("a", 2048)})
+		close(returned)
+	}()
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("expected Message to return before the body is uploaded")
+	}
+
+	close(store.release)
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(m.sent) != 1 || m.sent[0].MessageAttributes[offloadSizeAttribute] == nil || len(store.objects) != 1 {
+		t.Errorf("expected the offloaded message to be sent in the background, got %d sent and %d objects", len(m.sent), len(store.objects))
+	}
+}
@@ -0,0 +1,204 @@
+package gosqs
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Option sets a Config field in NewConfig, it returns an error for values that can never work
+type Option func(*Config) error
+
+// NewConfig builds a Config from the options, rejecting invalid values as they are applied and options that conflict
+// with each other once all are applied. Fields without an option can still be set on the returned Config
+//
+//	conf, err := gosqs.NewConfig(
+//		gosqs.WithRegion("us-east-1"),
+//		gosqs.WithTopicARN("arn:aws:sns:us-east-1:123456789012:orders"),
+//		gosqs.WithWorkerPool(10),
+//	)
+func NewConfig(opts ...Option) (Config, error) {
+	var c Config
+	for _, opt := range opts {
+		if err := opt(&c); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := c.conflicts(); err != nil {
+		return Config{}, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// conflicts reports fields that NewConfig does not allow together because one of them would be silently ignored
+func (c Config) conflicts() error {
+	switch {
+	case c.TopicARN != "" && c.TopicPrefix != "":
+		return ErrInvalidConfig.Context(fmt.Errorf("TopicARN cannot be combined with TopicPrefix, the topic ARN is derived from TopicPrefix only when TopicARN is empty"))
+	case c.Transport != nil && (c.SessionProvider != nil || c.Key != "" || c.Hostname != ""):
+		return ErrInvalidConfig.Context(fmt.Errorf("Transport cannot be combined with SessionProvider, credentials or Hostname"))
+	case c.SessionProvider != nil && c.Key != "":
+		return ErrInvalidConfig.Context(fmt.Errorf("SessionProvider cannot be combined with credentials, the session provides them"))
+	case c.QueueURL != "" && c.QueueOwnerAccountID != "":
+		return ErrInvalidConfig.Context(fmt.Errorf("QueueURL cannot be combined with QueueOwnerAccountID, the owner is only used to resolve the url"))
+	}
+
+	return nil
+}
+
+// WithRegion sets the AWS region of the clients, it is also used to derive the topic ARN and queue urls. Any name is
+// accepted, e.g. "local" for an emulator set with WithHostname
+func WithRegion(region string) Option {
+	return func(c *Config) error {
+		if region == "" {
+			return ErrInvalidConfig.Context(fmt.Errorf("region must not be empty"))
+		}
+
+		c.Region = region
+		return nil
+	}
+}
+
+// WithCredentials sets the static key and secret of the default session
+func WithCredentials(key, secret string) Option {
+	return func(c *Config) error {
+		if key == "" || secret == "" {
+			return ErrInvalidConfig.Context(fmt.Errorf("key and secret must not be empty"))
+		}
+
+		c.Key, c.Secret = key, secret
+		return nil
+	}
+}
+
+// WithSessionProvider sets a custom session setup, see Config.SessionProvider
+func WithSessionProvider(provider SessionProviderFunc) Option {
+	return func(c *Config) error {
+		c.SessionProvider = provider
+		return nil
+	}
+}
+
+// WithTransport sets the SQS and SNS clients, see Config.Transport
+func WithTransport(t Transport) Option {
+	return func(c *Config) error {
+		c.Transport = t
+		return nil
+	}
+}
+
+// WithHostname sets the endpoint of an emulator or local testing setup
+func WithHostname(hostname string) Option {
+	return func(c *Config) error {
+		c.Hostname = hostname
+		return nil
+	}
+}
+
+// WithEnv sets the environment name that prefixes queue names and the derived topic ARN
+func WithEnv(env string) Option {
+	return func(c *Config) error {
+		c.Env = env
+		return nil
+	}
+}
+
+// WithAWSAccountID sets the account used to derive the topic ARN and queue urls
+func WithAWSAccountID(id string) Option {
+	return func(c *Config) error {
+		c.AWSAccountID = id
+		return nil
+	}
+}
+
+// WithTopicPrefix sets the prefix the topic ARN is derived from, it conflicts with WithTopicARN
+func WithTopicPrefix(prefix string) Option {
+	return func(c *Config) error {
+		c.TopicPrefix = prefix
+		return nil
+	}
+}
+
+// topicARNPattern matches SNS topic ARNs of any partition
+var topicARNPattern = regexp.MustCompile(`^arn:[^:]+:sns:[^:]+:\d{12}:[^:]+$`)
+
+// WithTopicARN sets the topic messages are published to instead of deriving it, it conflicts with WithTopicPrefix
+func WithTopicARN(arn string) Option {
+	return func(c *Config) error {
+		if !topicARNPattern.MatchString(arn) {
+			return ErrInvalidConfig.Context(fmt.Errorf("invalid topic ARN %q", arn))
+		}
+
+		c.TopicARN = arn
+		return nil
+	}
+}
+
+// WithQueueURL sets the url of the consumer's queue so it is not resolved during setup
+func WithQueueURL(url string) Option {
+	return func(c *Config) error {
+		c.QueueURL = url
+		return nil
+	}
+}
+
+// WithWorkerPool sets the number of goroutines processing messages, it must be at least 1
+func WithWorkerPool(n int) Option {
+	return func(c *Config) error {
+		if n < 1 {
+			return ErrInvalidConfig.Context(fmt.Errorf("WorkerPool must be at least 1, got %d", n))
+		}
+
+		c.WorkerPool = n
+		return nil
+	}
+}
+
+// WithVisibilityTimeout sets the visibility timeout in seconds sent with every receive, between 1 and 43200
+func WithVisibilityTimeout(seconds int) Option {
+	return func(c *Config) error {
+		if seconds < 1 || seconds > maxVisibilityTimeout {
+			return ErrInvalidConfig.Context(fmt.Errorf("VisibilityTimeout must be between 1 and %d seconds, got %d", maxVisibilityTimeout, seconds))
+		}
+
+		c.VisibilityTimeout = seconds
+		return nil
+	}
+}
+
+// WithExtensionLimit sets the number of visibility extensions, 0 turns extensions off
+func WithExtensionLimit(n int) Option {
+	return func(c *Config) error {
+		if n < 0 {
+			return ErrInvalidConfig.Context(fmt.Errorf("ExtensionLimit must not be negative, got %d", n))
+		}
+
+		c.ExtensionLimit = &n
+		return nil
+	}
+}
+
+// WithRetryCount sets the attempts of failed AWS requests
+func WithRetryCount(n int) Option {
+	return func(c *Config) error {
+		if n < 0 {
+			return ErrInvalidConfig.Context(fmt.Errorf("RetryCount must not be negative, got %d", n))
+		}
+
+		c.RetryCount = n
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the consumer and publisher
+func WithLogger(l Logger) Option {
+	return func(c *Config) error {
+		c.Logger = l
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package gosqs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestNewConfig(t *testing.T) {
+	conf, err := NewConfig(
+		WithRegion("us-east-1"),
+		WithTopicARN("arn:aws:sns:us-east-1:123456789012:orders"),
+		WithWorkerPool(10),
+		WithVisibilityTimeout(60),
+		WithExtensionLimit(0),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if conf.Region != "us-east-1" || conf.TopicARN != "arn:aws:sns:us-east-1:123456789012:orders" || conf.WorkerPool != 10 || conf.VisibilityTimeout != 60 || *conf.ExtensionLimit != 0 {
+		t.Errorf("expected the options to be applied, got %+v", conf)
+	}
+
+	invalid := map[string][]Option{
+		"region":          {WithRegion("")},
+		"topic_arn":       {WithTopicARN("orders")},
+		"worker_pool":     {WithWorkerPool(0)},
+		"visibility":      {WithVisibilityTimeout(maxVisibilityTimeout + 1)},
+		"credentials":     {WithCredentials("key", "")},
+		"arn_and_prefix":  {WithTopicARN("arn:aws:sns:us-east-1:123456789012:orders"), WithTopicPrefix("todolist")},
+		"session_and_key": {WithSessionProvider(func(Config) (*session.Session, error) { return nil, nil }), WithCredentials("key", "secret")},
+	}
+	for name, opts := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewConfig(opts...); err == nil || !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewConfigLocal(t *testing.T) {
+	conf, err := NewConfig(WithRegion("local"), WithHostname("http://localhost:4100"), WithCredentials("key", "secret"))
+	if err != nil {
+		t.Fatalf("expected the emulator configuration to be accepted, got %v", err)
+	}
+
+	if conf.Region != "local" || conf.Hostname != "http://localhost:4100" {
+		t.Errorf("expected the options to be applied, got %+v", conf)
+	}
+}
@@ -0,0 +1,152 @@
+package gosqs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// overflowField is the body field holding the attributes that did not fit into the message attributes
+const overflowField = "_gosqs_attrs"
+
+// keptAttributes never move into the body, the consumer needs them before the body is decoded
+var keptAttributes = map[string]bool{"route": true, contentTypeAttribute: true, contentEncodingAttribute: true}
+
+// overflowAttribute is an attribute carried in the overflow field of the body
+type overflowAttribute struct {
+	DataType string `json:"dataType"`
+	Value    string `json:"value"`
+}
+
+// overflowNames returns the attributes that move into the body so that at most maxMessageAttributes remain. The kept
+// attributes and the correlation attribute stay first, the others are kept in alphabetical order until the limit is reached
+func overflowNames(names []string, correlation string) []string {
+	kept := func(name string) bool { return keptAttributes[name] || name == correlation }
+	sort.Slice(names, func(i, j int) bool {
+		if kept(names[i]) != kept(names[j]) {
+			return kept(names[i])
+		}
+		return names[i] < names[j]
+	})
+
+	if len(names) <= maxMessageAttributes {
+		return nil
+	}
+	return names[maxMessageAttributes:]
+}
+
+// overflowBody adds the attributes to the overflow field of a JSON object body
+func overflowBody(body string, attrs map[string]overflowAttribute) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &fields); err != nil || fields == nil {
+		return "", ErrAttributeOverflow.Context(fmt.Errorf("the body is not a JSON object"))
+	}
+
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return "", ErrMarshal.Context(err)
+	}
+	fields[overflowField] = b
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return "", ErrMarshal.Context(err)
+	}
+	return string(out), nil
+}
+
+// overflowSQS moves the attributes beyond the limit of a message sent to a queue into its body when AttributeOverflow is set
+func (p *publisher) overflowSQS(body *string, attrs map[string]*sqs.MessageAttributeValue) (*string, error) {
+	if !p.attributeOverflow || len(attrs) <= maxMessageAttributes {
+		return body, nil
+	}
+
+	if _, ok := attrs[contentEncodingAttribute]; ok {
+		return nil, ErrAttributeOverflow.Context(fmt.Errorf("compressed bodies cannot carry attributes"))
+	}
+
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+
+	moved := map[string]overflowAttribute{}
+	for _, k := range overflowNames(names, correlationAttribute(p.correlationAttribute)) {
+		moved[k] = overflowAttribute{DataType: aws.StringValue(attrs[k].DataType), Value: aws.StringValue(attrs[k].StringValue)}
+	}
+
+	out, err := overflowBody(aws.StringValue(body), moved)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range moved {
+		delete(attrs, k)
+	}
+	return &out, nil
+}
+
+// overflowSNS is overflowSQS for messages published to the topic
+func (p *publisher) overflowSNS(body *string, attrs map[string]*sns.MessageAttributeValue) (*string, error) {
+	if !p.attributeOverflow || len(attrs) <= maxMessageAttributes {
+		return body, nil
+	}
+
+	converted := make(map[string]*sqs.MessageAttributeValue, len(attrs))
+	for k, v := range attrs {
+		converted[k] = &sqs.MessageAttributeValue{DataType: v.DataType, StringValue: v.StringValue}
+	}
+
+	out, err := p.overflowSQS(body, converted)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range attrs {
+		if _, ok := converted[k]; !ok {
+			delete(attrs, k)
+		}
+	}
+	return out, nil
+}
+
+// mergeOverflow restores the attributes carried in the overflow field of the body and removes the field, attributes sent
+// as message attributes take precedence
+func (m *message) mergeOverflow() {
+	if m.bodyErr != nil || m.Message.Body == nil || !bytes.Contains(m.body(), []byte(overflowField)) {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(m.body(), &fields); err != nil {
+		return
+	}
+
+	var attrs map[string]overflowAttribute
+	raw, ok := fields[overflowField]
+	if !ok || json.Unmarshal(raw, &attrs) != nil {
+		return
+	}
+
+	delete(fields, overflowField)
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+
+	cp := *m.Message
+	cp.Body = aws.String(string(b))
+	cp.MessageAttributes = make(map[string]*sqs.MessageAttributeValue, len(m.MessageAttributes)+len(attrs))
+	for k, v := range attrs {
+		cp.MessageAttributes[k] = &sqs.MessageAttributeValue{DataType: aws.String(v.DataType), StringValue: aws.String(v.Value)}
+	}
+	for k, v := range m.MessageAttributes {
+		cp.MessageAttributes[k] = v
+	}
+	m.Message = &cp
+}
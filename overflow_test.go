@@ -0,0 +1,106 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestAttributeOverflow(t *testing.T) {
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/", attributeOverflow: true}
+
+	var opts []PublishOption
+	for i := 0; i < 12; i++ {
+		opts = append(opts, WithAttribute(DataTypeString, fmt.Sprintf("attr%02d", i), fmt.Sprintf("value%d", i)))
+	}
+	opts = append(opts, WithCorrelationID("abc"))
+
+	body := sample{Val: "overflow"}
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", &body, opts...); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	sent := m.sent[0]
+	if len(sent.MessageAttributes) != maxMessageAttributes {
+		t.Fatalf("expected %d attributes to be sent, got %d", maxMessageAttributes, len(sent.MessageAttributes))
+	}
+
+	for _, name := range []string{"route", defaultCorrelationAttribute, "attr00"} {
+		if _, ok := sent.MessageAttributes[name]; !ok {
+			t.Errorf("expected %s to stay a message attribute", name)
+		}
+	}
+
+	if !strings.Contains(*sent.MessageBody, overflowField) {
+		t.Fatalf("expected the body to carry the overflowed attributes, got %s", *sent.MessageBody)
+	}
+
+	u := "http://localhost:4100/dev-post-worker"
+	var got []string
+	var decoded sample
+	var raw string
+	c := &consumer{sqs: m, QueueURL: u, logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		handlers: map[string]Handler{
+			"post_created": func(ctx context.Context, msg Message) error {
+				for i := 0; i < 12; i++ {
+					got = append(got, msg.Attribute(fmt.Sprintf("attr%02d", i)))
+				}
+				b, _ := msg.RawBody()
+				raw = string(b)
+				return msg.Decode(&decoded)
+			},
+		}}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(got) != 12 || got[0] != "value0" || got[11] != "value11" {
+		t.Errorf("expected every attribute to be readable on receive, got %v", got)
+	}
+
+	if decoded.Val != "overflow" || strings.Contains(raw, overflowField) {
+		t.Errorf("expected the overflow field to be removed from the body, got %s", raw)
+	}
+}
+
+func TestAttributeOverflowRejected(t *testing.T) {
+	var opts []PublishOption
+	for i := 0; i < 11; i++ {
+		opts = append(opts, WithAttribute(DataTypeString, fmt.Sprintf("attr%02d", i), "value"))
+	}
+
+	t.Run("not_an_object", func(t *testing.T) {
+		p := &publisher{sqs: newMockSQS(), env: "dev", sqsURL: "http://localhost:4100/", attributeOverflow: true}
+		if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", []string{"a"}, opts...); !errors.Is(err, ErrAttributeOverflow) {
+			t.Errorf("expected %v, got %v", ErrAttributeOverflow, err)
+		}
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		conf := Config{CompressBody: true}
+		p := &publisher{sqs: newMockSQS(), env: "dev", sqsURL: "http://localhost:4100/", attributeOverflow: true,
+			codec: withCompression(conf), attributes: compressionAttributes(conf)}
+		if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", &sample{}, opts...); !errors.Is(err, ErrAttributeOverflow) {
+			t.Errorf("expected %v, got %v", ErrAttributeOverflow, err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m := newMockSQS()
+		p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+		if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", &sample{}, opts...); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if n := len(m.sent[0].MessageAttributes); n != 12 {
+			t.Errorf("expected the attributes to be sent as is, got %d", n)
+		}
+
+		if strings.Contains(aws.StringValue(m.sent[0].MessageBody), overflowField) {
+			t.Error("expected the body to be unchanged")
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package gosqs
+
+import (
+	"sync"
+)
+
+// pauseGate holds the receive loop while the consumer is paused. The zero value is not paused
+type pauseGate struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+// wait blocks until the gate is open
+func (g *pauseGate) wait() {
+	g.mu.Lock()
+	if !g.paused {
+		g.mu.Unlock()
+		return
+	}
+	resume := g.resume
+	g.mu.Unlock()
+
+	<-resume
+}
+
+func (g *pauseGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		g.paused = true
+		g.resume = make(chan struct{})
+	}
+}
+
+func (g *pauseGate) open() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		g.paused = false
+		close(g.resume)
+	}
+}
+
+func (g *pauseGate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Pause stops receiving new messages, messages that were already received are processed as usual. A receive that is in
+// progress completes and its messages are still processed
+func (c *consumer) Pause() {
+	c.gate.pause()
+	c.Logger().Println("consumer paused", c.QueueURL)
+}
+
+// Resume restarts receiving messages after Pause
+func (c *consumer) Resume() {
+	c.gate.open()
+	c.Logger().Println("consumer resumed", c.QueueURL)
+}
+
+// IsPaused reports whether the consumer is paused
+func (c *consumer) IsPaused() bool {
+	return c.gate.isPaused()
+}
@@ -0,0 +1,45 @@
+package gosqs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", workerPool: 1, logger: &testLogger{}}
+	receives := func() int {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.receives
+	}
+
+	c.Pause()
+	if !c.IsPaused() {
+		t.Fatal("expected the consumer to be paused")
+	}
+
+	go c.Consume()
+	time.Sleep(10 * time.Millisecond)
+	if n := receives(); n != 0 {
+		t.Fatalf("expected no receives while paused, got %d", n)
+	}
+
+	c.Resume()
+	deadline := time.Now().Add(time.Second)
+	for receives() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if receives() == 0 {
+		t.Fatal("expected receiving to restart after Resume")
+	}
+
+	// leave the consumer paused so the receive loop stops for the remaining tests
+	c.Pause()
+	time.Sleep(5 * time.Millisecond)
+	n := receives()
+	time.Sleep(10 * time.Millisecond)
+	if receives() != n || !c.IsPaused() {
+		t.Errorf("expected receiving to stop again, got %d more receives", receives()-n)
+	}
+}
@@ -0,0 +1,357 @@
+package gosqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// maxBodySize is the largest message body SNS and SQS accept
+const maxBodySize = 262144
+
+// PublishOption configures a single Publish or PublishTo call
+type PublishOption func(*publishOptions)
+
+type publishOptions struct {
+	attributes    []customAttribute
+	groupID       string
+	dedupID       string
+	contentDedup  bool
+	subject       string
+	traceHeader   string
+	correlationID string
+	structure     map[string]string
+	// set from the body when the message is sent
+	bodyAttributes []bodyAttribute
+	groupIDPath    string
+	// set to the region that accepted the message
+	acceptedRegion *string
+	err            error
+}
+
+func newPublishOptions(opts []PublishOption) (*publishOptions, error) {
+	o := &publishOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o, o.err
+}
+
+// WithAttribute adds a custom attribute to a single message. It follows the same datatype rules as Config.NewCustomAttribute
+// and overrides target and Config attributes with the same title
+func WithAttribute(dataType DataType, title string, value interface{}) PublishOption {
+	return func(o *publishOptions) {
+		attr, err := newCustomAttribute(dataType, title, value)
+		if err != nil {
+			o.err = err
+			return
+		}
+
+		o.attributes = append(o.attributes, attr)
+	}
+}
+
+// traceHeaderPrefix is prepended to the attribute name of every propagation header
+const traceHeaderPrefix = "trace-"
+
+// WithTraceHeaders adds propagation headers, e.g. traceparent and tracestate, to the message so consumers can continue the
+// producer's trace with m.TraceHeaders(). Each header is sent as a String attribute prefixed with "trace-" and counts towards
+// the 10 attribute limit
+func WithTraceHeaders(headers map[string]string) PublishOption {
+	return func(o *publishOptions) {
+		for k, v := range headers {
+			o.attributes = append(o.attributes, customAttribute{traceHeaderPrefix + k, DataTypeString.String(), v})
+		}
+	}
+}
+
+// WithMessageGroupID sets the MessageGroupId of a message sent to a FIFO queue or topic, it is required for FIFO targets and
+// fails the call with ErrNotFIFO for standard ones
+func WithMessageGroupID(id string) PublishOption {
+	return func(o *publishOptions) {
+		o.groupID = id
+	}
+}
+
+// WithDeduplicationID sets the MessageDeduplicationId of a message sent to a FIFO queue or topic
+func WithDeduplicationID(id string) PublishOption {
+	return func(o *publishOptions) {
+		o.dedupID = id
+	}
+}
+
+// WithContentDedup omits the MessageDeduplicationId and relies on the ContentBasedDeduplication setting of the FIFO queue
+// or topic, which must be enabled. It cannot be combined with WithDeduplicationID
+func WithContentDedup() PublishOption {
+	return func(o *publishOptions) {
+		o.contentDedup = true
+	}
+}
+
+// WithSubject sets the Subject of a message published to the topic, consumers can read it with m.Subject() when raw
+// message delivery is disabled on the subscription. It is ignored by PublishTo as SQS messages have no subject
+func WithSubject(subject string) PublishOption {
+	return func(o *publishOptions) {
+		o.subject = subject
+	}
+}
+
+// WithMessageStructureJSON publishes a different payload to each subscription protocol, keyed by protocol such as "sqs",
+// "lambda" or "http". The "default" key is required by SNS and used for every protocol without a payload of its own.
+// The payloads replace the body passed to Publish. It is ignored by PublishTo as SQS has no message structure
+func WithMessageStructureJSON(payloads map[string]string) PublishOption {
+	return func(o *publishOptions) {
+		if _, ok := payloads["default"]; !ok {
+			o.err = ErrMessageStructure
+			return
+		}
+
+		o.structure = payloads
+	}
+}
+
+// WithAWSTraceHeader sets the AWSTraceHeader system attribute of a message sent to a queue, propagating the X-Ray trace
+// without using one of the 10 message attributes. Consumers read it with m.TraceHeader(). It is ignored by Publish as SNS
+// propagates the trace header itself when active tracing is enabled on the topic
+func WithAWSTraceHeader(header string) PublishOption {
+	return func(o *publishOptions) {
+		o.traceHeader = header
+	}
+}
+
+// systemAttributes returns the message system attributes of a message sent to a queue, or nil if there are none
+func (o *publishOptions) systemAttributes() map[string]*sqs.MessageSystemAttributeValue {
+	if o.traceHeader == "" {
+		return nil
+	}
+
+	return map[string]*sqs.MessageSystemAttributeValue{
+		sqs.MessageSystemAttributeNameForSendsAwstraceHeader: {DataType: aws.String(DataTypeString.String()), StringValue: aws.String(o.traceHeader)},
+	}
+}
+
+// isFIFO reports whether the queue name or topic ARN refers to a FIFO target
+func isFIFO(target string) bool {
+	return strings.HasSuffix(target, ".fifo")
+}
+
+// validateFIFO ensures FIFO targets get a group ID and exactly one deduplication mechanism, and that standard targets
+// get neither as SQS and SNS would ignore or reject them
+func (o *publishOptions) validateFIFO(fifo bool) error {
+	if !fifo {
+		if o.groupID != "" || o.dedupID != "" || o.contentDedup {
+			return ErrNotFIFO
+		}
+		return nil
+	}
+
+	if o.contentDedup && o.dedupID != "" {
+		return ErrDedupConflict
+	}
+
+	if o.groupID == "" {
+		return ErrGroupRequired
+	}
+
+	if !o.contentDedup && o.dedupID == "" {
+		return ErrDedupRequired
+	}
+
+	return nil
+}
+
+// callAttributes returns the attributes set on a single call, including the correlation attribute
+func (o *publishOptions) callAttributes(correlationAttr string) []customAttribute {
+	return append(append([]customAttribute{}, o.attributes...), correlationAttributes(correlationAttr, o.correlationID)...)
+}
+
+// attributesFor merges the custom attributes for a message sent to the target, the precedence is call > target > Config
+func (p *publisher) attributesFor(target string, call ...customAttribute) []customAttribute {
+	return mergeAttributes(p.attributes, p.targetAttributes[target], call)
+}
+
+// Publish sends a message to the topic and waits for the result, returning the message ID. The event will be sent as is,
+// no prepending will take place
+func (p *publisher) Publish(ctx context.Context, event string, body interface{}, opts ...PublishOption) (string, error) {
+	o, err := newPublishOptions(opts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := o.applyBody(body); err != nil {
+		return "", err
+	}
+
+	if err := o.validateFIFO(isFIFO(p.arn)); err != nil {
+		return "", err
+	}
+
+	b, err := marshalBody(p.codec, body)
+	if err != nil {
+		return "", ErrMarshal.Context(err)
+	}
+
+	return p.publish(ctx, event, string(b), o)
+}
+
+// PublishReader sends a pre-serialized body read from r to the topic and waits for the result, returning the message ID. Reading
+// stops with ErrBodyOverflow once the body exceeds the 262144 byte limit
+func (p *publisher) PublishReader(ctx context.Context, event string, r io.Reader, opts ...PublishOption) (string, error) {
+	o, err := newPublishOptions(opts)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxBodySize+1))
+	if err != nil {
+		return "", ErrReadBody.Context(err)
+	}
+
+	if len(b) > maxBodySize {
+		return "", ErrBodyOverflow
+	}
+
+	if err := o.applyJSONBody(b); err != nil {
+		return "", err
+	}
+
+	if err := o.validateFIFO(isFIFO(p.arn)); err != nil {
+		return "", err
+	}
+
+	if _, ok := p.codec.(gzipCodec); ok {
+		if b, err = gzipBody(b); err != nil {
+			return "", ErrMarshal.Context(err)
+		}
+	}
+
+	return p.publish(ctx, event, string(b), o)
+}
+
+// publish sends the body to the topic using the options of the call
+func (p *publisher) publish(ctx context.Context, event, body string, o *publishOptions) (string, error) {
+	input := &sns.PublishInput{
+		Message:           &body,
+		MessageAttributes: defaultSNSAttributes(event, p.attributesFor(p.arn, o.callAttributes(p.correlationAttribute)...)...),
+		TopicArn:          &p.arn,
+	}
+
+	if o.groupID != "" {
+		input.MessageGroupId = &o.groupID
+	}
+
+	if o.dedupID != "" {
+		input.MessageDeduplicationId = &o.dedupID
+	}
+
+	if o.subject != "" {
+		input.Subject = &o.subject
+	}
+
+	if o.structure != nil {
+		b, err := json.Marshal(o.structure)
+		if err != nil {
+			return "", ErrMarshal.Context(err)
+		}
+
+		input.Message = aws.String(string(b))
+		input.MessageStructure = aws.String("json")
+		// protocol specific payloads are sent as is, even when CompressBody is set
+		delete(input.MessageAttributes, contentEncodingAttribute)
+	} else {
+		var err error
+		if input.Message, err = p.overflowSNS(input.Message, input.MessageAttributes); err != nil {
+			return "", err
+		}
+
+		if input.Message, err = p.shrinkSNS(ctx, input.Message, input.MessageAttributes); err != nil {
+			return "", err
+		}
+	}
+
+	if err := checkSize(snsMessageSize(input.Message, input.MessageAttributes)); err != nil {
+		return "", err
+	}
+
+	resp, region, err := p.publishSNS(ctx, input)
+	if err != nil {
+		// a publish that also failed over to the failover topic is already an ErrPublish
+		if errors.Is(err, ErrPublish) {
+			return "", err
+		}
+		return "", ErrPublish.Context(err)
+	}
+
+	if o.acceptedRegion != nil {
+		*o.acceptedRegion = region
+	}
+
+	return *resp.MessageId, nil
+}
+
+// PublishTo sends a direct message to an individual queue and waits for the result, returning the message ID. The event
+// will be sent as is, no prepending will take place. No other queues will receive this message
+func (p *publisher) PublishTo(ctx context.Context, queue, event string, body interface{}, opts ...PublishOption) (string, error) {
+	o, err := newPublishOptions(opts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := o.applyBody(body); err != nil {
+		return "", err
+	}
+
+	if err := o.validateFIFO(isFIFO(queue)); err != nil {
+		return "", err
+	}
+
+	b, err := marshalBody(p.codec, body)
+	if err != nil {
+		return "", ErrMarshal.Context(err)
+	}
+
+	out := string(b)
+	u := p.sqsURL + fmt.Sprintf("%s-%s", p.env, queue)
+	input := &sqs.SendMessageInput{
+		MessageBody:             &out,
+		MessageAttributes:       defaultSQSAttributes(event, p.attributesFor(queue, o.callAttributes(p.correlationAttribute)...)...),
+		MessageSystemAttributes: o.systemAttributes(),
+		QueueUrl:                &u,
+	}
+
+	if o.groupID != "" {
+		input.MessageGroupId = &o.groupID
+	}
+
+	if o.dedupID != "" {
+		input.MessageDeduplicationId = &o.dedupID
+	}
+
+	if input.MessageBody, err = p.overflowSQS(input.MessageBody, input.MessageAttributes); err != nil {
+		return "", err
+	}
+
+	if input.MessageBody, err = p.shrinkSQS(ctx, input.MessageBody, input.MessageAttributes); err != nil {
+		return "", err
+	}
+
+	if err := checkSize(sqsMessageSize(input.MessageBody, input.MessageAttributes)); err != nil {
+		return "", err
+	}
+
+	resp, err := p.sqs.SendMessageWithContext(ctx, input)
+	if err != nil {
+		return "", ErrPublish.Context(err)
+	}
+
+	return *resp.MessageId, nil
+}
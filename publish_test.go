@@ -0,0 +1,214 @@
+package gosqs
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPublishAttributePrecedence(t *testing.T) {
+	arn := "arn:aws:sns:local:000000000000:todolist-dev"
+	conf := Config{}
+	conf.NewCustomAttribute(DataTypeString, "source", "config")
+	conf.NewCustomAttribute(DataTypeString, "team", "config")
+	conf.NewTargetAttribute(arn, DataTypeString, "team", "target")
+	conf.NewTargetAttribute(arn, DataTypeString, "tier", "target")
+
+	m := &mockSNS{}
+	p := &publisher{sns: m, arn: arn, attributes: conf.Attributes, targetAttributes: conf.TargetAttributes}
+
+	id, err := p.Publish(context.TODO(), "post_created", &sample{}, WithAttribute(DataTypeString, "tier", "call"))
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if id != "1" {
+		t.Errorf("unexpected message id, got %s", id)
+	}
+
+	attrs := m.published[0].MessageAttributes
+	expected := map[string]string{"route": "post_created", "source": "config", "team": "target", "tier": "call"}
+	if len(attrs) != len(expected) {
+		t.Fatalf("unexpected attributes, got %+v", attrs)
+	}
+
+	for k, v := range expected {
+		if got := *attrs[k].StringValue; got != v {
+			t.Errorf("unexpected value for %s, expected %s, got %s", k, v, got)
+		}
+	}
+}
+
+func TestPublishTo(t *testing.T) {
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "some_event", &sample{}, WithAttribute(DataTypeNumber, "count", "one")); err != ErrMarshal {
+		t.Fatalf("expected %v for an invalid attribute, got %v", ErrMarshal, err)
+	}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "some_event", &sample{}, WithAttribute(DataTypeNumber, "count", 1)); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	msgs := m.queues["http://localhost:4100/dev-post-worker"]
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message in the queue, got %d", len(msgs))
+	}
+
+	if got := *msgs[0].MessageAttributes["count"].StringValue; got != "1" {
+		t.Errorf("unexpected attribute value, got %s", got)
+	}
+}
+
+func TestPublishFIFOOptions(t *testing.T) {
+	p := &publisher{sqs: newMockSQS(), sns: &mockSNS{}, env: "dev", sqsURL: "http://localhost:4100/", arn: "arn:aws:sns:local:000000000000:todolist-dev"}
+
+	for name, opt := range map[string]PublishOption{
+		"group":         WithMessageGroupID("post"),
+		"dedup":         WithDeduplicationID("1"),
+		"content_dedup": WithContentDedup(),
+	} {
+		if _, err := p.Publish(context.TODO(), "post_created", &sample{}, opt); err != ErrNotFIFO {
+			t.Errorf("%s: expected %v on a standard topic, got %v", name, ErrNotFIFO, err)
+		}
+
+		if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", &sample{}, opt); err != ErrNotFIFO {
+			t.Errorf("%s: expected %v on a standard queue, got %v", name, ErrNotFIFO, err)
+		}
+	}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker.fifo", "post_created", &sample{}, WithContentDedup()); err != ErrGroupRequired {
+		t.Errorf("expected %v, got %v", ErrGroupRequired, err)
+	}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker.fifo", "post_created", &sample{Val: "a"}, WithMessageGroupIDFromBody("val"), WithContentDedup()); err != nil {
+		t.Errorf("expected a group id from the body to satisfy the FIFO queue, got %v", err)
+	}
+
+	p.arn += ".fifo"
+	if err := p.send(&sample{}, "post_created"); err != ErrGroupRequired {
+		t.Errorf("expected %v for background sends to a FIFO topic, got %v", ErrGroupRequired, err)
+	}
+}
+
+func TestPublishDeduplication(t *testing.T) {
+	arn := "arn:aws:sns:local:000000000000:todolist-dev.fifo"
+	m := &mockSNS{}
+	p := &publisher{sns: m, arn: arn}
+
+	if _, err := p.Publish(context.TODO(), "post_created", &sample{}, WithMessageGroupID("post")); err != ErrDedupRequired {
+		t.Errorf("expected %v, got %v", ErrDedupRequired, err)
+	}
+
+	if _, err := p.Publish(context.TODO(), "post_created", &sample{}, WithContentDedup(), WithDeduplicationID("1")); err != ErrDedupConflict {
+		t.Errorf("expected %v, got %v", ErrDedupConflict, err)
+	}
+
+	if _, err := p.Publish(context.TODO(), "post_created", &sample{}, WithMessageGroupID("post"), WithContentDedup()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	in := m.published[0]
+	if in.MessageDeduplicationId != nil {
+		t.Errorf("expected no deduplication id with content based deduplication, got %s", *in.MessageDeduplicationId)
+	}
+
+	if in.MessageGroupId == nil || *in.MessageGroupId != "post" {
+		t.Errorf("expected the message group id to be set, got %v", in.MessageGroupId)
+	}
+}
+
+func TestWithTraceHeaders(t *testing.T) {
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev"}
+
+	headers := map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "some_event", &sample{}, WithTraceHeaders(headers)); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	msg := newMessage(m.queues["dev-post-worker"][0])
+	got := msg.TraceHeaders()
+	if len(got) != 1 || got["traceparent"] != headers["traceparent"] {
+		t.Errorf("unexpected trace headers, expected %v, got %v", headers, got)
+	}
+}
+
+func TestWithSubject(t *testing.T) {
+	m := &mockSNS{}
+	p := &publisher{sns: m, arn: "arn:aws:sns:local:000000000000:todolist-dev"}
+
+	if _, err := p.Publish(context.TODO(), "post_created", &sample{}, WithSubject("urgent")); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if s := m.published[0].Subject; s == nil || *s != "urgent" {
+		t.Errorf("expected the subject to be set, got %v", s)
+	}
+}
+
+func TestPublishReader(t *testing.T) {
+	m := &mockSNS{}
+	p := &publisher{sns: m, arn: "arn:aws:sns:local:000000000000:todolist-dev"}
+
+	if _, err := p.PublishReader(context.TODO(), "post_created", strings.NewReader(`{"val":"a"}`), WithSubject("s")); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	in := m.published[0]
+	if *in.Message != `{"val":"a"}` || *in.MessageAttributes["route"].StringValue != "post_created" || *in.Subject != "s" {
+		t.Errorf("unexpected publish input, got %+v", in)
+	}
+
+	big := strings.NewReader(strings.Repeat("a", maxBodySize+1))
+	if _, err := p.PublishReader(context.TODO(), "post_created", big); err != ErrBodyOverflow {
+		t.Errorf("expected ErrBodyOverflow, got %v", err)
+	}
+
+	if len(m.published) != 1 {
+		t.Errorf("expected the oversized body not to be published, got %d messages", len(m.published))
+	}
+}
+
+func TestWithAWSTraceHeader(t *testing.T) {
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+	header := "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "some_event", &sample{}, WithAWSTraceHeader(header)); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(m.sent[0].MessageAttributes) != 1 {
+		t.Errorf("expected the trace header not to use a message attribute, got %+v", m.sent[0].MessageAttributes)
+	}
+
+	msg := newMessage(m.queues["http://localhost:4100/dev-post-worker"][0])
+	if msg.TraceHeader() != header {
+		t.Errorf("unexpected trace header, got %q", msg.TraceHeader())
+	}
+}
+
+func TestWithMessageStructureJSON(t *testing.T) {
+	m := &mockSNS{}
+	p := &publisher{sns: m, arn: "arn:aws:sns:local:000000000000:todolist-dev"}
+
+	if _, err := p.Publish(context.TODO(), "post_created", nil, WithMessageStructureJSON(map[string]string{"sqs": "{}"})); err != ErrMessageStructure {
+		t.Fatalf("expected %v without a default payload, got %v", ErrMessageStructure, err)
+	}
+
+	payloads := map[string]string{"default": "post created", "sqs": `{"id":1}`}
+	if _, err := p.Publish(context.TODO(), "post_created", nil, WithMessageStructureJSON(payloads)); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	in := m.published[0]
+	if in.MessageStructure == nil || *in.MessageStructure != "json" {
+		t.Errorf("expected the json message structure, got %v", in.MessageStructure)
+	}
+
+	if *in.Message != `{"default":"post created","sqs":"{\"id\":1}"}` {
+		t.Errorf("unexpected message, got %s", *in.Message)
+	}
+}
@@ -1,19 +1,26 @@
 package gosqs
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 )
 
 const maxRetryCount = 5
 
+// publishRetryDelay is the pause between attempts of a message that is sent in the background
+var publishRetryDelay = 10 * time.Second
+
 var errDataLimit = errors.New("InvalidParameterValue: One or more parameters are invalid. Reason: Message must be shorter than 262144 bytes")
 
 // Notifier used for broadcasting messages
@@ -22,6 +29,15 @@ type Notifier interface {
 }
 
 // Publisher provides an interface for sending messages through AWS SQS and SNS
+//
+// NewPublisher returns the AWS backed implementation, code that sends messages should depend on this interface so that
+// a fake such as sqstesting.StubPublisher can be injected in tests
+//
+// A Publisher is safe for concurrent use by multiple goroutines, a single one should be shared by the whole service. Its
+// configuration is copied by NewPublisher and never changed afterwards, so changes to the Config, e.g. through
+// NewCustomAttribute or NewTargetAttribute, do not apply to it. Every call builds its own input and the AWS clients are
+// safe for concurrent use, the state of background sends and auto batching is guarded by a mutex. A custom Logger, Codec
+// or Transport is called concurrently and must be safe for concurrent use as well
 type Publisher interface {
 	// Create sends a message using a notifier, the modelname will be prepended to the static event, e.g post_created
 	Create(n Notifier)
@@ -38,36 +54,79 @@ type Publisher interface {
 	// Message sends a direct message to an individual queue, the queueName(receiver) must be provided. The event will be sent
 	// as is, no prepending will take place. No other queues will receive this message.
 	Message(queue, message string, body interface{})
+	// Publish sends a message to the topic and waits for the result, returning the message ID. The event will be sent as is.
+	// A *MessageTooLargeError is returned without calling AWS if the body and attributes exceed the 262144 byte limit
+	Publish(ctx context.Context, event string, body interface{}, opts ...PublishOption) (string, error)
+	// PublishReader sends a pre-serialized body read from r to the topic and waits for the result, returning the message ID.
+	// It returns ErrBodyOverflow if the body exceeds the 262144 byte limit
+	PublishReader(ctx context.Context, event string, r io.Reader, opts ...PublishOption) (string, error)
+	// PublishTo sends a direct message to an individual queue and waits for the result, returning the message ID.
+	// No other queues will receive this message
+	PublishTo(ctx context.Context, queue, event string, body interface{}, opts ...PublishOption) (string, error)
+	// PublishBatch sends direct messages to an individual queue in batches of 10, the result reports the message ID or the
+	// error of every entry by its index
+	PublishBatch(ctx context.Context, queue string, entries []BatchEntry) (BatchResult, error)
+	// ResolvedTopicARN returns the topic ARN messages are published to, either as configured, derived or created during setup
+	ResolvedTopicARN() string
+	// Flush blocks until every message sent in the background has been delivered or has failed, or until the context is cancelled.
+	// A *FlushError lists the messages that could not be delivered since the last Flush
+	Flush(ctx context.Context) error
+	// Close flushes the publisher, messages sent in the background after Close are dropped
+	Close(ctx context.Context) error
 }
 
+var _ Publisher = (*publisher)(nil)
+
 type publisher struct {
-	sqs *sqs.SQS
-	sns *sns.SNS
+	sqs sqsiface.SQSAPI
+	sns snsiface.SNSAPI
 
 	arn    string
 	env    string
 	sqsURL string
 
-	camelCase  bool
-	attributes []customAttribute
-	logger     Logger
+	camelCase        bool
+	attributes       []customAttribute
+	targetAttributes map[string][]customAttribute
+	logger           Logger
+
+	correlationAttribute string
+	codec                Codec
+	attributeOverflow    bool
+
+	// s3 is set when Config.OffloadBucket is
+	s3                s3iface.S3API
+	compressThreshold int
+	offloadBucket     string
+	offloadKeyPrefix  string
+	offloadThreshold  int
+
+	// region is the region of the topic, failoverSNS is set when Config.FailoverTopicARN is
+	region          string
+	failoverSNS     snsiface.SNSAPI
+	failoverARN     string
+	failoverRegion  string
+	failoverPolicy  FailoverPolicy
+	failoverTimeout time.Duration
+
+	inflight inflight
+	batcher  *autoBatcher
 }
 
-// NewPublisher creates a new SQS/SNS publisher instance
+// NewPublisher creates a new SQS/SNS publisher instance. Errors are returned as a *SetupError
 func NewPublisher(c Config) (Publisher, error) {
-	if c.SessionProvider == nil {
-		c.SessionProvider = newSession
+	if err := c.Validate(); err != nil {
+		return nil, setupErr(SetupValidation, err)
 	}
 
-	sess, err := c.SessionProvider(c)
-
+	transport, err := c.transport()
 	if err != nil {
-		return nil, err
+		return nil, setupErr(SetupCredentials, err)
 	}
 
 	arn := c.TopicARN
 	if arn == "" {
-		arn = fmt.Sprintf("arn:aws:sns:%s:%s:%s-%s", c.Region, c.AWSAccountID, c.TopicPrefix, c.Env)
+		arn = fmt.Sprintf("arn:aws:sns:%s:%s:%s-%s", c.topicRegion(), c.AWSAccountID, c.TopicPrefix, c.Env)
 	}
 
 	sqsURL := fmt.Sprintf("%s/", c.Hostname)
@@ -80,16 +139,74 @@ func NewPublisher(c Config) (Publisher, error) {
 	}
 
 	pub := &publisher{
-		sqs:    sqs.New(sess),
-		sns:    sns.New(sess),
-		arn:    arn,
-		env:    c.Env,
-		sqsURL: sqsURL,
+		sqs:              transport.SQS(),
+		sns:              transport.SNS(),
+		arn:              arn,
+		env:              c.Env,
+		sqsURL:           sqsURL,
+		attributes:       mergeAttributes(c.sourceAttributes(), c.Attributes, codecAttributes(c.Codec), compressionAttributes(c)),
+		targetAttributes: c.targetAttributes(),
+		logger:           c.Logger,
+		codec:            withCompression(c),
+
+		correlationAttribute: c.CorrelationAttribute,
+		attributeOverflow:    c.AttributeOverflow,
+
+		region:          topicARNRegion(arn),
+		failoverARN:     c.FailoverTopicARN,
+		failoverRegion:  c.failoverRegion(),
+		failoverPolicy:  c.FailoverPolicy,
+		failoverTimeout: c.FailoverTimeout,
+
+		compressThreshold: c.CompressThreshold,
+		offloadBucket:     c.OffloadBucket,
+		offloadKeyPrefix:  c.OffloadKeyPrefix,
+		offloadThreshold:  c.offloadThreshold(),
+	}
+
+	if c.OffloadBucket != "" {
+		if pub.s3, err = c.s3Client(); err != nil {
+			return nil, setupErr(SetupCredentials, err)
+		}
+	}
+
+	if c.FailoverTopicARN != "" {
+		if pub.failoverSNS, err = c.failoverSNS(); err != nil {
+			return nil, setupErr(SetupCredentials, err)
+		}
+		if pub.failoverTimeout == 0 {
+			pub.failoverTimeout = defaultFailoverTimeout
+		}
+	}
+
+	pub.batcher = newAutoBatcher(c, pub.sendAutoBatch)
+
+	if c.DryRun {
+		d := newDryRun(c)
+		pub.sns = &dryRunSNS{SNSAPI: pub.sns, dryRun: d}
+		if pub.failoverSNS != nil {
+			pub.failoverSNS = &dryRunSNS{SNSAPI: pub.failoverSNS, dryRun: d}
+		}
+		pub.sqs = &dryRunSQS{SQSAPI: pub.sqs, dryRun: d}
+		if pub.s3 != nil {
+			pub.s3 = &dryRunS3{S3API: pub.s3}
+		}
+	}
+
+	if c.EnsureTopic {
+		if err := pub.ensureTopic(c, arn[strings.LastIndex(arn, ":")+1:]); err != nil {
+			return nil, setupErr(SetupResolution, err)
+		}
 	}
 
 	return pub, nil
 }
 
+// ResolvedTopicARN returns the topic ARN messages are published to, either as configured, derived or created during setup
+func (p *publisher) ResolvedTopicARN() string {
+	return p.arn
+}
+
 func (p *publisher) event(n Notifier, action string) string {
 	if p.camelCase {
 		return fmt.Sprintf("%s%s", n.ModelName(), strings.Title(action))
@@ -101,19 +218,19 @@ func (p *publisher) event(n Notifier, action string) string {
 // Create sends a message using a notifier, the modelname will be prepended to the static event, e.g post_created
 func (p *publisher) Create(n Notifier) {
 	e := p.event(n, "created")
-	go p.send(n, e)
+	p.async(e, func() error { return p.send(n, e) })
 }
 
 // Delete sends a message using a notifier, the modelname will be prepended to the static event, e.g post_deleted
 func (p *publisher) Delete(n Notifier) {
 	e := p.event(n, "deleted")
-	go p.send(n, e)
+	p.async(e, func() error { return p.send(n, e) })
 }
 
 // Update sends a message using a notifier, the modelname will be prepended to the static event, e.g post_updated
 func (p *publisher) Update(n Notifier) {
 	e := p.event(n, "updated")
-	go p.send(n, e)
+	p.async(e, func() error { return p.send(n, e) })
 }
 
 type modify struct {
@@ -134,23 +251,47 @@ func newModify(n Notifier, changes interface{}) *modify {
 // a special decoder will need to be used to process these events
 func (p *publisher) Modify(n Notifier, changes interface{}) {
 	e := p.event(n, "modified")
-	go p.send(newModify(n, changes), e)
+	p.async(e, func() error { return p.send(newModify(n, changes), e) })
 }
 
 // Dispatch sends a message using a notifier, the modelname will be prepended to the provided event, e.g post_published
 func (p *publisher) Dispatch(n Notifier, event string) {
 	e := p.event(n, event)
-	go p.send(n, e)
+	p.async(e, func() error { return p.send(n, e) })
 }
 
 // Message sends a direct message to an individual queue, the queueName(receiver) must be provided. The event will be sent
 // as is, no prepending will take place. No other queues will receive this message.
+//
+// When AutoBatchSize is configured the message is buffered and sent with other messages of the same queue and event
+// using SendMessageBatch. Messages to FIFO queues are dropped with ErrGroupRequired, use PublishTo with WithMessageGroupID
 func (p *publisher) Message(queue, event string, body interface{}) {
+	if p.batcher != nil {
+		if p.inflight.isClosed() {
+			p.Logger().Println(ErrPublisherClosed.Error(), event)
+			return
+		}
+		// marshaled right away like the messages sent on their own, the value may change while it is buffered
+		o, err := marshalBody(p.codec, body)
+		if err != nil {
+			p.Logger().Println(ErrMarshal.Context(err).Error())
+			return
+		}
+
+		p.batcher.add(queue, event, o)
+		return
+	}
+
+	if isFIFO(queue) {
+		p.Logger().Println(ErrGroupRequired.Error(), event)
+		return
+	}
+
 	name := fmt.Sprintf("%s-%s", p.env, queue)
 
-	o, err := json.Marshal(body)
+	o, err := marshalBody(p.codec, body)
 	if err != nil {
-		p.logger.Println(ErrMarshal.Context(err).Error())
+		p.Logger().Println(ErrMarshal.Context(err).Error())
 		return
 	}
 
@@ -160,84 +301,106 @@ func (p *publisher) Message(queue, event string, body interface{}) {
 
 	sqsInput := &sqs.SendMessageInput{
 		MessageBody:       &out,
-		MessageAttributes: defaultSQSAttributes(event, p.attributes...),
+		MessageAttributes: defaultSQSAttributes(event, p.attributesFor(queue)...),
 		QueueUrl:          &u,
 	}
 
-	go p.sendDirectMessage(sqsInput, event)
+	// the body is shrunk in the background like the messages of send, an offloaded body is uploaded to S3 first
+	p.async(event, func() error {
+		var err error
+		if sqsInput.MessageBody, err = p.overflowSQS(sqsInput.MessageBody, sqsInput.MessageAttributes); err != nil {
+			return err
+		}
+
+		if sqsInput.MessageBody, err = p.shrinkSQS(context.Background(), sqsInput.MessageBody, sqsInput.MessageAttributes); err != nil {
+			return err
+		}
+
+		return p.sendDirectMessage(sqsInput, event)
+	})
 }
 
 // sendDirectMessage is used to handle sending and error failures in a separate go-routine
 //
 // AWS-SDK will use their own retry mechanism for a failed request utilizing exponential backoff. If they fail
 // then we will wait 10 seconds before trying again
-func (p *publisher) sendDirectMessage(input *sqs.SendMessageInput, event string, retryCount ...int) {
+func (p *publisher) sendDirectMessage(input *sqs.SendMessageInput, event string, retryCount ...int) error {
 	var c int
 	if len(retryCount) != 0 {
 		c = retryCount[0]
 	}
 
-	if c > maxRetryCount {
-		return
+	if err := checkSize(sqsMessageSize(input.MessageBody, input.MessageAttributes)); err != nil {
+		return err
 	}
 
-	if _, err := p.sqs.SendMessage(input); err != nil {
-		if err.Error() == errDataLimit.Error() {
-			panic(ErrBodyOverflow.Context(err))
-		}
+	_, err := p.sqs.SendMessage(input)
+	if err == nil {
+		return nil
+	}
+
+	if err.Error() == errDataLimit.Error() {
+		panic(ErrBodyOverflow.Context(err))
+	}
 
-		log.Print(ErrPublish)
-		time.Sleep(10 * time.Second)
-		p.sendDirectMessage(input, event, c+1)
+	if c >= maxRetryCount {
+		return ErrPublish.Context(err)
 	}
+
+	log.Print(ErrPublish)
+	time.Sleep(publishRetryDelay)
+	return p.sendDirectMessage(input, event, c+1)
 }
 
 // send is used to handle sending and error failures in a separate go-routine for SNS messages
 //
 // AWS-SDK will use their own retry mechanism for a failed request utilizing exponential backoff. If they fail
 // then we will wait 10 seconds before trying again
-func (p *publisher) send(body interface{}, event string, retryCount ...int) {
-	var c int
-	if len(retryCount) != 0 {
-		c = retryCount[0]
-	}
-
-	if c > maxRetryCount {
-		return
+func (p *publisher) send(body interface{}, event string) error {
+	if isFIFO(p.arn) {
+		return ErrGroupRequired
 	}
 
-	o, err := json.Marshal(body)
+	o, err := marshalBody(p.codec, body)
 	if err != nil {
 		panic(ErrMarshal.Context(err))
 	}
 
 	out := string(o)
 	snsInput := &sns.PublishInput{Message: &out,
-		MessageAttributes: defaultSNSAttributes(event, p.attributes...),
+		MessageAttributes: defaultSNSAttributes(event, p.attributesFor(p.arn)...),
 		TopicArn:          &p.arn,
 	}
 
-	var retrier func(input *sns.PublishInput, retryCount int)
+	if snsInput.Message, err = p.overflowSNS(snsInput.Message, snsInput.MessageAttributes); err != nil {
+		return err
+	}
 
-	retrier = func(input *sns.PublishInput, retryCount int) {
-		if c > maxRetryCount {
-			return
+	if snsInput.Message, err = p.shrinkSNS(context.Background(), snsInput.Message, snsInput.MessageAttributes); err != nil {
+		return err
+	}
+
+	if err := checkSize(snsMessageSize(snsInput.Message, snsInput.MessageAttributes)); err != nil {
+		return err
+	}
+
+	for retryCount := 0; ; retryCount++ {
+		_, _, err = p.publishSNS(context.Background(), snsInput)
+		if err == nil {
+			return nil
 		}
 
-		_, err = p.sns.Publish(snsInput)
-		if err != nil {
-			if err.Error() == errDataLimit.Error() {
-				panic(ErrBodyOverflow.Context(err).Error())
-			}
+		if err.Error() == errDataLimit.Error() {
+			panic(ErrBodyOverflow.Context(err).Error())
+		}
 
-			log.Println(ErrPublish.Context(err), " retrying in 10s")
-			time.Sleep(10 * time.Second)
-			retrier(input, retryCount+1)
-			return
+		if retryCount >= maxRetryCount {
+			return ErrPublish.Context(err)
 		}
-	}
 
-	retrier(snsInput, 0)
+		log.Println(ErrPublish.Context(err), " retrying in 10s")
+		time.Sleep(publishRetryDelay)
+	}
 }
 
 // defaultSNSAttributes provides general SNS attributes that we need for every message
@@ -248,6 +411,7 @@ func defaultSNSAttributes(event string, ca ...customAttribute) map[string]*sns.M
 	}
 
 	for _, attr := range ca {
+		attr := attr
 		m[attr.Title] = &sns.MessageAttributeValue{DataType: &attr.DataType, StringValue: &attr.Value}
 	}
 
@@ -262,6 +426,7 @@ func defaultSQSAttributes(event string, ca ...customAttribute) map[string]*sqs.M
 	}
 
 	for _, attr := range ca {
+		attr := attr
 		m[attr.Title] = &sqs.MessageAttributeValue{DataType: &attr.DataType, StringValue: &attr.Value}
 	}
 
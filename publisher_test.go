@@ -1,8 +1,10 @@
 package gosqs
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/service/sns"
@@ -199,3 +201,99 @@ func TestDefaultSQSAttributs(t *testing.T) {
 		t.Fatalf("unexpected results,\nexpected %+v,\ngot: %+v", expected, att)
 	}
 }
+
+func TestResolvedTopicARN(t *testing.T) {
+	conf := Config{Region: "us-east-1", Key: "key", Secret: "secret", AWSAccountID: "000000000000", TopicPrefix: "todolist", Env: "dev"}
+	pub, err := NewPublisher(conf)
+	if err != nil {
+		t.Fatalf("error creating publisher, got %v", err)
+	}
+
+	if arn := pub.ResolvedTopicARN(); arn != "arn:aws:sns:us-east-1:000000000000:todolist-dev" {
+		t.Errorf("expected the ARN derived during setup, got %s", arn)
+	}
+}
+
+func TestNewPublisherTopicRegion(t *testing.T) {
+	conf := Config{Region: "us-east-1", TopicRegion: "eu-west-1", Key: "key", Secret: "secret", AWSAccountID: "000000000000", TopicPrefix: "todolist", Env: "dev"}
+	pub, err := NewPublisher(conf)
+	if err != nil {
+		t.Fatalf("error creating publisher, got %v", err)
+	}
+
+	p := pub.(*publisher)
+	if p.arn != "arn:aws:sns:eu-west-1:000000000000:todolist-dev" {
+		t.Errorf("expected the topic region in the ARN, got %s", p.arn)
+	}
+
+	if r := *p.sns.(*sns.SNS).Client.Config.Region; r != "eu-west-1" {
+		t.Errorf("expected the SNS client to use the topic region, got %s", r)
+	}
+
+	if r := *p.sqs.(*sqs.SQS).Client.Config.Region; r != "us-east-1" {
+		t.Errorf("expected the SQS client to use the region, got %s", r)
+	}
+}
+
+func TestPublisherConcurrentUse(t *testing.T) {
+	sqsMock, snsMock := newMockSQS(), &mockSNS{}
+	conf := Config{Transport: &awsTransport{sqs: sqsMock, sns: snsMock}, Hostname: "http://localhost:4100", Env: "dev",
+		TopicARN: "arn:aws:sns:us-east-1:000000000000:orders", Logger: &testLogger{}}
+	if err := conf.NewTargetAttribute("billing", DataTypeString, "team", "billing"); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := NewPublisher(conf)
+	if err != nil {
+		t.Fatalf("error creating publisher, got %v", err)
+	}
+
+	// changes to the config after NewPublisher must not race with the publisher
+	go func() {
+		for i := 0; i < 100; i++ {
+			conf.NewTargetAttribute("billing", DataTypeString, fmt.Sprintf("attr%d", i), "value")
+			conf.NewCustomAttribute(DataTypeString, fmt.Sprintf("attr%d", i), "value")
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := context.Background()
+			opt := WithAttribute(DataTypeString, "request", fmt.Sprint(i))
+			if _, err := pub.Publish(ctx, "order_created", &sample{Val: "order"}, opt, WithCorrelationID(fmt.Sprint(i))); err != nil {
+				t.Errorf("unexpected publish error, got %v", err)
+			}
+			if _, err := pub.PublishTo(ctx, "billing", "invoice_created", &sample{}, opt); err != nil {
+				t.Errorf("unexpected publish error, got %v", err)
+			}
+			if _, err := pub.PublishBatch(ctx, "billing", []BatchEntry{{Event: "invoice_created", Body: &sample{}, Options: []PublishOption{opt}}, {Event: "invoice_paid", Body: &sample{}}}); err != nil {
+				t.Errorf("unexpected batch error, got %v", err)
+			}
+			pub.Create(&sample{})
+		}(i)
+	}
+	wg.Wait()
+
+	if err := pub.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected flush error, got %v", err)
+	}
+
+	if len(snsMock.published) != 100 || len(sqsMock.sent) != 150 {
+		t.Fatalf("expected every message to be sent, got %d published and %d sent", len(snsMock.published), len(sqsMock.sent))
+	}
+
+	for _, in := range snsMock.published {
+		if _, ok := in.MessageAttributes["attr0"]; ok {
+			t.Fatalf("expected attributes added after NewPublisher to be ignored, got %v", in.MessageAttributes)
+		}
+	}
+
+	for _, in := range sqsMock.sent {
+		if team := in.MessageAttributes["team"]; team == nil || *team.StringValue != "billing" {
+			t.Fatalf("expected the target attribute, got %v", in.MessageAttributes)
+		}
+	}
+}
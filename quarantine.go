@@ -0,0 +1,122 @@
+package gosqs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// QuarantineSink receives messages that failed Config.QuarantineAfter times. Once Quarantine returns nil the message is deleted
+// from the queue, if it returns an error the message stays in the queue and is received again
+type QuarantineSink interface {
+	Quarantine(ctx context.Context, m QuarantinedMessage) error
+}
+
+// QuarantineFunc allows a function to be used as a QuarantineSink
+type QuarantineFunc func(ctx context.Context, m QuarantinedMessage) error
+
+// Quarantine calls the function
+func (f QuarantineFunc) Quarantine(ctx context.Context, m QuarantinedMessage) error {
+	return f(ctx, m)
+}
+
+// QuarantinedAttribute is a message attribute of a quarantined message
+type QuarantinedAttribute struct {
+	DataType string `json:"dataType"`
+	Value    string `json:"value"`
+}
+
+// QuarantinedMessage holds everything needed to replay a message that was removed from the queue after repeated failures
+type QuarantinedMessage struct {
+	QueueURL      string                          `json:"queueUrl"`
+	MessageID     string                          `json:"messageId"`
+	Route         string                          `json:"route"`
+	Body          string                          `json:"body"`
+	Attributes    map[string]QuarantinedAttribute `json:"attributes"`
+	ReceiveCount  int                             `json:"receiveCount"`
+	Err           string                          `json:"error"`
+	QuarantinedAt time.Time                       `json:"quarantinedAt"`
+}
+
+// FileQuarantine appends quarantined messages to a file as JSON lines
+type FileQuarantine struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileQuarantine creates a sink that appends quarantined messages to the file at path, creating it if needed
+func NewFileQuarantine(path string) *FileQuarantine {
+	return &FileQuarantine{path: path}
+}
+
+// Quarantine appends the message to the file
+func (f *FileQuarantine) Quarantine(ctx context.Context, m QuarantinedMessage) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return ErrMarshal.Context(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return ErrQuarantine.Context(err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		return ErrQuarantine.Context(err)
+	}
+
+	return nil
+}
+
+// receiveCount returns how often the message has been received, it is 0 if ApproximateReceiveCount was not received
+func (m *message) receiveCount() int {
+	v, ok := m.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]
+	if !ok || v == nil {
+		return 0
+	}
+
+	n, _ := strconv.Atoi(*v)
+	return n
+}
+
+// quarantine hands a failed message to the sink once it has failed often enough, it reports whether the message was
+// quarantined and can be deleted
+func (c *consumer) quarantine(ctx context.Context, m *message, handlerErr error) bool {
+	if c.quarantineSink == nil || c.quarantineAfter <= 0 || m.receiveCount() < c.quarantineAfter {
+		return false
+	}
+
+	q := QuarantinedMessage{
+		QueueURL:      c.QueueURL,
+		MessageID:     aws.StringValue(m.MessageId),
+		Route:         m.Route(),
+		Body:          string(m.body()),
+		Attributes:    map[string]QuarantinedAttribute{},
+		ReceiveCount:  m.receiveCount(),
+		Err:           handlerErr.Error(),
+		QuarantinedAt: time.Now(),
+	}
+
+	for k, v := range m.MessageAttributes {
+		if v != nil {
+			q.Attributes[k] = QuarantinedAttribute{DataType: aws.StringValue(v.DataType), Value: aws.StringValue(v.StringValue)}
+		}
+	}
+
+	if err := c.quarantineSink.Quarantine(ctx, q); err != nil {
+		c.logMessage(m, ErrQuarantine.Context(err).Error(), q.MessageID)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package gosqs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestQuarantine(t *testing.T) {
+	m := newMockSQS()
+	var quarantined []QuarantinedMessage
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, quarantineAfter: 3,
+		quarantineSink: QuarantineFunc(func(ctx context.Context, q QuarantinedMessage) error {
+			quarantined = append(quarantined, q)
+			return nil
+		}),
+		handlers: map[string]Handler{
+			"post_created": func(ctx context.Context, msg Message) error { return errors.New("failed") },
+		}}
+
+	early := m.add("queue", "post_created", `{"val":"a"}`)
+	early.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("2")
+	late := m.add("queue", "post_created", `{"val":"b"}`)
+	late.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("3")
+
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(quarantined) != 1 {
+		t.Fatalf("expected 1 quarantined message, got %d", len(quarantined))
+	}
+
+	q := quarantined[0]
+	if q.Body != `{"val":"b"}` || q.Route != "post_created" || q.ReceiveCount != 3 || q.Err != "failed" || q.Attributes["route"].Value != "post_created" {
+		t.Errorf("unexpected quarantined message, got %+v", q)
+	}
+
+	if len(m.deleted) != 1 || m.deleted[0] != *late.ReceiptHandle {
+		t.Errorf("expected only the quarantined message to be deleted, got %v", m.deleted)
+	}
+}
+
+func TestFileQuarantine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quarantine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "quarantine.jsonl")
+	sink := NewFileQuarantine(path)
+	for _, id := range []string{"1", "2"} {
+		if err := sink.Quarantine(context.TODO(), QuarantinedMessage{MessageID: id}); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var q QuarantinedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &q); err != nil {
+			t.Fatalf("invalid line %s", scanner.Text())
+		}
+		ids = append(ids, q.MessageID)
+	}
+
+	if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("expected both messages to be appended, got %v", ids)
+	}
+}
@@ -0,0 +1,46 @@
+package gosqs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// QueueTags returns the tags of the consumer's queue
+func (c *consumer) QueueTags(ctx context.Context) (map[string]string, error) {
+	o, err := c.sqs.ListQueueTagsWithContext(ctx, &sqs.ListQueueTagsInput{QueueUrl: &c.QueueURL})
+	if err != nil {
+		return nil, ErrQueueTags.Context(err)
+	}
+
+	return aws.StringValueMap(o.Tags), nil
+}
+
+// configureFromTags resolves the queue, reads its tags and returns the config as changed by ConfigureFromTags. The queue
+// url is kept in the returned config so the queue is not resolved again
+func (c Config) configureFromTags(client sqsiface.SQSAPI, queueName string) (Config, error) {
+	probe := &consumer{sqs: client, QueueURL: c.QueueURL}
+	if probe.QueueURL == "" {
+		if err := probe.resolveQueue(c, queueName); err != nil {
+			return c, setupErr(SetupResolution, err)
+		}
+	}
+
+	tags, err := probe.QueueTags(context.Background())
+	if err != nil {
+		return c, setupErr(SetupResolution, err)
+	}
+
+	if err := c.ConfigureFromTags(tags, &c); err != nil {
+		return c, setupErr(SetupValidation, ErrInvalidConfig.Context(err))
+	}
+
+	c.QueueURL = probe.QueueURL
+	if err := c.Validate(); err != nil {
+		return c, setupErr(SetupValidation, err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,48 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestConfigureFromTags(t *testing.T) {
+	m := newMockSQS()
+	m.urls = map[string]string{"dev-orders": "https://sqs.local/dev-orders"}
+	m.queueTags = map[string]string{"team": "billing", "slo-tier": "gold"}
+
+	var seen map[string]string
+	conf := Config{Env: "dev", Transport: &awsTransport{sqs: m}, VisibilityTimeout: 30, Logger: &testLogger{},
+		ConfigureFromTags: func(tags map[string]string, c *Config) error {
+			seen = tags
+			if tags["slo-tier"] == "gold" {
+				c.VisibilityTimeout = 120
+			}
+			return nil
+		}}
+
+	cons, err := NewConsumer(conf, "orders")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	c := cons.(*consumer)
+	if c.VisibilityTimeout != 120 || c.QueueURL != "https://sqs.local/dev-orders" || seen["team"] != "billing" {
+		t.Errorf("expected the tags to configure the consumer, got %d, %s, %v", c.VisibilityTimeout, c.QueueURL, seen)
+	}
+
+	if len(m.lookups) != 1 {
+		t.Errorf("expected the queue to be resolved once, got %d", len(m.lookups))
+	}
+
+	tags, err := c.QueueTags(context.TODO())
+	if err != nil || tags["slo-tier"] != "gold" {
+		t.Errorf("expected the queue tags, got %v, %v", tags, err)
+	}
+
+	conf.ConfigureFromTags = func(tags map[string]string, c *Config) error { return errors.New("unknown tier") }
+	var setup *SetupError
+	if _, err := NewConsumer(conf, "orders"); !errors.As(err, &setup) || setup.Stage != SetupValidation {
+		t.Errorf("expected an error of the hook to fail validation, got %v", err)
+	}
+}
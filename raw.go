@@ -0,0 +1,36 @@
+package gosqs
+
+import "context"
+
+// RawHandler processes the body of a message as is, e.g. CSV lines or opaque payloads that are not JSON. The Message gives
+// access to the attributes and the other metadata of the message
+type RawHandler func(ctx context.Context, body []byte, m Message) error
+
+// RegisterRawHandler registers a handler that receives the body without decoding it. The body is unwrapped from the SNS
+// envelope and decompressed like the body of a regular handler, a body that cannot be decompressed fails with ErrDecode and
+// Config.OnDecodeErrorAction applies. Raw and regular handlers can be registered on the same consumer
+func (c *consumer) RegisterRawHandler(name string, h RawHandler, adapters ...Adapter) {
+	c.RegisterHandler(name, rawHandler(h), adapters...)
+}
+
+// rawHandler adapts a RawHandler to a Handler
+func rawHandler(h RawHandler) Handler {
+	return func(ctx context.Context, m Message) error {
+		body, err := m.RawBody()
+		if err != nil {
+			return err
+		}
+
+		return h(ctx, body, m)
+	}
+}
+
+// RawBody returns the body of the message, unwrapped from the SNS envelope and decompressed
+func (m *message) RawBody() ([]byte, error) {
+	m.loadBody(context.Background())
+	if m.bodyErr != nil {
+		return nil, decodeErr(m.bodyErr)
+	}
+
+	return m.body(), nil
+}
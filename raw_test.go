@@ -0,0 +1,54 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestRegisterRawHandler(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+
+	var lines []string
+	var region string
+	c.RegisterRawHandler("csv_imported", func(ctx context.Context, body []byte, msg Message) error {
+		lines = append(lines, string(body))
+		region = msg.Attribute("region")
+		return nil
+	})
+	c.RegisterHandler("post_created", func(ctx context.Context, msg Message) error {
+		var s sample
+		return msg.Decode(&s)
+	})
+
+	raw := m.add("queue", "csv_imported", "1,alice,42")
+	raw.MessageAttributes["region"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("eu")}
+	m.add("queue", "post_created", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(lines) != 1 || lines[0] != "1,alice,42" || region != "eu" {
+		t.Errorf("expected the raw body and its attributes, got %v and %q", lines, region)
+	}
+
+	if len(m.deleted) != 2 {
+		t.Errorf("expected raw and JSON messages to be deleted, got %v", m.deleted)
+	}
+}
+
+func TestRawBodyDecompressFailure(t *testing.T) {
+	msg := newMessage(&sqs.Message{Body: aws.String("not gzip")})
+	msg.bodyErr = errors.New("gzip: invalid header")
+
+	called := false
+	err := rawHandler(func(ctx context.Context, body []byte, m Message) error {
+		called = true
+		return nil
+	})(context.TODO(), msg)
+	if called || !errors.Is(err, ErrDecode) {
+		t.Errorf("expected ErrDecode without calling the handler, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package gosqs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// ReceiveInfo describes a single ReceiveMessage call, e.g. to log its request ID for correlation with AWS support
+type ReceiveInfo struct {
+	// RequestID is the SQS request ID of the call, it is empty if the request never reached SQS
+	RequestID string
+	// Messages is the number of messages received
+	Messages int
+	// Err is set if the call failed
+	Err      error
+	Duration time.Duration
+	// AttemptID is the ReceiveRequestAttemptId of a receive from a FIFO queue
+	AttemptID string
+}
+
+// ReceiveHookFunc is called after every ReceiveMessage call, it must not block
+type ReceiveHookFunc func(ReceiveInfo)
+
+// captureRequestID stores the request ID of the call once it completed, whether it succeeded or not
+func captureRequestID(id *string) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			*id = r.RequestID
+		})
+	}
+}
+
+// received records the outcome of a ReceiveMessage call in the stats and reports it to the receive hook
+func (c *consumer) received(info ReceiveInfo) {
+	c.stats.receiveRequest(info.RequestID)
+	if c.receiveHook != nil {
+		c.receiveHook(info)
+	}
+}
+
+// receiveAttemptWindow is how long SQS deduplicates FIFO receives with the same ReceiveRequestAttemptId
+const receiveAttemptWindow = 5 * time.Minute
+
+// receiveAttempts hands out the ReceiveRequestAttemptId of FIFO receives. The SDK retries a receive with the same input and
+// therefore the same ID, a receive that failed is retried with its ID as well so SQS returns the messages it may already
+// have handed to the failed attempt instead of holding them until their visibility timeout expires. A new ID is used after
+// a successful receive and once the ID is older than the 5 minute deduplication window
+type receiveAttempts struct {
+	mu      sync.Mutex
+	id      string
+	started time.Time
+}
+
+// next returns the ID of the next receive
+func (a *receiveAttempts) next() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.id == "" || time.Since(a.started) >= receiveAttemptWindow {
+		a.id, a.started = newAttemptID(), time.Now()
+	}
+	return a.id
+}
+
+// done ends the attempt once a receive succeeded, the ID of a failed receive is kept for its retry
+func (a *receiveAttempts) done(err error) {
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.id = ""
+}
+
+// newAttemptID returns a random ReceiveRequestAttemptId
+func newAttemptID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,131 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestReceiveHook(t *testing.T) {
+	m := newMockSQS()
+	var infos []ReceiveInfo
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		receiveHook: func(info ReceiveInfo) { infos = append(infos, info) }}
+
+	m.add("queue", "post_created", "{}")
+	m.receiveErrs = []error{errors.New("unavailable")}
+	c.poll(func(msg *message) {})
+	c.poll(func(msg *message) {})
+
+	if len(infos) != 2 {
+		t.Fatalf("expected a report for every receive, got %+v", infos)
+	}
+
+	if infos[0].RequestID != "request-1" || infos[0].Err == nil {
+		t.Errorf("expected the failed receive with its request id, got %+v", infos[0])
+	}
+
+	if infos[1].RequestID != "request-2" || infos[1].Messages != 1 || infos[1].Err != nil {
+		t.Errorf("expected the successful receive with its request id, got %+v", infos[1])
+	}
+
+	if id := c.Stats().LastReceiveRequestID; id != "request-2" {
+		t.Errorf("expected the last request id in the stats, got %q", id)
+	}
+}
+
+func TestMinimalReceive(t *testing.T) {
+	conf := Config{QueueURL: "queue", Transport: &awsTransport{sqs: newMockSQS()}, MinimalReceive: true, QuarantineAfter: 3,
+		MessageAttributeNames: []string{"tenant"}}
+	c, err := NewConsumer(conf, "post-worker")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	input := c.(*consumer).receiveInput()
+	names := aws.StringValueSlice(input.AttributeNames)
+	if !reflect.DeepEqual(names, []string{"MessageGroupId", "AWSTraceHeader", "ApproximateReceiveCount"}) {
+		t.Errorf("expected only the required system attributes, got %v", names)
+	}
+
+	messageNames := aws.StringValueSlice(input.MessageAttributeNames)
+	if messageNames[0] != "tenant" || messageNames[1] != "route" {
+		t.Errorf("expected the configured and required message attributes, got %v", messageNames)
+	}
+	for _, n := range messageNames {
+		if n == all {
+			t.Errorf("expected All not to be requested, got %v", messageNames)
+		}
+	}
+}
+
+func TestReceiveRequestAttemptID(t *testing.T) {
+	m := newMockSQS()
+	var infos []ReceiveInfo
+	c := &consumer{sqs: m, QueueURL: "queue.fifo", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		receiveHook: func(info ReceiveInfo) { infos = append(infos, info) }}
+
+	m.receiveErrs = []error{errors.New("connection reset")}
+	for i := 0; i < 3; i++ {
+		c.poll(func(msg *message) {})
+	}
+
+	if len(infos) != 3 || infos[0].AttemptID == "" {
+		t.Fatalf("expected an attempt id for every FIFO receive, got %+v", infos)
+	}
+
+	if infos[1].AttemptID != infos[0].AttemptID {
+		t.Errorf("expected the failed receive to be retried with its attempt id, got %q and %q", infos[0].AttemptID, infos[1].AttemptID)
+	}
+
+	if infos[2].AttemptID == infos[1].AttemptID {
+		t.Errorf("expected a new attempt id after a successful receive, got %q", infos[2].AttemptID)
+	}
+
+	c.attempts.started = c.attempts.started.Add(-receiveAttemptWindow)
+	m.receiveErrs = []error{errors.New("connection reset")}
+	c.poll(func(msg *message) {})
+	failed := c.attempts.id
+	c.attempts.started = c.attempts.started.Add(-receiveAttemptWindow)
+	c.poll(func(msg *message) {})
+	if infos[4].AttemptID == failed {
+		t.Error("expected a new attempt id once the deduplication window passed")
+	}
+
+	standard := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30,
+		receiveHook: func(info ReceiveInfo) {
+			if info.AttemptID != "" {
+				t.Errorf("expected no attempt id for standard queues, got %q", info.AttemptID)
+			}
+		}}
+	standard.poll(func(msg *message) {})
+}
+
+func TestMinimalReceiveTraceHeaders(t *testing.T) {
+	m := newMockSQS()
+	cons, err := NewConsumer(Config{QueueURL: "queue", Transport: &awsTransport{sqs: m}, Logger: &testLogger{}, MinimalReceive: true}, "post-worker")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	msg := m.add("queue", "post_created", "{}")
+	msg.MessageAttributes[traceHeaderPrefix+"traceparent"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("00-abc-01")}
+	msg.Attributes = map[string]*string{sqs.MessageSystemAttributeNameAwstraceHeader: aws.String("Root=1-abc")}
+
+	c := cons.(*consumer)
+	var headers map[string]string
+	var header string
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error {
+		headers, header = m.TraceHeaders(), m.TraceHeader()
+		return nil
+	})
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if headers["traceparent"] != "00-abc-01" || header != "Root=1-abc" {
+		t.Errorf("expected the trace headers to be received, got %v and %q", headers, header)
+	}
+}
@@ -0,0 +1,112 @@
+package gosqs
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+const (
+	// credentialBackoffBase is the pause after the first receive that failed because of the credentials
+	credentialBackoffBase = time.Second
+	// credentialBackoffMax caps the pause between client rebuilds
+	credentialBackoffMax = 5 * time.Minute
+)
+
+// credentialErrorCodes are the error codes of requests that failed because the credentials expired, are invalid or could
+// not be retrieved
+var credentialErrorCodes = map[string]bool{
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"RequestExpired":              true,
+	"InvalidClientTokenId":        true,
+	"UnrecognizedClientException": true,
+	"NoCredentialProviders":       true,
+}
+
+// isCredentialError reports whether the request failed because of its credentials
+func isCredentialError(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && credentialErrorCodes[aerr.Code()]
+}
+
+// reconnectingSQS sends the requests of the consumer through a client that is replaced when the clients are rebuilt, it
+// implements sqsiface.SQSAPI in reconnectsqs.go
+type reconnectingSQS struct {
+	mu     sync.RWMutex
+	client sqsiface.SQSAPI
+}
+
+func newReconnectingSQS(client sqsiface.SQSAPI) *reconnectingSQS {
+	return &reconnectingSQS{client: client}
+}
+
+func (r *reconnectingSQS) current() sqsiface.SQSAPI {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.client
+}
+
+func (r *reconnectingSQS) replace(client sqsiface.SQSAPI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client = client
+}
+
+// reconnector rebuilds the SQS client of the consumer when requests fail because of the credentials, e.g. when temporary
+// credentials expired and a refresh failed. Rebuilds back off exponentially until a receive succeeds again
+type reconnector struct {
+	mu       sync.Mutex
+	client   *reconnectingSQS
+	build    func() (sqsiface.SQSAPI, error)
+	failures int
+}
+
+// newReconnector wraps the client, build creates a new client with fresh credentials
+func newReconnector(client sqsiface.SQSAPI, build func() (sqsiface.SQSAPI, error)) *reconnector {
+	return &reconnector{client: newReconnectingSQS(client), build: build}
+}
+
+// rebuild replaces the client and returns how long the receive loop should pause before the next receive
+func (r *reconnector) rebuild() (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures++
+	pause := credentialBackoffMax
+	if r.failures <= 32 {
+		if d := credentialBackoffBase << uint(r.failures-1); d > 0 && d < credentialBackoffMax {
+			pause = d
+		}
+	}
+
+	client, err := r.build()
+	if err != nil {
+		return pause, err
+	}
+
+	r.client.replace(client)
+	return pause, nil
+}
+
+// reset ends the backoff once a receive succeeded
+func (r *reconnector) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = 0
+}
+
+// reconnect rebuilds the clients after a receive failed because of the credentials
+func (c *consumer) reconnect(err error, requestID string) time.Duration {
+	pause, buildErr := c.reconnector.rebuild()
+	if buildErr != nil {
+		c.Logger().Println(ErrCredentials.Context(err).Error(), "request id", requestID, "rebuilding the aws clients failed:", buildErr.Error(), "retrying in", pause)
+		return pause
+	}
+
+	c.Logger().Println(ErrCredentials.Context(err).Error(), "request id", requestID, "rebuilt the aws clients, retrying in", pause)
+	return pause
+}
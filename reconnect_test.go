@@ -0,0 +1,87 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// expiredSQS fails every receive with the error
+type expiredSQS struct {
+	*mockSQS
+	err error
+}
+
+func (m *expiredSQS) ReceiveMessageWithContext(ctx aws.Context, in *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	return nil, m.err
+}
+
+func TestReconnect(t *testing.T) {
+	expired := &expiredSQS{mockSQS: newMockSQS(), err: awserr.New("ExpiredToken", "The security token included in the request is expired", nil)}
+	fresh := newMockSQS()
+	fresh.add("queue", "post_created", "{}")
+
+	builds := 0
+	results := []sqsiface.SQSAPI{expired, fresh}
+	r := newReconnector(expired, func() (sqsiface.SQSAPI, error) {
+		builds++
+		if builds == 1 {
+			return nil, errors.New("sts unavailable")
+		}
+		return results[builds-1], nil
+	})
+
+	var handled int
+	c := &consumer{sqs: r.client, reconnector: r, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		handlers: map[string]Handler{"post_created": func(ctx context.Context, msg Message) error { handled++; return nil }}}
+	dispatch := func(msg *message) { c.run(msg) }
+
+	if pause := c.poll(dispatch); pause != time.Second {
+		t.Errorf("expected the first rebuild to back off for a second, got %v", pause)
+	}
+
+	// the failed rebuild keeps the expired client, the next pause doubles
+	if pause := c.poll(dispatch); pause != 2*time.Second || builds != 2 {
+		t.Errorf("expected a second rebuild after 2s, got %v and %d builds", pause, builds)
+	}
+
+	if pause := c.poll(dispatch); pause != 0 || handled != 1 || r.failures != 0 {
+		t.Errorf("expected the rebuilt client to receive the message, got pause %v, %d handled and %d failures", pause, handled, r.failures)
+	}
+}
+
+func TestIsCredentialError(t *testing.T) {
+	if !isCredentialError(awserr.New("ExpiredTokenException", "expired", nil)) || !isCredentialError(awserr.New("NoCredentialProviders", "no valid providers", nil)) {
+		t.Error("expected expired and missing credentials to be credential errors")
+	}
+
+	if isCredentialError(awserr.New(sqs.ErrCodeQueueDoesNotExist, "missing", nil)) || isCredentialError(errors.New("connection refused")) {
+		t.Error("expected other errors not to be credential errors")
+	}
+}
+
+func TestReconnectQueueTags(t *testing.T) {
+	expired := &expiredSQS{mockSQS: newMockSQS(), err: awserr.New("ExpiredToken", "The security token included in the request is expired", nil)}
+	fresh := newMockSQS()
+	fresh.queueTags = map[string]string{"team": "orders"}
+
+	r := newReconnector(expired, func() (sqsiface.SQSAPI, error) { return fresh, nil })
+	c := &consumer{sqs: r.client, reconnector: r, QueueURL: "queue", logger: &testLogger{}}
+	c.poll(func(msg *message) {})
+
+	tags, err := c.QueueTags(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if tags["team"] != "orders" {
+		t.Errorf("expected the tags to be listed with the rebuilt client, got %v", tags)
+	}
+}
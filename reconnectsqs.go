@@ -0,0 +1,268 @@
+package gosqs
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// reconnectingSQS delegates every operation to the current client, so no request is sent with the credentials of a
+// client that was replaced
+var _ sqsiface.SQSAPI = (*reconnectingSQS)(nil)
+
+func (r *reconnectingSQS) AddPermission(in *sqs.AddPermissionInput) (*sqs.AddPermissionOutput, error) {
+	return r.current().AddPermission(in)
+}
+
+func (r *reconnectingSQS) AddPermissionWithContext(ctx aws.Context, in *sqs.AddPermissionInput, opts ...request.Option) (*sqs.AddPermissionOutput, error) {
+	return r.current().AddPermissionWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) AddPermissionRequest(in *sqs.AddPermissionInput) (*request.Request, *sqs.AddPermissionOutput) {
+	return r.current().AddPermissionRequest(in)
+}
+
+func (r *reconnectingSQS) ChangeMessageVisibility(in *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return r.current().ChangeMessageVisibility(in)
+}
+
+func (r *reconnectingSQS) ChangeMessageVisibilityWithContext(ctx aws.Context, in *sqs.ChangeMessageVisibilityInput, opts ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return r.current().ChangeMessageVisibilityWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) ChangeMessageVisibilityRequest(in *sqs.ChangeMessageVisibilityInput) (*request.Request, *sqs.ChangeMessageVisibilityOutput) {
+	return r.current().ChangeMessageVisibilityRequest(in)
+}
+
+func (r *reconnectingSQS) ChangeMessageVisibilityBatch(in *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	return r.current().ChangeMessageVisibilityBatch(in)
+}
+
+func (r *reconnectingSQS) ChangeMessageVisibilityBatchWithContext(ctx aws.Context, in *sqs.ChangeMessageVisibilityBatchInput, opts ...request.Option) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	return r.current().ChangeMessageVisibilityBatchWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) ChangeMessageVisibilityBatchRequest(in *sqs.ChangeMessageVisibilityBatchInput) (*request.Request, *sqs.ChangeMessageVisibilityBatchOutput) {
+	return r.current().ChangeMessageVisibilityBatchRequest(in)
+}
+
+func (r *reconnectingSQS) CreateQueue(in *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+	return r.current().CreateQueue(in)
+}
+
+func (r *reconnectingSQS) CreateQueueWithContext(ctx aws.Context, in *sqs.CreateQueueInput, opts ...request.Option) (*sqs.CreateQueueOutput, error) {
+	return r.current().CreateQueueWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) CreateQueueRequest(in *sqs.CreateQueueInput) (*request.Request, *sqs.CreateQueueOutput) {
+	return r.current().CreateQueueRequest(in)
+}
+
+func (r *reconnectingSQS) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	return r.current().DeleteMessage(in)
+}
+
+func (r *reconnectingSQS) DeleteMessageWithContext(ctx aws.Context, in *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	return r.current().DeleteMessageWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) DeleteMessageRequest(in *sqs.DeleteMessageInput) (*request.Request, *sqs.DeleteMessageOutput) {
+	return r.current().DeleteMessageRequest(in)
+}
+
+func (r *reconnectingSQS) DeleteMessageBatch(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	return r.current().DeleteMessageBatch(in)
+}
+
+func (r *reconnectingSQS) DeleteMessageBatchWithContext(ctx aws.Context, in *sqs.DeleteMessageBatchInput, opts ...request.Option) (*sqs.DeleteMessageBatchOutput, error) {
+	return r.current().DeleteMessageBatchWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) DeleteMessageBatchRequest(in *sqs.DeleteMessageBatchInput) (*request.Request, *sqs.DeleteMessageBatchOutput) {
+	return r.current().DeleteMessageBatchRequest(in)
+}
+
+func (r *reconnectingSQS) DeleteQueue(in *sqs.DeleteQueueInput) (*sqs.DeleteQueueOutput, error) {
+	return r.current().DeleteQueue(in)
+}
+
+func (r *reconnectingSQS) DeleteQueueWithContext(ctx aws.Context, in *sqs.DeleteQueueInput, opts ...request.Option) (*sqs.DeleteQueueOutput, error) {
+	return r.current().DeleteQueueWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) DeleteQueueRequest(in *sqs.DeleteQueueInput) (*request.Request, *sqs.DeleteQueueOutput) {
+	return r.current().DeleteQueueRequest(in)
+}
+
+func (r *reconnectingSQS) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return r.current().GetQueueAttributes(in)
+}
+
+func (r *reconnectingSQS) GetQueueAttributesWithContext(ctx aws.Context, in *sqs.GetQueueAttributesInput, opts ...request.Option) (*sqs.GetQueueAttributesOutput, error) {
+	return r.current().GetQueueAttributesWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) GetQueueAttributesRequest(in *sqs.GetQueueAttributesInput) (*request.Request, *sqs.GetQueueAttributesOutput) {
+	return r.current().GetQueueAttributesRequest(in)
+}
+
+func (r *reconnectingSQS) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	return r.current().GetQueueUrl(in)
+}
+
+func (r *reconnectingSQS) GetQueueUrlWithContext(ctx aws.Context, in *sqs.GetQueueUrlInput, opts ...request.Option) (*sqs.GetQueueUrlOutput, error) {
+	return r.current().GetQueueUrlWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) GetQueueUrlRequest(in *sqs.GetQueueUrlInput) (*request.Request, *sqs.GetQueueUrlOutput) {
+	return r.current().GetQueueUrlRequest(in)
+}
+
+func (r *reconnectingSQS) ListDeadLetterSourceQueues(in *sqs.ListDeadLetterSourceQueuesInput) (*sqs.ListDeadLetterSourceQueuesOutput, error) {
+	return r.current().ListDeadLetterSourceQueues(in)
+}
+
+func (r *reconnectingSQS) ListDeadLetterSourceQueuesWithContext(ctx aws.Context, in *sqs.ListDeadLetterSourceQueuesInput, opts ...request.Option) (*sqs.ListDeadLetterSourceQueuesOutput, error) {
+	return r.current().ListDeadLetterSourceQueuesWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) ListDeadLetterSourceQueuesRequest(in *sqs.ListDeadLetterSourceQueuesInput) (*request.Request, *sqs.ListDeadLetterSourceQueuesOutput) {
+	return r.current().ListDeadLetterSourceQueuesRequest(in)
+}
+
+func (r *reconnectingSQS) ListDeadLetterSourceQueuesPages(in *sqs.ListDeadLetterSourceQueuesInput, fn func(*sqs.ListDeadLetterSourceQueuesOutput, bool) bool) error {
+	return r.current().ListDeadLetterSourceQueuesPages(in, fn)
+}
+
+func (r *reconnectingSQS) ListDeadLetterSourceQueuesPagesWithContext(ctx aws.Context, in *sqs.ListDeadLetterSourceQueuesInput, fn func(*sqs.ListDeadLetterSourceQueuesOutput, bool) bool, opts ...request.Option) error {
+	return r.current().ListDeadLetterSourceQueuesPagesWithContext(ctx, in, fn, opts...)
+}
+
+func (r *reconnectingSQS) ListQueueTags(in *sqs.ListQueueTagsInput) (*sqs.ListQueueTagsOutput, error) {
+	return r.current().ListQueueTags(in)
+}
+
+func (r *reconnectingSQS) ListQueueTagsWithContext(ctx aws.Context, in *sqs.ListQueueTagsInput, opts ...request.Option) (*sqs.ListQueueTagsOutput, error) {
+	return r.current().ListQueueTagsWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) ListQueueTagsRequest(in *sqs.ListQueueTagsInput) (*request.Request, *sqs.ListQueueTagsOutput) {
+	return r.current().ListQueueTagsRequest(in)
+}
+
+func (r *reconnectingSQS) ListQueues(in *sqs.ListQueuesInput) (*sqs.ListQueuesOutput, error) {
+	return r.current().ListQueues(in)
+}
+
+func (r *reconnectingSQS) ListQueuesWithContext(ctx aws.Context, in *sqs.ListQueuesInput, opts ...request.Option) (*sqs.ListQueuesOutput, error) {
+	return r.current().ListQueuesWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) ListQueuesRequest(in *sqs.ListQueuesInput) (*request.Request, *sqs.ListQueuesOutput) {
+	return r.current().ListQueuesRequest(in)
+}
+
+func (r *reconnectingSQS) ListQueuesPages(in *sqs.ListQueuesInput, fn func(*sqs.ListQueuesOutput, bool) bool) error {
+	return r.current().ListQueuesPages(in, fn)
+}
+
+func (r *reconnectingSQS) ListQueuesPagesWithContext(ctx aws.Context, in *sqs.ListQueuesInput, fn func(*sqs.ListQueuesOutput, bool) bool, opts ...request.Option) error {
+	return r.current().ListQueuesPagesWithContext(ctx, in, fn, opts...)
+}
+
+func (r *reconnectingSQS) PurgeQueue(in *sqs.PurgeQueueInput) (*sqs.PurgeQueueOutput, error) {
+	return r.current().PurgeQueue(in)
+}
+
+func (r *reconnectingSQS) PurgeQueueWithContext(ctx aws.Context, in *sqs.PurgeQueueInput, opts ...request.Option) (*sqs.PurgeQueueOutput, error) {
+	return r.current().PurgeQueueWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) PurgeQueueRequest(in *sqs.PurgeQueueInput) (*request.Request, *sqs.PurgeQueueOutput) {
+	return r.current().PurgeQueueRequest(in)
+}
+
+func (r *reconnectingSQS) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	return r.current().ReceiveMessage(in)
+}
+
+func (r *reconnectingSQS) ReceiveMessageWithContext(ctx aws.Context, in *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	return r.current().ReceiveMessageWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) ReceiveMessageRequest(in *sqs.ReceiveMessageInput) (*request.Request, *sqs.ReceiveMessageOutput) {
+	return r.current().ReceiveMessageRequest(in)
+}
+
+func (r *reconnectingSQS) RemovePermission(in *sqs.RemovePermissionInput) (*sqs.RemovePermissionOutput, error) {
+	return r.current().RemovePermission(in)
+}
+
+func (r *reconnectingSQS) RemovePermissionWithContext(ctx aws.Context, in *sqs.RemovePermissionInput, opts ...request.Option) (*sqs.RemovePermissionOutput, error) {
+	return r.current().RemovePermissionWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) RemovePermissionRequest(in *sqs.RemovePermissionInput) (*request.Request, *sqs.RemovePermissionOutput) {
+	return r.current().RemovePermissionRequest(in)
+}
+
+func (r *reconnectingSQS) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	return r.current().SendMessage(in)
+}
+
+func (r *reconnectingSQS) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	return r.current().SendMessageWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) SendMessageRequest(in *sqs.SendMessageInput) (*request.Request, *sqs.SendMessageOutput) {
+	return r.current().SendMessageRequest(in)
+}
+
+func (r *reconnectingSQS) SendMessageBatch(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+	return r.current().SendMessageBatch(in)
+}
+
+func (r *reconnectingSQS) SendMessageBatchWithContext(ctx aws.Context, in *sqs.SendMessageBatchInput, opts ...request.Option) (*sqs.SendMessageBatchOutput, error) {
+	return r.current().SendMessageBatchWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) SendMessageBatchRequest(in *sqs.SendMessageBatchInput) (*request.Request, *sqs.SendMessageBatchOutput) {
+	return r.current().SendMessageBatchRequest(in)
+}
+
+func (r *reconnectingSQS) SetQueueAttributes(in *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	return r.current().SetQueueAttributes(in)
+}
+
+func (r *reconnectingSQS) SetQueueAttributesWithContext(ctx aws.Context, in *sqs.SetQueueAttributesInput, opts ...request.Option) (*sqs.SetQueueAttributesOutput, error) {
+	return r.current().SetQueueAttributesWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) SetQueueAttributesRequest(in *sqs.SetQueueAttributesInput) (*request.Request, *sqs.SetQueueAttributesOutput) {
+	return r.current().SetQueueAttributesRequest(in)
+}
+
+func (r *reconnectingSQS) TagQueue(in *sqs.TagQueueInput) (*sqs.TagQueueOutput, error) {
+	return r.current().TagQueue(in)
+}
+
+func (r *reconnectingSQS) TagQueueWithContext(ctx aws.Context, in *sqs.TagQueueInput, opts ...request.Option) (*sqs.TagQueueOutput, error) {
+	return r.current().TagQueueWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) TagQueueRequest(in *sqs.TagQueueInput) (*request.Request, *sqs.TagQueueOutput) {
+	return r.current().TagQueueRequest(in)
+}
+
+func (r *reconnectingSQS) UntagQueue(in *sqs.UntagQueueInput) (*sqs.UntagQueueOutput, error) {
+	return r.current().UntagQueue(in)
+}
+
+func (r *reconnectingSQS) UntagQueueWithContext(ctx aws.Context, in *sqs.UntagQueueInput, opts ...request.Option) (*sqs.UntagQueueOutput, error) {
+	return r.current().UntagQueueWithContext(ctx, in, opts...)
+}
+
+func (r *reconnectingSQS) UntagQueueRequest(in *sqs.UntagQueueInput) (*request.Request, *sqs.UntagQueueOutput) {
+	return r.current().UntagQueueRequest(in)
+}
@@ -0,0 +1,130 @@
+package gosqs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// redacted replaces the values that must not be logged
+const redacted = "***"
+
+// redactor hides sensitive message contents before they are logged. A nil redactor leaves everything as is
+type redactor struct {
+	attributes map[string]bool
+	body       func(string) string
+}
+
+// newRedactor creates a redactor from the config, it returns nil if nothing is redacted
+func newRedactor(c Config) *redactor {
+	if len(c.RedactAttributes) == 0 && c.RedactBody == nil {
+		return nil
+	}
+
+	r := &redactor{attributes: map[string]bool{}, body: c.RedactBody}
+	for _, name := range c.RedactAttributes {
+		r.attributes[name] = true
+	}
+
+	return r
+}
+
+// value returns the attribute value to log
+func (r *redactor) value(name string, value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	if r != nil && r.attributes[name] {
+		return redacted
+	}
+
+	return *value
+}
+
+// message returns the body to log
+func (r *redactor) message(body *string) *string {
+	if body == nil || r == nil || r.body == nil {
+		return body
+	}
+
+	out := r.body(*body)
+	return &out
+}
+
+// messageAttributes formats the attributes of a received message for logging, e.g. [route=post_created token=***]
+func (r *redactor) messageAttributes(attrs map[string]*sqs.MessageAttributeValue) string {
+	pairs := make([]string, 0, len(attrs))
+	for k, v := range attrs {
+		if v == nil {
+			continue
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, r.value(k, v.StringValue)))
+	}
+
+	sort.Strings(pairs)
+	return "[" + strings.Join(pairs, " ") + "]"
+}
+
+// sqsAttributes returns a copy of the attributes with redacted values
+func (r *redactor) sqsAttributes(attrs map[string]*sqs.MessageAttributeValue) map[string]*sqs.MessageAttributeValue {
+	if r == nil {
+		return attrs
+	}
+
+	out := make(map[string]*sqs.MessageAttributeValue, len(attrs))
+	for k, v := range attrs {
+		if v != nil && r.attributes[k] {
+			cp := *v
+			cp.StringValue = aws.String(redacted)
+			v = &cp
+		}
+		out[k] = v
+	}
+
+	return out
+}
+
+// input returns a copy of a send input that is safe to log
+func (r *redactor) input(input interface{}) interface{} {
+	if r == nil {
+		return input
+	}
+
+	switch in := input.(type) {
+	case *sns.PublishInput:
+		cp := *in
+		cp.Message = r.message(in.Message)
+		cp.MessageAttributes = make(map[string]*sns.MessageAttributeValue, len(in.MessageAttributes))
+		for k, v := range in.MessageAttributes {
+			if v != nil && r.attributes[k] {
+				attr := *v
+				attr.StringValue = aws.String(redacted)
+				v = &attr
+			}
+			cp.MessageAttributes[k] = v
+		}
+		return &cp
+	case *sqs.SendMessageInput:
+		cp := *in
+		cp.MessageBody = r.message(in.MessageBody)
+		cp.MessageAttributes = r.sqsAttributes(in.MessageAttributes)
+		return &cp
+	case *sqs.SendMessageBatchInput:
+		cp := *in
+		cp.Entries = make([]*sqs.SendMessageBatchRequestEntry, len(in.Entries))
+		for i, e := range in.Entries {
+			entry := *e
+			entry.MessageBody = r.message(e.MessageBody)
+			entry.MessageAttributes = r.sqsAttributes(e.MessageAttributes)
+			cp.Entries[i] = &entry
+		}
+		return &cp
+	}
+
+	return input
+}
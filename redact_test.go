@@ -0,0 +1,43 @@
+package gosqs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestRedactor(t *testing.T) {
+	r := newRedactor(Config{RedactAttributes: []string{"email"}, RedactBody: func(string) string { return "{}" }})
+
+	in := &sns.PublishInput{
+		Message: aws.String(`{"email":"jane@example.com"}`),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"route": {DataType: aws.String("String"), StringValue: aws.String("user_created")},
+			"email": {DataType: aws.String("String"), StringValue: aws.String("jane@example.com")},
+		},
+	}
+
+	out := r.input(in).(*sns.PublishInput)
+	if *out.Message != "{}" || *out.MessageAttributes["email"].StringValue != redacted || *out.MessageAttributes["route"].StringValue != "user_created" {
+		t.Errorf("unexpected redacted input, got %+v", out)
+	}
+
+	if *in.Message == "{}" || *in.MessageAttributes["email"].StringValue == redacted {
+		t.Error("expected the original input to remain untouched")
+	}
+
+	attrs := map[string]*sqs.MessageAttributeValue{
+		"route": {DataType: aws.String("String"), StringValue: aws.String("user_created")},
+		"email": {DataType: aws.String("String"), StringValue: aws.String("jane@example.com")},
+	}
+	if got := r.messageAttributes(attrs); got != "[email=*** route=user_created]" {
+		t.Errorf("unexpected attributes, got %s", got)
+	}
+
+	var none *redactor
+	if got := none.messageAttributes(attrs); got != "[email=jane@example.com route=user_created]" {
+		t.Errorf("expected a nil redactor to keep the values, got %s", got)
+	}
+}
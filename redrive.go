@@ -0,0 +1,234 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// RedriveOption configures a RedriveDLQ run
+type RedriveOption func(*redriveOptions)
+
+type redriveOptions struct {
+	filter       func(Message) bool
+	contentDedup bool
+	rate         float64
+	concurrency  int
+}
+
+// WithRedriveFilter only moves the dead-lettered messages for which the filter returns true, e.g. messages sent within
+// an incident window using m.SentTime() or messages carrying a specific attribute. Messages that do not match remain in
+// the DLQ untouched
+func WithRedriveFilter(filter func(Message) bool) RedriveOption {
+	return func(o *redriveOptions) {
+		o.filter = filter
+	}
+}
+
+// WithRedriveContentDedup omits the MessageDeduplicationId of messages moved into a FIFO queue and relies on its
+// ContentBasedDeduplication setting, which must be enabled
+func WithRedriveContentDedup() RedriveOption {
+	return func(o *redriveOptions) {
+		o.contentDedup = true
+	}
+}
+
+// WithRedriveRate moves at most perSecond messages per second, e.g. so the recovery from an outage does not overwhelm
+// the downstreams of the consumer. Default is unlimited
+func WithRedriveRate(perSecond float64) RedriveOption {
+	return func(o *redriveOptions) {
+		o.rate = perSecond
+	}
+}
+
+// WithRedriveConcurrency moves up to n messages at the same time. Default is 1, messages are moved one after the other.
+// It does not apply when the consumer's queue is FIFO, concurrent sends would reorder the messages of a group
+func WithRedriveConcurrency(n int) RedriveOption {
+	return func(o *redriveOptions) {
+		o.concurrency = n
+	}
+}
+
+const (
+	// redriveDedupPrefix marks the deduplication ids of redriven messages
+	redriveDedupPrefix = "redrive-"
+	// redriveWaitSeconds is the long poll of the DLQ receives, a short poll can return no messages while the DLQ is not
+	// empty which would end the run early
+	redriveWaitSeconds = 1
+)
+
+// RedriveDLQ moves messages from the dead letter queue back into the consumer's queue, preserving the body and message
+// attributes except for the dead letter metadata added by DecodeErrorDeadLetter. It returns the number of messages that were moved once a receive of the DLQ returns no messages
+// within a second or the context is cancelled.
+//
+// Messages skipped by a filter are hidden with the longest visibility timeout SQS accepts so they are not received twice
+// during the run, after which their visibility is reset so they are immediately available in the DLQ again. Skipped
+// messages of a run that does not finish, e.g. because the process exits, stay hidden for up to 12 hours.
+//
+// Messages moved into a FIFO queue keep the MessageGroupId they were dead-lettered with, messages of a standard DLQ use
+// their message ID as the group. The MessageDeduplicationId is derived from the message ID in the DLQ so a message that
+// is sent again after a failed delete is deduplicated, unless WithRedriveContentDedup is set
+//
+// WithRedriveRate and WithRedriveConcurrency throttle the run so a large DLQ can be moved back without a stampede on the
+// downstreams, set Config.MaxMessagesPerSecond to pace the consumer working through the redriven backlog as well
+func (c *consumer) RedriveDLQ(ctx context.Context, dlqURL string, opts ...RedriveOption) (int, error) {
+	o := &redriveOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// concurrent sends would reorder the messages of a group
+	if o.concurrency <= 0 || isFIFO(c.QueueURL) {
+		o.concurrency = 1
+	}
+	limiter := newRateLimiter(o.rate)
+
+	var skipped []*string
+	defer func() { c.releaseMessages(dlqURL, skipped) }()
+
+	var (
+		mu       sync.Mutex
+		moved    int
+		failed   error
+		sem      = make(chan struct{}, o.concurrency)
+		inflight sync.WaitGroup
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return moved, err
+		}
+
+		output, err := c.sqs.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              &dlqURL,
+			MaxNumberOfMessages:   &maxMessages,
+			MessageAttributeNames: []*string{&all},
+			AttributeNames:        []*string{&all},
+			WaitTimeSeconds:       aws.Int64(redriveWaitSeconds),
+		})
+		if err != nil {
+			return moved, ErrGetMessage.Context(err)
+		}
+
+		if len(output.Messages) == 0 {
+			return moved, nil
+		}
+
+		var matched []*sqs.Message
+		var hidden []*string
+		for _, m := range output.Messages {
+			if o.filter != nil && !o.filter(newMessage(m)) {
+				hidden = append(hidden, m.ReceiptHandle)
+				continue
+			}
+			matched = append(matched, m)
+		}
+		c.hideMessages(dlqURL, hidden)
+		skipped = append(skipped, hidden...)
+
+		for i, m := range matched {
+			// the rest of the batch is released instead of staying hidden in the DLQ
+			if err := limiter.wait(ctx); err != nil {
+				for _, rest := range matched[i:] {
+					skipped = append(skipped, rest.ReceiptHandle)
+				}
+				break
+			}
+
+			sem <- struct{}{}
+			inflight.Add(1)
+			go func(m *sqs.Message) {
+				defer func() { <-sem }()
+				defer inflight.Done()
+
+				err := c.redriveMessage(ctx, dlqURL, o, m)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil && failed == nil {
+					failed = err
+				} else if err == nil {
+					moved++
+				}
+			}(m)
+		}
+		inflight.Wait()
+
+		if failed != nil {
+			return moved, failed
+		}
+	}
+}
+
+// redriveMessage sends a dead-lettered message to the consumer's queue and deletes it from the DLQ
+func (c *consumer) redriveMessage(ctx context.Context, dlqURL string, o *redriveOptions, m *sqs.Message) error {
+	if _, err := c.sqs.SendMessageWithContext(ctx, o.redriveInput(c.QueueURL, m)); err != nil {
+		return ErrPublish.Context(err)
+	}
+
+	if _, err := c.sqs.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{QueueUrl: &dlqURL, ReceiptHandle: m.ReceiptHandle}); err != nil {
+		return ErrUnableToDelete.Context(err)
+	}
+
+	return nil
+}
+
+// redriveInput builds the message sent to the queue for a dead-lettered message
+func (o *redriveOptions) redriveInput(queueURL string, m *sqs.Message) *sqs.SendMessageInput {
+	input := &sqs.SendMessageInput{
+		MessageBody:       m.Body,
+		MessageAttributes: stripDeadLetterAttributes(m.MessageAttributes),
+		QueueUrl:          &queueURL,
+	}
+
+	if !isFIFO(queueURL) {
+		return input
+	}
+
+	input.MessageGroupId = m.MessageId
+	if group := aws.StringValue(m.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]); group != "" {
+		input.MessageGroupId = &group
+	}
+
+	if !o.contentDedup {
+		input.MessageDeduplicationId = aws.String(redriveDedupPrefix + aws.StringValue(m.MessageId))
+	}
+
+	return input
+}
+
+// hideMessages sets the longest visibility timeout on received messages so they are not received again while a run
+// continues
+func (c *consumer) hideMessages(queueURL string, handles []*string) {
+	if len(handles) == 0 {
+		return
+	}
+
+	timeout := int64(maxVisibilityTimeout)
+	entries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, len(handles))
+	for i, h := range handles {
+		entries[i] = &sqs.ChangeMessageVisibilityBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: h, VisibilityTimeout: &timeout}
+	}
+
+	out, err := c.sqs.ChangeMessageVisibilityBatch(&sqs.ChangeMessageVisibilityBatchInput{QueueUrl: &queueURL, Entries: entries})
+	if err != nil {
+		c.Logger().Println(ErrUnableToExtend.Context(err).Error())
+		return
+	}
+
+	for _, f := range out.Failed {
+		c.Logger().Println(ErrUnableToExtend.Context(fmt.Errorf("%s: %s", aws.StringValue(f.Code), aws.StringValue(f.Message))).Error())
+	}
+}
+
+// releaseMessages resets the visibility of received messages so they can be received again right away
+func (c *consumer) releaseMessages(queueURL string, handles []*string) {
+	timeout := int64(0)
+	for _, h := range handles {
+		if _, err := c.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{QueueUrl: &queueURL, ReceiptHandle: h, VisibilityTimeout: &timeout}); err != nil {
+			c.Logger().Println(ErrUnableToExtend.Context(err).Error())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package gosqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// waitRecordingSQS records the long poll of every receive
+type waitRecordingSQS struct {
+	*mockSQS
+
+	waits []int64
+}
+
+func (w *waitRecordingSQS) ReceiveMessageWithContext(ctx aws.Context, in *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	w.waits = append(w.waits, aws.Int64Value(in.WaitTimeSeconds))
+	return w.mockSQS.ReceiveMessageWithContext(ctx, in, opts...)
+}
+
+// visibilityRecordingSQS records the visibility timeouts of every ChangeMessageVisibilityBatch call
+type visibilityRecordingSQS struct {
+	*mockSQS
+
+	timeouts map[string][]int64
+}
+
+func (v *visibilityRecordingSQS) ChangeMessageVisibilityBatch(in *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	for _, e := range in.Entries {
+		v.timeouts[*e.ReceiptHandle] = append(v.timeouts[*e.ReceiptHandle], *e.VisibilityTimeout)
+	}
+	return v.mockSQS.ChangeMessageVisibilityBatch(in)
+}
+
+func TestRedriveDLQ(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+
+	m.add("dlq", "post_created", `{"val":"a"}`)
+	m.add("dlq", "post_deleted", `{"val":"b"}`)
+	m.add("dlq", "post_created", `{"val":"c"}`)
+
+	moved, err := c.RedriveDLQ(context.TODO(), "dlq", WithRedriveFilter(func(msg Message) bool {
+		return msg.Route() == "post_created"
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if moved != 2 {
+		t.Fatalf("expected 2 redriven messages, got %d", moved)
+	}
+
+	if len(m.queues["queue"]) != 2 {
+		t.Errorf("expected 2 messages in the queue, got %d", len(m.queues["queue"]))
+	}
+
+	if len(m.deleted) != 2 {
+		t.Errorf("expected 2 deleted messages, got %d", len(m.deleted))
+	}
+
+	if v, ok := m.visibilities["receipt-2"]; !ok || v != 0 {
+		t.Errorf("expected the skipped message visibility to be reset, got %d, %v", v, ok)
+	}
+}
+
+func TestRedriveDLQHidesSkipped(t *testing.T) {
+	m := &visibilityRecordingSQS{mockSQS: newMockSQS(), timeouts: map[string][]int64{}}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+
+	m.add("dlq", "post_created", `{"val":"a"}`)
+	skipped := m.add("dlq", "post_deleted", `{"val":"b"}`)
+
+	if _, err := c.RedriveDLQ(context.TODO(), "dlq", WithRedriveFilter(func(msg Message) bool {
+		return msg.Route() == "post_created"
+	})); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if got := m.timeouts[*skipped.ReceiptHandle]; len(got) != 1 || got[0] != maxVisibilityTimeout {
+		t.Errorf("expected the skipped message to be hidden for the rest of the run, got %v", got)
+	}
+
+	if v := m.visibilities[*skipped.ReceiptHandle]; v != 0 {
+		t.Errorf("expected the skipped message visibility to be reset, got %d", v)
+	}
+}
+
+func TestRedriveDLQFIFO(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue.fifo", logger: &testLogger{}}
+
+	grouped := m.add("dlq.fifo", "post_created", `{"val":"a"}`)
+	grouped.Attributes[sqs.MessageSystemAttributeNameMessageGroupId] = aws.String("post-1")
+	grouped.Attributes[sqs.MessageSystemAttributeNameMessageDeduplicationId] = aws.String("original")
+	ungrouped := m.add("dlq.fifo", "post_created", `{"val":"b"}`)
+
+	if _, err := c.RedriveDLQ(context.TODO(), "dlq.fifo"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(m.sent) != 2 {
+		t.Fatalf("expected 2 redriven messages, got %d", len(m.sent))
+	}
+
+	if g := aws.StringValue(m.sent[0].MessageGroupId); g != "post-1" {
+		t.Errorf("expected the group id to be preserved, got %q", g)
+	}
+
+	if d := aws.StringValue(m.sent[0].MessageDeduplicationId); d != "redrive-"+*grouped.MessageId {
+		t.Errorf("expected a deduplication id derived from the message id, got %q", d)
+	}
+
+	if g := aws.StringValue(m.sent[1].MessageGroupId); g != *ungrouped.MessageId {
+		t.Errorf("expected messages without a group to use their message id, got %q", g)
+	}
+
+	m.add("dlq.fifo", "post_created", `{"val":"c"}`)
+	if _, err := c.RedriveDLQ(context.TODO(), "dlq.fifo", WithRedriveContentDedup()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if d := m.sent[2].MessageDeduplicationId; d != nil {
+		t.Errorf("expected no deduplication id with content based deduplication, got %s", *d)
+	}
+
+	c.QueueURL = "queue"
+	m.add("dlq.fifo", "post_created", `{"val":"d"}`)
+	if _, err := c.RedriveDLQ(context.TODO(), "dlq.fifo"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if in := m.sent[3]; in.MessageGroupId != nil || in.MessageDeduplicationId != nil {
+		t.Errorf("expected no FIFO fields for a standard queue, got %v", in)
+	}
+}
+
+func TestRedriveDLQLongPolls(t *testing.T) {
+	m := &waitRecordingSQS{mockSQS: newMockSQS()}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+	m.add("dlq", "post_created", "{}")
+
+	if moved, err := c.RedriveDLQ(context.TODO(), "dlq"); err != nil || moved != 1 {
+		t.Fatalf("expected the message to be moved, got %d, %v", moved, err)
+	}
+
+	if len(m.waits) != 2 {
+		t.Fatalf("expected a receive of the message and an empty one, got %d", len(m.waits))
+	}
+
+	for _, w := range m.waits {
+		if w < 1 {
+			t.Errorf("expected the DLQ to be long polled so an empty short poll does not end the run, got %d", w)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package gosqs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// replyToAttribute holds the url of the queue a reply is expected on
+const replyToAttribute = "replyTo"
+
+// WithReplyTo asks the consumer of a message to reply to the queue, e.g. the requester's consumer.ResolvedQueueURL().
+// Combine it with WithCorrelationID to match the reply to the request, consumers answer with consumer.Reply
+func WithReplyTo(queueURL string) PublishOption {
+	return func(o *publishOptions) {
+		o.attributes = append(o.attributes, customAttribute{replyToAttribute, DataTypeString.String(), queueURL})
+	}
+}
+
+// ReplyTo returns the url of the queue the sender expects a reply on, or an empty string
+func (m *message) ReplyTo() string {
+	return m.Attribute(replyToAttribute)
+}
+
+// Reply sends a message to the ReplyTo queue of the request carrying the request's correlation ID, so the requester can match
+// it. It returns ErrNoReplyTo if the request was not sent with WithReplyTo
+func (c *consumer) Reply(ctx context.Context, request Message, event string, body interface{}) error {
+	queueURL := request.ReplyTo()
+	if queueURL == "" {
+		return ErrNoReplyTo
+	}
+
+	if id := request.CorrelationID(); id != "" {
+		ctx = ContextWithCorrelationID(ctx, id)
+	}
+
+	o, err := marshalBody(c.codec, body)
+	if err != nil {
+		return ErrMarshal.Context(err)
+	}
+
+	out := string(o)
+	input := &sqs.SendMessageInput{
+		MessageBody:       &out,
+		MessageAttributes: defaultSQSAttributes(event, c.attributesFor(ctx, queueURL)...),
+		QueueUrl:          &queueURL,
+	}
+
+	if err := checkSize(sqsMessageSize(input.MessageBody, input.MessageAttributes)); err != nil {
+		return err
+	}
+
+	if _, err := c.sqs.SendMessageWithContext(ctx, input); err != nil {
+		return ErrPublish.Context(err)
+	}
+
+	return nil
+}
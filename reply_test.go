@@ -0,0 +1,35 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReply(t *testing.T) {
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+	c := &consumer{sqs: m, QueueURL: "http://localhost:4100/dev-post-worker", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+
+	c.RegisterHandler("check_post", func(ctx context.Context, msg Message) error {
+		return c.Reply(ctx, msg, "post_checked", testStruct{Val: "ok"})
+	})
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "check_post", testStruct{Val: "a"}, WithReplyTo("requester"), WithCorrelationID("req-1")); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	c.poll(func(msg *message) { c.run(msg) })
+
+	reply := newMessage(m.queues["requester"][0])
+	if reply.Route() != "post_checked" {
+		t.Fatalf("expected the reply on the requester's queue, got %v", reply)
+	}
+	if reply.Attribute(defaultCorrelationAttribute) != "req-1" {
+		t.Errorf("expected the reply to carry the correlation ID, got %q", reply.Attribute(defaultCorrelationAttribute))
+	}
+
+	if err := c.Reply(context.TODO(), reply, "post_checked", testStruct{}); !errors.Is(err, ErrNoReplyTo) {
+		t.Errorf("expected %v, got %v", ErrNoReplyTo, err)
+	}
+}
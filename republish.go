@@ -0,0 +1,107 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// attributes of a message republished by RepublishOnRetry, they are overwritten on every attempt so they never accumulate
+const (
+	retryAttemptAttribute   = "retry-attempt"
+	retryLastErrorAttribute = "retry-last-error"
+)
+
+// maxDelaySeconds is the longest delivery delay SQS accepts
+const maxDelaySeconds = 900
+
+// retryAttempt returns how often the message was republished by RepublishOnRetry, it is 0 for a message that never was
+func (m *message) retryAttempt() int {
+	n, _ := strconv.Atoi(m.Attribute(retryAttemptAttribute))
+	return n
+}
+
+// republishAttributes returns the attributes of the republished copy, the original attributes with the attempt and the
+// last error replacing the previous ones. It returns false if they do not fit within the attribute limit
+func (m *message) republishAttributes(attempt int, lastErr string) (map[string]*sqs.MessageAttributeValue, bool) {
+	attrs := make(map[string]*sqs.MessageAttributeValue, len(m.MessageAttributes)+2)
+	for k, v := range m.MessageAttributes {
+		attrs[k] = v
+	}
+
+	// the body was decompressed on receive and is republished as is
+	if m.bodyErr == nil {
+		delete(attrs, contentEncodingAttribute)
+	}
+
+	if len(lastErr) > maxDeadLetterReason {
+		lastErr = lastErr[:maxDeadLetterReason]
+	}
+
+	attrs[retryAttemptAttribute] = &sqs.MessageAttributeValue{DataType: aws.String(DataTypeNumber.String()), StringValue: aws.String(strconv.Itoa(attempt))}
+	attrs[retryLastErrorAttribute] = &sqs.MessageAttributeValue{DataType: aws.String(DataTypeString.String()), StringValue: aws.String(lastErr)}
+
+	return attrs, len(attrs) <= maxMessageAttributes
+}
+
+// retryDelay returns the delivery delay of a republished message, the delay of a RetryAfterError, the retry backoff of
+// the attempt or the visibility timeout, capped at the longest delay SQS accepts
+func (c *consumer) retryDelay(attempt int, err error) int64 {
+	d := time.Duration(c.VisibilityTimeout) * time.Second
+	var retry *RetryAfterError
+	switch {
+	case errors.As(err, &retry):
+		d = retry.Delay
+	case c.retryBackoff != nil:
+		d = c.retryBackoff.delay(attempt)
+	}
+
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds < 0 {
+		return 0
+	}
+	if seconds > maxDelaySeconds {
+		return maxDelaySeconds
+	}
+	return seconds
+}
+
+// republished sends a copy of a failed message carrying the attempt and the error to the queue, it reports whether the
+// copy was sent and the original can be deleted. Messages that were republished MaxReceiveCount times, messages of FIFO
+// queues and messages whose attributes do not fit are redelivered as usual
+func (c *consumer) republished(ctx context.Context, m *message, handlerErr error) bool {
+	if !c.republishOnRetry || isFIFO(c.QueueURL) {
+		return false
+	}
+
+	max := c.maxReceiveCount
+	if max <= 0 {
+		max = defaultMaxReceiveCount
+	}
+
+	attempt := m.retryAttempt() + 1
+	if attempt >= max {
+		return false
+	}
+
+	attrs, ok := m.republishAttributes(attempt, handlerErr.Error())
+	if !ok {
+		return false
+	}
+
+	if _, err := c.sqs.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		MessageBody:       m.Message.Body,
+		MessageAttributes: attrs,
+		DelaySeconds:      aws.Int64(c.retryDelay(attempt, handlerErr)),
+		QueueUrl:          &c.QueueURL,
+	}); err != nil {
+		c.logMessage(m, ErrPublish.Context(err).Error(), aws.StringValue(m.MessageId))
+		return false
+	}
+
+	return true
+}
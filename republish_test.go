@@ -0,0 +1,90 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRepublishOnRetry(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, republishOnRetry: true, maxReceiveCount: 3}
+
+	var attempts []string
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error {
+		attempts = append(attempts, m.Attribute(retryAttemptAttribute)+":"+m.Attribute(retryLastErrorAttribute))
+		return errors.New(strings.Repeat("x", 300))
+	})
+
+	original := *m.add("queue", "post_created", "{}").ReceiptHandle
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(m.deleted) != 1 || m.deleted[0] != original {
+		t.Fatalf("expected the original to be deleted, got %v", m.deleted)
+	}
+
+	if len(m.sent) != 1 || *m.sent[0].QueueUrl != "queue" || *m.sent[0].DelaySeconds != 30 {
+		t.Fatalf("expected a copy delayed by the visibility timeout, got %v", m.sent)
+	}
+
+	attrs := m.sent[0].MessageAttributes
+	if *attrs[retryAttemptAttribute].StringValue != "1" || len(*attrs[retryLastErrorAttribute].StringValue) != maxDeadLetterReason {
+		t.Errorf("expected the attempt and the truncated error, got %v", attrs)
+	}
+
+	if *attrs["route"].StringValue != "post_created" {
+		t.Errorf("expected the original attributes to be kept, got %v", attrs)
+	}
+
+	c.poll(func(msg *message) { c.run(msg) })
+	if len(m.sent) != 2 || *m.sent[1].MessageAttributes[retryAttemptAttribute].StringValue != "2" || len(m.sent[1].MessageAttributes) != len(attrs) {
+		t.Fatalf("expected the attempt to replace the previous one, got %v", m.sent)
+	}
+
+	c.poll(func(msg *message) { c.run(msg) })
+	if len(m.sent) != 2 || len(m.deleted) != 2 {
+		t.Errorf("expected the last attempt to be redelivered as usual, got %d sent and %d deleted", len(m.sent), len(m.deleted))
+	}
+
+	if len(attempts) != 3 || attempts[0] != ":" || !strings.HasPrefix(attempts[2], "2:x") {
+		t.Errorf("expected the handler to see the previous attempts, got %v", attempts)
+	}
+}
+
+func TestRepublishAttributeLimit(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, republishOnRetry: true}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return errors.New("failed") })
+
+	msg := m.add("queue", "post_created", "{}")
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"} {
+		msg.MessageAttributes[name] = msg.MessageAttributes["route"]
+	}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(m.sent) != 0 || len(m.deleted) != 0 {
+		t.Errorf("expected a message without room for the attributes to be redelivered, got %d sent and %d deleted", len(m.sent), len(m.deleted))
+	}
+}
+
+func TestRepublishMinimalReceive(t *testing.T) {
+	m := newMockSQS()
+	conf := Config{QueueURL: "queue", Transport: &awsTransport{sqs: m}, Logger: &testLogger{}, MinimalReceive: true,
+		RepublishOnRetry: true, MaxReceiveCount: 3}
+	cons, err := NewConsumer(conf, "post-worker")
+	if err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	c := cons.(*consumer)
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return errors.New("failed") })
+	m.add("queue", "post_created", "{}")
+	for i := 0; i < 4; i++ {
+		c.poll(func(msg *message) { c.run(msg) })
+	}
+
+	if len(m.sent) != 2 || *m.sent[1].MessageAttributes[retryAttemptAttribute].StringValue != "2" {
+		t.Errorf("expected the attempt to be received and to stop the copies after MaxReceiveCount, got %d sent", len(m.sent))
+	}
+}
@@ -0,0 +1,160 @@
+package gosqs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// queue attributes of high throughput FIFO queues, the SDK has no constants for them
+const (
+	deduplicationScopeAttribute  = "DeduplicationScope"
+	fifoThroughputLimitAttribute = "FifoThroughputLimit"
+)
+
+// defaultMaxReceiveCount is the maxReceiveCount of the RedrivePolicy when Config.MaxReceiveCount is not set
+const defaultMaxReceiveCount = 5
+
+// resolveQueue looks up the url of the consumer's queue, creating the queue when it does not exist and Config.EnsureQueue is set.
+// Queues that already exist are tagged with Config.Tags if Config.TagExistingResources is set
+func (c *consumer) resolveQueue(conf Config, queueName string) error {
+	name := fmt.Sprintf("%s-%s", conf.Env, queueName)
+	lookup := &sqs.GetQueueUrlInput{QueueName: &name}
+	if conf.QueueOwnerAccountID != "" {
+		lookup.QueueOwnerAWSAccountId = &conf.QueueOwnerAccountID
+	}
+
+	o, err := c.sqs.GetQueueUrl(lookup)
+	if err == nil {
+		c.QueueURL = *o.QueueUrl
+		if conf.TagExistingResources && len(conf.Tags) > 0 {
+			if _, err := c.sqs.TagQueue(&sqs.TagQueueInput{QueueUrl: &c.QueueURL, Tags: aws.StringMap(conf.Tags)}); err != nil {
+				return ErrTagResource.Context(err)
+			}
+		}
+		return nil
+	}
+
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != sqs.ErrCodeQueueDoesNotExist || !conf.EnsureQueue {
+		return err
+	}
+
+	input := c.createQueueInput(conf, name)
+	if conf.DeadLetterQueueARN != "" || conf.DeadLetterQueue != "" {
+		policy, err := c.redrivePolicy(conf)
+		if err != nil {
+			return err
+		}
+		input.Attributes[sqs.QueueAttributeNameRedrivePolicy] = &policy
+	}
+
+	created, err := c.sqs.CreateQueue(input)
+	if err != nil {
+		return ErrCreateResource.Context(err)
+	}
+
+	c.QueueURL = *created.QueueUrl
+	return nil
+}
+
+// createQueueInput returns the input for creating a queue with Config.Tags applied
+func (c *consumer) createQueueInput(conf Config, name string) *sqs.CreateQueueInput {
+	input := &sqs.CreateQueueInput{QueueName: &name, Attributes: map[string]*string{}}
+	if len(conf.Tags) > 0 {
+		input.Tags = aws.StringMap(conf.Tags)
+	}
+
+	if isFIFO(name) {
+		input.Attributes[sqs.QueueAttributeNameFifoQueue] = aws.String("true")
+		if conf.HighThroughputFIFO {
+			input.Attributes[deduplicationScopeAttribute] = aws.String("messageGroup")
+			input.Attributes[fifoThroughputLimitAttribute] = aws.String("perMessageGroupId")
+		}
+	}
+
+	return input
+}
+
+// redrivePolicy builds the RedrivePolicy attribute of a created queue. When only the name of the dead letter queue is configured
+// it is created, CreateQueue returns the existing queue if it already exists
+func (c *consumer) redrivePolicy(conf Config) (string, error) {
+	arn := conf.DeadLetterQueueARN
+	if arn == "" {
+		created, err := c.sqs.CreateQueue(c.createQueueInput(conf, fmt.Sprintf("%s-%s", conf.Env, conf.DeadLetterQueue)))
+		if err != nil {
+			return "", ErrCreateResource.Context(err)
+		}
+
+		attrs, err := c.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+			QueueUrl:       created.QueueUrl,
+			AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+		})
+		if err != nil {
+			return "", ErrQueueAttributes.Context(err)
+		}
+
+		v, ok := attrs.Attributes[sqs.QueueAttributeNameQueueArn]
+		if !ok || v == nil {
+			return "", ErrQueueAttributes.Context(fmt.Errorf("missing QueueArn of %s", *created.QueueUrl))
+		}
+		arn = *v
+	}
+
+	count := conf.MaxReceiveCount
+	if count <= 0 {
+		count = defaultMaxReceiveCount
+	}
+
+	policy, err := json.Marshal(map[string]string{"deadLetterTargetArn": arn, "maxReceiveCount": strconv.Itoa(count)})
+	if err != nil {
+		return "", ErrMarshal.Context(err)
+	}
+
+	return string(policy), nil
+}
+
+// ensureTopic creates the publisher's topic with Config.Tags applied. CreateTopic is idempotent, an existing topic is returned
+// as is and is only tagged if Config.TagExistingResources is set
+func (p *publisher) ensureTopic(conf Config, name string) error {
+	input := &sns.CreateTopicInput{Name: &name}
+	if isFIFO(name) {
+		input.Attributes = map[string]*string{"FifoTopic": aws.String("true")}
+	}
+
+	for k, v := range conf.Tags {
+		input.Tags = append(input.Tags, &sns.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+
+	o, err := p.sns.CreateTopic(input)
+	if err != nil {
+		// tags on CreateTopic must match the tags of an existing topic, tag it separately instead
+		aerr, ok := err.(awserr.Error)
+		if !ok || aerr.Code() != sns.ErrCodeInvalidParameterException || len(input.Tags) == 0 {
+			return ErrCreateResource.Context(err)
+		}
+
+		input.Tags = nil
+		if o, err = p.sns.CreateTopic(input); err != nil {
+			return ErrCreateResource.Context(err)
+		}
+	}
+
+	p.arn = *o.TopicArn
+	if conf.TagExistingResources && len(conf.Tags) > 0 {
+		tags := make([]*sns.Tag, 0, len(conf.Tags))
+		for k, v := range conf.Tags {
+			tags = append(tags, &sns.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+
+		if _, err := p.sns.TagResource(&sns.TagResourceInput{ResourceArn: &p.arn, Tags: tags}); err != nil {
+			return ErrTagResource.Context(err)
+		}
+	}
+
+	return nil
+}
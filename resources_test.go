@@ -0,0 +1,142 @@
+package gosqs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestResolveQueue(t *testing.T) {
+	tags := map[string]string{"team": "platform"}
+
+	t.Run("create", func(t *testing.T) {
+		m := newMockSQS()
+		c := &consumer{sqs: m}
+		if err := c.resolveQueue(Config{Env: "dev", EnsureQueue: true, Tags: tags}, "post-worker"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if len(m.created) != 1 || *m.created[0].Tags["team"] != "platform" {
+			t.Fatalf("expected the queue to be created with tags, got %+v", m.created)
+		}
+
+		if c.ResolvedQueueURL() != "http://localhost:4100/dev-post-worker" {
+			t.Errorf("unexpected queue url, got %s", c.ResolvedQueueURL())
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		c := &consumer{sqs: newMockSQS()}
+		if err := c.resolveQueue(Config{Env: "dev"}, "post-worker"); err == nil {
+			t.Fatal("expected an error for a missing queue without EnsureQueue")
+		}
+	})
+
+	t.Run("tag_existing", func(t *testing.T) {
+		m := newMockSQS()
+		m.urls = map[string]string{"dev-post-worker": "http://localhost:4100/dev-post-worker"}
+		c := &consumer{sqs: m}
+		if err := c.resolveQueue(Config{Env: "dev", EnsureQueue: true, Tags: tags, TagExistingResources: true}, "post-worker"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if len(m.created) != 0 || len(m.tagged) != 1 {
+			t.Errorf("expected the existing queue to be tagged, got %d created and %d tagged", len(m.created), len(m.tagged))
+		}
+	})
+}
+
+func TestResolveQueueRedrivePolicy(t *testing.T) {
+	t.Run("dlq_arn", func(t *testing.T) {
+		m := newMockSQS()
+		c := &consumer{sqs: m}
+		conf := Config{Env: "dev", EnsureQueue: true, DeadLetterQueueARN: "arn:aws:sqs:local:000000000000:dev-post-worker-dlq", MaxReceiveCount: 3}
+		if err := c.resolveQueue(conf, "post-worker"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		expected := `{"deadLetterTargetArn":"arn:aws:sqs:local:000000000000:dev-post-worker-dlq","maxReceiveCount":"3"}`
+		if len(m.created) != 1 || *m.created[0].Attributes["RedrivePolicy"] != expected {
+			t.Errorf("unexpected redrive policy, got %+v", m.created)
+		}
+	})
+
+	t.Run("create_dlq", func(t *testing.T) {
+		m := newMockSQS()
+		m.attributes = map[string]*string{"QueueArn": aws.String("arn:aws:sqs:local:000000000000:dev-post-worker-dlq")}
+		c := &consumer{sqs: m}
+		if err := c.resolveQueue(Config{Env: "dev", EnsureQueue: true, DeadLetterQueue: "post-worker-dlq"}, "post-worker"); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+
+		if len(m.created) != 2 || *m.created[0].QueueName != "dev-post-worker-dlq" {
+			t.Fatalf("expected the dead letter queue to be created first, got %+v", m.created)
+		}
+
+		expected := `{"deadLetterTargetArn":"arn:aws:sqs:local:000000000000:dev-post-worker-dlq","maxReceiveCount":"5"}`
+		if got := *m.created[1].Attributes["RedrivePolicy"]; got != expected {
+			t.Errorf("unexpected redrive policy, got %s", got)
+		}
+
+		if c.ResolvedQueueURL() != "http://localhost:4100/dev-post-worker" {
+			t.Errorf("unexpected queue url, got %s", c.ResolvedQueueURL())
+		}
+	})
+}
+
+func TestEnsureTopic(t *testing.T) {
+	m := &mockSNS{}
+	p := &publisher{sns: m}
+	if err := p.ensureTopic(Config{Tags: map[string]string{"team": "platform"}}, "todolist-dev.fifo"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if p.ResolvedTopicARN() != "arn:aws:sns:local:000000000000:todolist-dev.fifo" {
+		t.Errorf("unexpected topic arn, got %s", p.ResolvedTopicARN())
+	}
+
+	in := m.created[0]
+	if len(in.Tags) != 1 || *in.Tags[0].Key != "team" {
+		t.Errorf("expected the topic to be created with tags, got %+v", in.Tags)
+	}
+
+	if v := in.Attributes["FifoTopic"]; v == nil || *v != "true" {
+		t.Errorf("expected a fifo topic, got %+v", in.Attributes)
+	}
+}
+
+func TestResolveQueueOwner(t *testing.T) {
+	m := newMockSQS()
+	m.urls = map[string]string{"dev-post-worker": "https://sqs.us-west-1.amazonaws.com/111111111111/dev-post-worker"}
+	c := &consumer{sqs: m}
+	if err := c.resolveQueue(Config{Env: "dev", QueueOwnerAccountID: "111111111111"}, "post-worker"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if owner := m.lookups[0].QueueOwnerAWSAccountId; owner == nil || *owner != "111111111111" {
+		t.Errorf("expected the owner account to be used for resolution, got %v", owner)
+	}
+}
+
+func TestResolveQueueHighThroughputFIFO(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m}
+	if err := c.resolveQueue(Config{Env: "dev", EnsureQueue: true, HighThroughputFIFO: true}, "post-worker.fifo"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	attrs := m.created[0].Attributes
+	if *attrs["FifoQueue"] != "true" || *attrs["DeduplicationScope"] != "messageGroup" || *attrs["FifoThroughputLimit"] != "perMessageGroupId" {
+		t.Errorf("expected a high throughput FIFO queue, got %v", attrs)
+	}
+
+	m = newMockSQS()
+	c = &consumer{sqs: m}
+	if err := c.resolveQueue(Config{Env: "dev", EnsureQueue: true, HighThroughputFIFO: true}, "post-worker"); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if _, ok := m.created[0].Attributes["DeduplicationScope"]; ok {
+		t.Error("expected standard queues not to get FIFO attributes")
+	}
+}
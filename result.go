@@ -0,0 +1,127 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// resultAction is what the consumer does with a message after a ResultHandler returned
+type resultAction int
+
+const (
+	resultAck resultAction = iota
+	resultRetry
+	resultRetryAfter
+	resultDeadLetter
+)
+
+// HandlerResult is the disposition of a message returned by a ResultHandler, build it with ResultAck, ResultRetry,
+// ResultRetryAfter or ResultDeadLetter
+type HandlerResult struct {
+	action resultAction
+	delay  time.Duration
+	err    error
+}
+
+// ResultAck deletes the message
+func ResultAck() HandlerResult {
+	return HandlerResult{action: resultAck}
+}
+
+// ResultRetry redelivers the message like a handler error would, after the visibility timeout or Config.RetryBackoffBase.
+// err describes why and may be nil
+func ResultRetry(err error) HandlerResult {
+	return HandlerResult{action: resultRetry, err: err}
+}
+
+// ResultRetryAfter redelivers the message after the delay, see RetryAfter. err describes why and may be nil
+func ResultRetryAfter(delay time.Duration, err error) HandlerResult {
+	return HandlerResult{action: resultRetryAfter, delay: delay, err: err}
+}
+
+// ResultDeadLetter moves the message to the dead letter queue right away instead of waiting for its last receive, err is
+// sent as the dead-letter-reason. The queue of WithDeadLetterQueue is used, or Config.DeadLetterQueueURL. Without either
+// the message is retried until the queue's redrive policy moves it
+func ResultDeadLetter(err error) HandlerResult {
+	return HandlerResult{action: resultDeadLetter, err: err}
+}
+
+// ResultHandler processes a message and decides explicitly what happens to it instead of deleting it on success and
+// redelivering it on error
+type ResultHandler func(ctx context.Context, m Message) HandlerResult
+
+// RegisterResultHandler registers a handler returning a HandlerResult. The result is turned into the error the consumer
+// already understands, so adapters, metrics and hooks see ResultAck as success and every other result as an error
+//
+//	consumer.RegisterResultHandler("invoice_created", func(ctx context.Context, m gosqs.Message) gosqs.HandlerResult {
+//		if err := charge(ctx, m); errors.Is(err, errRateLimited) {
+//			return gosqs.ResultRetryAfter(time.Minute, err)
+//		} else if err != nil {
+//			return gosqs.ResultDeadLetter(err)
+//		}
+//		return gosqs.ResultAck()
+//	})
+func (c *consumer) RegisterResultHandler(name string, h ResultHandler, adapters ...Adapter) {
+	c.RegisterHandler(name, resultHandler(h), adapters...)
+}
+
+// resultHandler adapts a ResultHandler to a Handler
+func resultHandler(h ResultHandler) Handler {
+	return func(ctx context.Context, m Message) error {
+		return h(ctx, m).error()
+	}
+}
+
+// error returns the handler error that has the consumer carry out the result
+func (r HandlerResult) error() error {
+	err := r.err
+	if err == nil && r.action != resultAck {
+		err = ErrRetry
+	}
+
+	switch r.action {
+	case resultRetry:
+		return err
+	case resultRetryAfter:
+		return &RetryAfterError{Delay: r.delay, Err: r.err}
+	case resultDeadLetter:
+		return &deadLetterResultError{err: err}
+	default:
+		return nil
+	}
+}
+
+// deadLetterResultError is returned for ResultDeadLetter
+type deadLetterResultError struct {
+	err error
+}
+
+// Error is used for implementing the error interface
+func (e *deadLetterResultError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the reason of the dead letter
+func (e *deadLetterResultError) Unwrap() error {
+	return e.err
+}
+
+// deadLettered moves a message whose handler returned ResultDeadLetter to the dead letter queue, it reports whether the
+// message was moved and can be deleted
+func (c *consumer) deadLettered(ctx context.Context, m *message, err error) bool {
+	var result *deadLetterResultError
+	if !errors.As(err, &result) {
+		return false
+	}
+
+	queueURL := c.deadLetterQueue(err)
+	if queueURL == "" {
+		c.logMessage(m, ErrNoDeadLetterQueue.Error(), m.Route(), aws.StringValue(m.MessageId))
+		return false
+	}
+
+	return c.sendDeadLetter(ctx, m, queueURL, result.err)
+}
@@ -0,0 +1,93 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegisterResultHandler(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, deadLetterQueueURL: "dlq"}
+
+	results := map[string]HandlerResult{
+		"ack":         ResultAck(),
+		"retry":       ResultRetry(nil),
+		"retry_after": ResultRetryAfter(45*time.Second, errors.New("rate limited")),
+		"dead_letter": ResultDeadLetter(errors.New("invalid invoice")),
+	}
+
+	var errs []error
+	capture := func(fn Handler) Handler {
+		return func(ctx context.Context, m Message) error {
+			err := fn(ctx, m)
+			errs = append(errs, err)
+			return err
+		}
+	}
+
+	for route, result := range results {
+		result := result
+		c.RegisterResultHandler(route, func(ctx context.Context, m Message) HandlerResult { return result }, capture)
+	}
+
+	ids := map[string]string{}
+	for _, route := range []string{"ack", "retry", "retry_after", "dead_letter"} {
+		ids[route] = *m.add("queue", route, "{}").ReceiptHandle
+	}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	deleted := map[string]bool{}
+	for _, d := range m.deleted {
+		deleted[d] = true
+	}
+
+	if !deleted[ids["ack"]] || !deleted[ids["dead_letter"]] {
+		t.Errorf("expected acked and dead-lettered messages to be deleted, got %v", deleted)
+	}
+
+	if deleted[ids["retry"]] || deleted[ids["retry_after"]] {
+		t.Errorf("expected retried messages to stay on the queue, got %v", deleted)
+	}
+
+	if v, ok := m.visibilities[ids["retry_after"]]; !ok || v != 45 {
+		t.Errorf("expected the retry delay as visibility, got %d, %v", v, ok)
+	}
+
+	if len(m.queues["dlq"]) != 1 {
+		t.Fatalf("expected the message in the dead letter queue, got %d", len(m.queues["dlq"]))
+	}
+
+	if reason := newMessage(m.queues["dlq"][0]).Attribute(deadLetterReasonAttribute); reason != "invalid invoice" {
+		t.Errorf("expected the reason of the result, got %q", reason)
+	}
+
+	var retried bool
+	for _, err := range errs {
+		retried = retried || errors.Is(err, ErrRetry)
+	}
+	if !retried {
+		t.Errorf("expected a retry without a reason to fail with %v, got %v", ErrRetry, errs)
+	}
+}
+
+func TestResultDeadLetterWithoutQueue(t *testing.T) {
+	m := newMockSQS()
+	logger := &testLogger{}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: logger, VisibilityTimeout: 30, extensionLimit: 2}
+	c.RegisterResultHandler("post_created", func(ctx context.Context, m Message) HandlerResult {
+		return ResultDeadLetter(errors.New("invalid"))
+	})
+
+	m.add("queue", "post_created", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(m.deleted) != 0 {
+		t.Errorf("expected the message to be retried without a dead letter queue, got %d deletes", len(m.deleted))
+	}
+
+	if len(logger.lines) == 0 {
+		t.Error("expected the missing dead letter queue to be logged")
+	}
+}
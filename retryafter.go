@@ -0,0 +1,58 @@
+package gosqs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryAfterError is returned by a handler to have the message redelivered after Delay instead of after the visibility
+// timeout. Err optionally describes why the message is retried
+type RetryAfterError struct {
+	Delay time.Duration
+	Err   error
+}
+
+// RetryAfter returns an error that makes the consumer redeliver the message after the delay, e.g. when a downstream asked
+// to back off for 30 seconds. The delay is rounded to seconds and capped at 12 hours
+func RetryAfter(delay time.Duration) error {
+	return &RetryAfterError{Delay: delay}
+}
+
+// Error is used for implementing the error interface
+func (e *RetryAfterError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("retry after %s: %s", e.Delay, e.Err.Error())
+	}
+
+	return fmt.Sprintf("retry after %s", e.Delay)
+}
+
+// Unwrap returns the reason of the retry
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// retryAfter changes the visibility of the message to the delay requested by the handler, it reports whether the handler
+// requested a delay
+func (c *consumer) retryAfter(m *message, err error) bool {
+	var retry *RetryAfterError
+	if !errors.As(err, &retry) {
+		return false
+	}
+
+	seconds := int64(retry.Delay.Round(time.Second) / time.Second)
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	if seconds > maxVisibilityTimeout {
+		seconds = maxVisibilityTimeout
+	}
+
+	if err := c.changeVisibility(m.ReceiptHandle, seconds); err != nil {
+		c.logMessage(m, err.Error(), m.Route())
+	}
+
+	return true
+}
@@ -0,0 +1,36 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		handlers: map[string]Handler{
+			"rate_limited": func(ctx context.Context, msg Message) error {
+				return fmt.Errorf("downstream: %w", RetryAfter(45*time.Second))
+			},
+			"failed": func(ctx context.Context, msg Message) error { return errors.New("failed") },
+		}}
+
+	limited := m.add("queue", "rate_limited", "{}")
+	failed := m.add("queue", "failed", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if v, ok := m.visibilities[*limited.ReceiptHandle]; !ok || v != 45 {
+		t.Errorf("expected the message to be delayed by 45s, got %d", v)
+	}
+
+	if _, ok := m.visibilities[*failed.ReceiptHandle]; ok {
+		t.Error("expected a regular failure to keep the visibility timeout")
+	}
+
+	if len(m.deleted) != 0 {
+		t.Errorf("expected no message to be deleted, got %v", m.deleted)
+	}
+}
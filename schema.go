@@ -0,0 +1,46 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+)
+
+// schemaVersionAttribute holds the version of the body's schema
+const schemaVersionAttribute = "schema-version"
+
+// WithSchemaVersion sets the version of the body's schema, it is sent as the schema-version attribute so consumers can
+// decode bodies of producers that run different versions, see VersionedHandler
+func WithSchemaVersion(version string) PublishOption {
+	return func(o *publishOptions) {
+		o.attributes = append(o.attributes, customAttribute{schemaVersionAttribute, DataTypeString.String(), version})
+	}
+}
+
+// SchemaVersion returns the schema version the message was sent with, or an empty string
+func (m *message) SchemaVersion() string {
+	return m.Attribute(schemaVersionAttribute)
+}
+
+// VersionedHandler returns a handler that runs the handler registered for the message's schema version, messages without
+// a version are handled by the latest version. Messages of an unknown version fail with ErrSchemaVersion and are retried,
+// e.g. until a consumer that knows the version is deployed
+//
+//	c.RegisterHandler("post_published", gosqs.VersionedHandler("2", map[string]gosqs.Handler{
+//		"1": handlePostV1,
+//		"2": handlePostV2,
+//	}))
+func VersionedHandler(latest string, handlers map[string]Handler) Handler {
+	return func(ctx context.Context, m Message) error {
+		version := m.SchemaVersion()
+		if version == "" {
+			version = latest
+		}
+
+		h, ok := handlers[version]
+		if !ok {
+			return ErrSchemaVersion.Context(fmt.Errorf("no handler for version %q of %s", version, m.Route()))
+		}
+
+		return h(ctx, m)
+	}
+}
@@ -0,0 +1,48 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVersionedHandler(t *testing.T) {
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+	c := &consumer{sqs: m, QueueURL: "http://localhost:4100/dev-post-worker", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+
+	var versions []string
+	handler := func(version string) Handler {
+		return func(ctx context.Context, msg Message) error {
+			versions = append(versions, version)
+			return nil
+		}
+	}
+
+	h := VersionedHandler("2", map[string]Handler{"1": handler("1"), "2": handler("2")})
+	c.RegisterHandler("post_published", h)
+
+	for _, opts := range [][]PublishOption{{WithSchemaVersion("1")}, {}} {
+		if _, err := p.PublishTo(context.TODO(), "post-worker", "post_published", testStruct{Val: "a"}, opts...); err != nil {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(versions) != 2 || versions[0] != "1" || versions[1] != "2" {
+		t.Fatalf("expected the versioned and the latest handler to run, got %v", versions)
+	}
+
+	if _, err := p.PublishTo(context.TODO(), "post-worker", "post_published", testStruct{Val: "a"}, WithSchemaVersion("3")); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+	out, _ := m.ReceiveMessage(c.receiveInput())
+	msg := newMessage(out.Messages[0])
+	if msg.SchemaVersion() != "3" {
+		t.Fatalf("expected the schema version attribute, got %q", msg.SchemaVersion())
+	}
+
+	if err := h(context.TODO(), msg); !errors.Is(err, ErrSchemaVersion) {
+		t.Errorf("expected %v, got %v", ErrSchemaVersion, err)
+	}
+}
@@ -0,0 +1,42 @@
+package gosqs
+
+// defaultSelfSourceAttribute is the attribute ServiceName is sent as
+const defaultSelfSourceAttribute = "source-service"
+
+// selfSourceAttribute returns the configured source attribute or the default
+func selfSourceAttribute(attr string) string {
+	if attr == "" {
+		return defaultSelfSourceAttribute
+	}
+	return attr
+}
+
+// selfSource recognizes messages sent by the consuming service itself. A nil selfSource matches no message
+type selfSource struct {
+	attribute string
+	value     string
+}
+
+// newSelfSource creates a selfSource from the config, it returns nil if SelfSourceValue is not set
+func newSelfSource(c Config) *selfSource {
+	if c.SelfSourceValue == "" {
+		return nil
+	}
+
+	return &selfSource{attribute: selfSourceAttribute(c.SelfSourceAttribute), value: c.SelfSourceValue}
+}
+
+// matches reports whether the message was sent by the service itself
+func (s *selfSource) matches(m Message) bool {
+	return s != nil && m.Attribute(s.attribute) == s.value
+}
+
+// selfPublished deletes the message without processing it if it was sent by the service itself
+func (c *consumer) selfPublished(m *message) bool {
+	if !c.selfSource.matches(m) {
+		return false
+	}
+
+	c.delete(m)
+	return true
+}
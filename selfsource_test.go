@@ -0,0 +1,42 @@
+package gosqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestSelfPublished(t *testing.T) {
+	m := newMockSQS()
+	var handled []string
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		selfSource: newSelfSource(Config{SelfSourceValue: "post-service"}),
+		handlers: map[string]Handler{
+			"post_published": func(ctx context.Context, msg Message) error {
+				handled = append(handled, msg.Attribute("source-service"))
+				return nil
+			},
+		}}
+
+	own := m.add("queue", "post_published", "{}")
+	own.MessageAttributes["source-service"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("post-service")}
+	other := m.add("queue", "post_published", "{}")
+	other.MessageAttributes["source-service"] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("comment-service")}
+	m.add("queue", "post_published", "{}")
+
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(handled) != 2 || handled[0] != "comment-service" || handled[1] != "" {
+		t.Errorf("expected only messages of other sources to be handled, got %v", handled)
+	}
+
+	if len(m.deleted) != 3 || m.deleted[0] != *own.ReceiptHandle {
+		t.Errorf("expected the own message to be deleted, got %v", m.deleted)
+	}
+
+	if s := newSelfSource(Config{SelfSourceAttribute: "origin"}); s != nil {
+		t.Error("expected no self source without a value")
+	}
+}
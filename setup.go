@@ -0,0 +1,60 @@
+package gosqs
+
+import (
+	"fmt"
+)
+
+// SetupStage identifies the step of NewConsumer or NewPublisher that failed
+type SetupStage int
+
+const (
+	// SetupValidation the configuration is invalid, retrying will not help
+	SetupValidation SetupStage = iota + 1
+	// SetupCredentials the session could not be created from the configured credentials or SessionProvider
+	SetupCredentials
+	// SetupResolution the queue or topic could not be resolved, created, tagged or synced. This includes network failures
+	// and missing permissions, the cause can be inspected with errors.As, e.g. for an awserr.Error
+	SetupResolution
+	// SetupWarmup the Config.WarmupFunc returned an error, the consumer is not ready to process messages
+	SetupWarmup
+)
+
+func (s SetupStage) String() string {
+	switch s {
+	case SetupValidation:
+		return "validation"
+	case SetupCredentials:
+		return "credentials"
+	case SetupResolution:
+		return "resolution"
+	case SetupWarmup:
+		return "warmup"
+	default:
+		return "unknown"
+	}
+}
+
+// SetupError is returned by NewConsumer and NewPublisher, use errors.As to branch on the failed Stage
+type SetupError struct {
+	Stage SetupStage
+	Err   error
+}
+
+// Error is used for implementing the error interface
+func (e *SetupError) Error() string {
+	return fmt.Sprintf("gosqs setup failed during %s: %s", e.Stage, e.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (e *SetupError) Unwrap() error {
+	return e.Err
+}
+
+// setupErr wraps the error of a setup stage, nil errors remain nil
+func setupErr(stage SetupStage, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &SetupError{Stage: stage, Err: err}
+}
@@ -0,0 +1,75 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestSetupError(t *testing.T) {
+	stage := func(err error) SetupStage {
+		var serr *SetupError
+		if !errors.As(err, &serr) {
+			t.Fatalf("expected a SetupError, got %v", err)
+		}
+		return serr.Stage
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := NewPublisher(Config{WorkerPool: -1})
+		if stage(err) != SetupValidation || !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("unexpected error, got %v", err)
+		}
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		_, err := NewConsumer(Config{Region: "local"}, "post-worker")
+		if stage(err) != SetupCredentials || !errors.Is(err, ErrInvalidCreds) {
+			t.Errorf("unexpected error, got %v", err)
+		}
+	})
+
+	t.Run("resolution", func(t *testing.T) {
+		down := errors.New("connection refused")
+		provider := func(c Config) (*session.Session, error) {
+			cfg := aws.NewConfig().WithRegion("local").WithCredentials(credentials.NewStaticCredentials("key", "secret", "")).WithMaxRetries(0)
+			sess, err := session.NewSession(cfg)
+			if err != nil {
+				return nil, err
+			}
+
+			sess.Handlers.Send.Clear()
+			sess.Handlers.Send.PushBack(func(r *request.Request) { r.Error = down })
+			return sess, nil
+		}
+
+		_, err := NewConsumer(Config{SessionProvider: provider, Env: "dev"}, "post-worker")
+		if stage(err) != SetupResolution || !errors.Is(err, down) {
+			t.Errorf("unexpected error, got %v", err)
+		}
+	})
+	t.Run("warmup", func(t *testing.T) {
+		cold := errors.New("cache not ready")
+		var deadline bool
+		conf := Config{QueueURL: "queue", Transport: &awsTransport{sqs: newMockSQS()}, WarmupTimeout: time.Second, WarmupFunc: func(ctx context.Context) error {
+			_, deadline = ctx.Deadline()
+			return cold
+		}}
+
+		_, err := NewConsumer(conf, "post-worker")
+		if stage(err) != SetupWarmup || !errors.Is(err, ErrWarmup) || !errors.Is(err, cold) || !deadline {
+			t.Errorf("unexpected error, got %v", err)
+		}
+
+		conf.WarmupFunc = func(ctx context.Context) error { return nil }
+		if _, err := NewConsumer(conf, "post-worker"); err != nil {
+			t.Errorf("unexpected error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package gosqs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// ShutdownMode determines what Shutdown does with the messages that were received but are not finished
+type ShutdownMode int
+
+const (
+	// ShutdownDrain waits for the received messages to finish processing (default)
+	ShutdownDrain ShutdownMode = iota
+	// ShutdownFastHandoff makes the received messages visible again right away so other consumers receive them instead of
+	// waiting out their visibility timeout. Messages whose handler is still running may be processed twice
+	ShutdownFastHandoff
+)
+
+// shutdownPollInterval is how often Shutdown checks whether the received messages have finished
+const shutdownPollInterval = 50 * time.Millisecond
+
+// lifecycle stops the receive loop on shutdown and tracks the received messages that have not finished processing.
+// The zero value is running
+type lifecycle struct {
+	mu        sync.Mutex
+	stopping  bool
+	handedOff bool
+	stopped   chan struct{}
+	finished  chan struct{}
+	messages  map[*message]struct{}
+}
+
+// done returns a channel that is closed once the consumer is shutting down
+func (l *lifecycle) done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stoppedChan()
+}
+
+// stoppedChan creates the shutdown channel on first use, the lock must be held
+func (l *lifecycle) stoppedChan() chan struct{} {
+	if l.stopped == nil {
+		l.stopped = make(chan struct{})
+	}
+	return l.stopped
+}
+
+// ended returns a channel that is closed once Shutdown has returned
+func (l *lifecycle) ended() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.finishedChan()
+}
+
+// finishedChan creates the channel of ended on first use, the lock must be held
+func (l *lifecycle) finishedChan() chan struct{} {
+	if l.finished == nil {
+		l.finished = make(chan struct{})
+	}
+	return l.finished
+}
+
+// end marks Shutdown as returned
+func (l *lifecycle) end() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.finishedChan():
+	default:
+		close(l.finished)
+	}
+}
+
+// isStopping reports whether Shutdown has been called
+func (l *lifecycle) isStopping() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopping
+}
+
+// stop ends the receive loop, it returns the unfinished messages when they are handed off
+func (l *lifecycle) stop(handoff bool) []*message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.stopping {
+		l.stopping = true
+		close(l.stoppedChan())
+	}
+
+	if !handoff || l.handedOff {
+		return nil
+	}
+
+	l.handedOff = true
+	messages := make([]*message, 0, len(l.messages))
+	for m := range l.messages {
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+// track registers a received message until it finishes
+func (l *lifecycle) track(m *message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.messages == nil {
+		l.messages = map[*message]struct{}{}
+	}
+	l.messages[m] = struct{}{}
+}
+
+// untrack removes a finished message
+func (l *lifecycle) untrack(m *message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.messages, m)
+}
+
+// isHandedOff reports whether the unfinished messages were released by a fast handoff
+func (l *lifecycle) isHandedOff() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.handedOff
+}
+
+// pending returns the number of unfinished messages
+func (l *lifecycle) pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.messages)
+}
+
+// sleep pauses the receive loop, returning early once the consumer is shutting down
+func (l *lifecycle) sleep(d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-l.done():
+	}
+}
+
+// receiveContext returns a context that is cancelled once the consumer is shutting down, so a long poll does not delay
+// the shutdown
+func (l *lifecycle) receiveContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := l.done()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Shutdown stops receiving new messages and makes Consume return. With ShutdownDrain it blocks until the received messages
+// have finished processing or the context is done, with ShutdownFastHandoff it makes them visible again and returns
+// without waiting for their handlers. With FinalDrain the drain continues with the messages remaining in the queue
+func (c *consumer) Shutdown(ctx context.Context) error {
+	// deferred messages are held until Shutdown returns
+	defer c.life.end()
+
+	handoff := c.shutdownMode == ShutdownFastHandoff
+	messages := c.life.stop(handoff)
+	// a paused receive loop has to wake up to notice the shutdown
+	c.gate.open()
+	c.Logger().Println("consumer shutting down", c.QueueURL)
+
+	if handoff {
+		c.handOff(messages)
+		return nil
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for c.life.pending() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	if c.finalDrain {
+		return c.sweep(ctx)
+	}
+
+	return nil
+}
+
+// sweepWaitSeconds is the long poll of the final drain, a receive that returns no messages within it ends the drain
+const sweepWaitSeconds = 1
+
+// sweep receives and processes the remaining messages one batch at a time until the queue is empty or the context is done
+func (c *consumer) sweep(ctx context.Context) error {
+	c.Logger().Println("draining remaining messages", c.QueueURL)
+	dispatch := func(m *message) {
+		if err := c.run(m); err != nil {
+			c.logMessage(m, err.Error())
+		}
+	}
+
+	for {
+		input := c.receiveInput()
+		input.WaitTimeSeconds = aws.Int64(sweepWaitSeconds)
+
+		pause, n := c.receive(ctx, input, dispatch)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// a failed receive or an open circuit breaker ends the drain, the messages are left for the next consumer
+		if pause > 0 || n == 0 {
+			return nil
+		}
+	}
+}
+
+// handOff resets the visibility of the unfinished messages, the changes are sent concurrently so they share
+// ChangeMessageVisibilityBatch calls
+func (c *consumer) handOff(messages []*message) {
+	var wg sync.WaitGroup
+	for _, m := range messages {
+		wg.Add(1)
+		go func(m *message) {
+			defer wg.Done()
+			// a deferred message is released first so a late Ack does not delete it after it was handed off
+			if m.pending != nil && !c.release(m.pending) {
+				return
+			}
+
+			if err := c.changeVisibility(m.ReceiptHandle, 0); err != nil {
+				c.logMessage(m, err.Error(), m.Route())
+			}
+		}(m)
+	}
+	wg.Wait()
+}
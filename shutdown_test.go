@@ -0,0 +1,106 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrain(t *testing.T) {
+	m := newMockSQS()
+	release := make(chan struct{})
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		handlers: map[string]Handler{
+			"post_published": func(ctx context.Context, msg Message) error { <-release; return nil },
+		}}
+
+	m.add("queue", "post_published", `{"val":"a"}`)
+	c.poll(func(msg *message) { go c.run(msg) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shutdown to wait for the running handler, got %v", err)
+	}
+
+	close(release)
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(m.deleted) != 1 {
+		t.Errorf("expected the drained message to be deleted, got %v", m.deleted)
+	}
+
+	done := make(chan struct{})
+	go func() { c.Consume(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Consume to return after Shutdown")
+	}
+}
+
+func TestShutdownFastHandoff(t *testing.T) {
+	m := newMockSQS()
+	release := make(chan struct{})
+	defer close(release)
+
+	var handled int
+	started := make(chan struct{})
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, shutdownMode: ShutdownFastHandoff,
+		handlers: map[string]Handler{
+			"post_published": func(ctx context.Context, msg Message) error { handled++; close(started); <-release; return nil },
+		}}
+
+	running := m.add("queue", "post_published", `{"val":"a"}`)
+	queued := m.add("queue", "post_published", `{"val":"b"}`)
+
+	var received []*message
+	c.poll(func(msg *message) { received = append(received, msg) })
+	go c.run(received[0])
+	<-started
+
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	for _, msg := range []string{*running.ReceiptHandle, *queued.ReceiptHandle} {
+		if v, ok := m.visibilities[msg]; !ok || v != 0 {
+			t.Errorf("expected %s to be made visible, got %v", msg, m.visibilities)
+		}
+	}
+
+	if err := c.run(received[1]); err != nil || handled != 1 {
+		t.Errorf("expected the handed off message not to be processed, got %d runs and %v", handled, err)
+	}
+}
+
+func TestShutdownFinalDrain(t *testing.T) {
+	m := newMockSQS()
+	var handled int
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, finalDrain: true,
+		handlers: map[string]Handler{
+			"post_published": func(ctx context.Context, msg Message) error { handled++; return nil },
+		}}
+
+	for i := 0; i < 25; i++ {
+		m.add("queue", "post_published", `{"val":"a"}`)
+	}
+
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if handled != 25 || len(m.deleted) != 25 {
+		t.Errorf("expected the remaining messages to be processed, got %d handled and %d deleted", handled, len(m.deleted))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.add("queue", "post_published", `{"val":"b"}`)
+	if err := c.sweep(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the drain to stop at the deadline, got %v", err)
+	}
+}
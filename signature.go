@@ -0,0 +1,202 @@
+package gosqs
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signingCertTTL is how long a downloaded signing certificate is cached
+const signingCertTTL = time.Hour
+
+// signingCertHost matches the hosts SNS serves its signing certificates from
+var signingCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// signedEnvelope holds the envelope fields covered by the signature exactly as they were sent
+type signedEnvelope struct {
+	Type             string
+	MessageId        string
+	TopicArn         string
+	Subject          *string
+	Message          string
+	Timestamp        string
+	SignatureVersion string
+	Signature        string
+	SigningCertURL   string
+}
+
+// cachedCert is a signing certificate and the time it was downloaded
+type cachedCert struct {
+	cert    *x509.Certificate
+	fetched time.Time
+}
+
+// signatureVerifier verifies the signatures of SNS envelopes, caching signing certificates by URL. A nil signatureVerifier
+// is disabled
+type signatureVerifier struct {
+	mu    sync.Mutex
+	certs map[string]cachedCert
+
+	fetch func(certURL string) ([]byte, error)
+	now   func() time.Time
+}
+
+// newSignatureVerifier creates a signature verifier from the config, it returns nil if verification is disabled
+func newSignatureVerifier(c Config) *signatureVerifier {
+	if !c.VerifySNSSignature {
+		return nil
+	}
+
+	return &signatureVerifier{certs: map[string]cachedCert{}, fetch: fetchCert, now: time.Now}
+}
+
+// fetchCert downloads a signing certificate
+func fetchCert(certURL string) ([]byte, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// verify checks the signature of an SNS envelope as documented by AWS
+func (v *signatureVerifier) verify(body string) error {
+	var env signedEnvelope
+	if err := json.Unmarshal([]byte(body), &env); err != nil {
+		return ErrInvalidSignature.Context(err)
+	}
+
+	var hash crypto.Hash
+	switch env.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return ErrInvalidSignature.Context(fmt.Errorf("unsupported signature version %q", env.SignatureVersion))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return ErrInvalidSignature.Context(err)
+	}
+
+	cert, err := v.cert(env.SigningCertURL)
+	if err != nil {
+		return ErrInvalidSignature.Context(err)
+	}
+
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ErrInvalidSignature.Context(fmt.Errorf("signing certificate does not hold an RSA key"))
+	}
+
+	if err := rsa.VerifyPKCS1v15(key, hash, digest(hash, env.stringToSign()), signature); err != nil {
+		return ErrInvalidSignature.Context(err)
+	}
+
+	return nil
+}
+
+// stringToSign builds the string SNS signs for a notification
+func (e signedEnvelope) stringToSign() string {
+	var b strings.Builder
+	b.WriteString("Message\n" + e.Message + "\n")
+	b.WriteString("MessageId\n" + e.MessageId + "\n")
+	if e.Subject != nil {
+		b.WriteString("Subject\n" + *e.Subject + "\n")
+	}
+	b.WriteString("Timestamp\n" + e.Timestamp + "\n")
+	b.WriteString("TopicArn\n" + e.TopicArn + "\n")
+	b.WriteString("Type\n" + e.Type + "\n")
+	return b.String()
+}
+
+// digest hashes the string to sign
+func digest(hash crypto.Hash, s string) []byte {
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(s))
+		return sum[:]
+	}
+
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
+
+// cert returns the signing certificate, downloading it if it is not cached or has expired. Only certificates served by SNS
+// over https are accepted
+func (v *signatureVerifier) cert(certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "https" || !signingCertHost.MatchString(u.Hostname()) {
+		return nil, fmt.Errorf("signing certificate %q is not served by SNS", certURL)
+	}
+
+	v.mu.Lock()
+	cached, ok := v.certs[certURL]
+	v.mu.Unlock()
+	if ok && v.now().Sub(cached.fetched) < signingCertTTL {
+		return cached.cert, nil
+	}
+
+	data, err := v.fetch(certURL)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing certificate %q is not PEM encoded", certURL)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	v.certs[certURL] = cachedCert{cert: cert, fetched: v.now()}
+	v.mu.Unlock()
+
+	return cert, nil
+}
+
+// forged deletes an SNS delivery without processing it if its signature cannot be verified. Raw deliveries and direct
+// messages carry no signature and are not checked
+func (c *consumer) forged(m *message) bool {
+	if c.verifier == nil || m.envelope == nil {
+		return false
+	}
+
+	err := c.verifier.verify(m.envelopeBody)
+	if err == nil {
+		return false
+	}
+
+	c.logMessage(m, err.Error(), stringValue(m.MessageId), m.envelope.TopicArn)
+	c.delete(m)
+	return true
+}
@@ -0,0 +1,125 @@
+package gosqs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const testCertURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+
+// signedNotification returns an SNS envelope signed with the key
+func signedNotification(t *testing.T, key *rsa.PrivateKey, version string, hash crypto.Hash, certURL string) signedEnvelope {
+	subject := "urgent"
+	env := signedEnvelope{
+		Type:             "Notification",
+		MessageId:        "sns-1",
+		TopicArn:         "arn:aws:sns:us-east-1:000000000000:todolist-dev",
+		Subject:          &subject,
+		Message:          `{"val":"a"}`,
+		Timestamp:        "2021-03-01T12:00:00.000Z",
+		SignatureVersion: version,
+		SigningCertURL:   certURL,
+	}
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, hash, digest(hash, env.stringToSign()))
+	if err != nil {
+		t.Fatalf("unable to sign, got %v", err)
+	}
+	env.Signature = base64.StdEncoding.EncodeToString(sig)
+	return env
+}
+
+func encodeEnvelope(t *testing.T, env signedEnvelope) string {
+	b, err := json.Marshal(env)
+	if err != nil {
+		t.Fatalf("unable to encode the envelope, got %v", err)
+	}
+	return string(b)
+}
+
+func testSigningCert(t *testing.T, key *rsa.PrivateKey) []byte {
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "sns.amazonaws.com"}, NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create the certificate, got %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate a key, got %v", err)
+	}
+
+	var fetches int
+	v := newSignatureVerifier(Config{VerifySNSSignature: true})
+	v.fetch = func(string) ([]byte, error) { fetches++; return testSigningCert(t, key), nil }
+
+	for version, hash := range map[string]crypto.Hash{"1": crypto.SHA1, "2": crypto.SHA256} {
+		if err := v.verify(encodeEnvelope(t, signedNotification(t, key, version, hash, testCertURL))); err != nil {
+			t.Errorf("expected signature version %s to verify, got %v", version, err)
+		}
+	}
+
+	if fetches != 1 {
+		t.Errorf("expected the certificate to be cached, got %d fetches", fetches)
+	}
+
+	tampered := signedNotification(t, key, "2", crypto.SHA256, testCertURL)
+	tampered.Message = `{"val":"b"}`
+	if err := v.verify(encodeEnvelope(t, tampered)); err == nil {
+		t.Error("expected a tampered message to fail verification")
+	}
+
+	foreign := signedNotification(t, key, "2", crypto.SHA256, "https://attacker.example.com/cert.pem")
+	if err := v.verify(encodeEnvelope(t, foreign)); err == nil {
+		t.Error("expected a certificate not served by SNS to be rejected")
+	}
+
+	if newSignatureVerifier(Config{}) != nil {
+		t.Error("expected verification to be disabled by default")
+	}
+}
+
+func TestRunForged(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate a key, got %v", err)
+	}
+
+	m := newMockSQS()
+	var handled int
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		verifier: newSignatureVerifier(Config{VerifySNSSignature: true}),
+		handlers: map[string]Handler{
+			"post_published": func(ctx context.Context, msg Message) error { handled++; return nil },
+		}}
+	c.verifier.fetch = func(string) ([]byte, error) { return testSigningCert(t, key), nil }
+
+	m.add("queue", "post_published", encodeEnvelope(t, signedNotification(t, key, "2", crypto.SHA256, testCertURL)))
+	forged := signedNotification(t, key, "2", crypto.SHA256, testCertURL)
+	forged.Message = `{"val":"forged"}`
+	m.add("queue", "post_published", encodeEnvelope(t, forged))
+	m.add("queue", "post_published", `{"val":"direct"}`)
+
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if handled != 2 {
+		t.Errorf("expected the signed and the direct message to be handled, got %d", handled)
+	}
+
+	if len(m.deleted) != 3 {
+		t.Errorf("expected every message to be deleted, got %v", m.deleted)
+	}
+}
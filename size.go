@@ -0,0 +1,54 @@
+package gosqs
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// MessageTooLargeError is returned before a message is sent if its body and attributes exceed the 262144 byte limit of SNS
+// and SQS, e.g. to compress or split the payload instead. It matches ErrMessageTooLarge with errors.Is
+type MessageTooLargeError struct {
+	// Size is the size of the body and attributes in bytes
+	Size int
+	// Max is the largest size SNS and SQS accept
+	Max int
+}
+
+// Error is used for implementing the error interface
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %d bytes exceeds the limit of %d bytes", ErrMessageTooLarge.Error(), e.Size, e.Max)
+}
+
+// Is reports whether the target is ErrMessageTooLarge
+func (e *MessageTooLargeError) Is(target error) bool {
+	return target == ErrMessageTooLarge
+}
+
+// checkSize returns a *MessageTooLargeError if the size exceeds the limit
+func checkSize(size int) error {
+	if size > maxBodySize {
+		return &MessageTooLargeError{Size: size, Max: maxBodySize}
+	}
+	return nil
+}
+
+// sqsMessageSize returns the size AWS counts towards the limit, the body plus the name, data type and value of every attribute
+func sqsMessageSize(body *string, attrs map[string]*sqs.MessageAttributeValue) int {
+	size := len(aws.StringValue(body))
+	for name, v := range attrs {
+		size += len(name) + len(aws.StringValue(v.DataType)) + len(aws.StringValue(v.StringValue)) + len(v.BinaryValue)
+	}
+	return size
+}
+
+// snsMessageSize returns the size AWS counts towards the limit, the body plus the name, data type and value of every attribute
+func snsMessageSize(body *string, attrs map[string]*sns.MessageAttributeValue) int {
+	size := len(aws.StringValue(body))
+	for name, v := range attrs {
+		size += len(name) + len(aws.StringValue(v.DataType)) + len(aws.StringValue(v.StringValue)) + len(v.BinaryValue)
+	}
+	return size
+}
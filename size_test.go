@@ -0,0 +1,56 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMessageTooLarge(t *testing.T) {
+	big := strings.Repeat("a", maxBodySize)
+
+	t.Run("publish", func(t *testing.T) {
+		s := &mockSNS{}
+		p := &publisher{sns: s, arn: "arn:aws:sns:local:000000000000:todolist-dev"}
+
+		_, err := p.Publish(context.TODO(), "post_created", big)
+		var tooLarge *MessageTooLargeError
+		if !errors.As(err, &tooLarge) || !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("expected a MessageTooLargeError, got %v", err)
+		}
+
+		// the JSON quotes and the route attribute count towards the limit
+		if tooLarge.Max != maxBodySize || tooLarge.Size != maxBodySize+2+len("route")+len("String")+len("post_created") {
+			t.Errorf("unexpected sizes, got %+v", tooLarge)
+		}
+
+		if len(s.published) != 0 {
+			t.Error("expected the message not to be sent")
+		}
+	})
+
+	t.Run("publish_to", func(t *testing.T) {
+		m := newMockSQS()
+		p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+
+		if _, err := p.PublishTo(context.TODO(), "post-worker", "post_created", big); !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("expected %v, got %v", ErrMessageTooLarge, err)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		m := newMockSQS()
+		p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+
+		half := strings.Repeat("a", maxBodySize/2)
+		res, err := p.PublishBatch(context.TODO(), "post-worker", []BatchEntry{{Event: "a", Body: half}, {Event: "b", Body: half}, {Event: "c", Body: big}})
+		if err == nil || res[0].Err != nil || res[1].Err != nil || !errors.Is(res[2].Err, ErrMessageTooLarge) {
+			t.Fatalf("expected only the oversized entry to fail, got %+v, %v", res, err)
+		}
+
+		if got := len(m.queues["http://localhost:4100/dev-post-worker"]); got != 2 {
+			t.Errorf("expected the fitting entries to be sent, got %d", got)
+		}
+	})
+}
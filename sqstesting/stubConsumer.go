@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/qhenkart/gosqs"
 )
@@ -14,6 +17,12 @@ type StubMessage struct {
 	body     []byte
 	Err      error
 	Endpoint string
+	// Envelope is returned by SNSEnvelope, leave it nil to emulate a raw delivery
+	Envelope *gosqs.SNSEnvelope
+	// ReplyQueue is returned by ReplyTo
+	ReplyQueue string
+	// Version is returned by SchemaVersion
+	Version string
 }
 
 // NewStubMessage returns an encoded stubmessage that is ready to emulate the sqs messenger
@@ -56,7 +65,29 @@ func (sm *StubMessage) Route() string {
 
 // Decode decodes the message into the provided interface
 func (sm *StubMessage) Decode(out interface{}) error {
-	return json.Unmarshal(sm.body, &out)
+	if err := json.Unmarshal(sm.body, &out); err != nil {
+		return gosqs.ErrDecode.Context(err)
+	}
+	return nil
+}
+
+// NewStubRawMessage returns a stubmessage with the body as is, e.g. for handlers registered with RegisterRawHandler
+func NewStubRawMessage(body []byte) *StubMessage {
+	return &StubMessage{body: body}
+}
+
+// RawBody returns the body of the message
+func (sm *StubMessage) RawBody() ([]byte, error) {
+	return sm.body, nil
+}
+
+// DecodeContext decodes the message into a provided interface unless the context is done
+func (sm *StubMessage) DecodeContext(ctx context.Context, out interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return sm.Decode(out)
 }
 
 // DecodeModified decodes the message into a provided interface along with changed values
@@ -87,9 +118,78 @@ func (sm *StubMessage) Attribute(key string) string {
 	return ""
 }
 
+// TraceHeaders returns no trace headers
+func (sm *StubMessage) TraceHeaders() map[string]string {
+	return nil
+}
+
+// Subject returns an empty subject
+func (sm *StubMessage) Subject() string {
+	return ""
+}
+
+// TraceHeader returns an empty trace header
+func (sm *StubMessage) TraceHeader() string {
+	return ""
+}
+
+// CorrelationID returns an empty correlation ID
+func (sm *StubMessage) CorrelationID() string {
+	return ""
+}
+
+// SchemaVersion returns the configured schema version
+func (sm *StubMessage) SchemaVersion() string {
+	return sm.Version
+}
+
+// ReplyTo returns the configured reply queue
+func (sm *StubMessage) ReplyTo() string {
+	return sm.ReplyQueue
+}
+
+// SNSEnvelope returns the configured envelope, ok is false if none is set
+func (sm *StubMessage) SNSEnvelope() (*gosqs.SNSEnvelope, bool) {
+	return sm.Envelope, sm.Envelope != nil
+}
+
+// TopicARN returns an empty topic ARN
+func (sm *StubMessage) TopicARN() string {
+	return ""
+}
+
+// FirstReceiveTime returns the zero time
+func (sm *StubMessage) FirstReceiveTime() time.Time {
+	return time.Time{}
+}
+
+// SentTime returns the zero time
+func (sm *StubMessage) SentTime() time.Time {
+	return time.Time{}
+}
+
+// PublishTime returns the Timestamp of the configured envelope, or the zero time if none is set
+func (sm *StubMessage) PublishTime() time.Time {
+	if sm.Envelope == nil {
+		return time.Time{}
+	}
+	return sm.Envelope.Timestamp
+}
+
+// AttributeRaw returns a fake attribute
+func (sm *StubMessage) AttributeRaw(key string) (string, string, bool) {
+	return "", "", false
+}
+
+var (
+	_ gosqs.Message   = (*StubMessage)(nil)
+	_ gosqs.Consumer  = (*StubConsumer)(nil)
+	_ gosqs.Publisher = (*StubPublisher)(nil)
+)
+
 // StubConsumer provides a stub framework for consumer unit tests
 //
-// SNS messages event names will go into the DispatcherMessages string array
+// SNS messages event names will go into the DispatcherMessages string array.
 //
 // Direct Messages to SQS will go into a map[string]string which defines
 // the queueName as the key and the event as the value. If a message is
@@ -97,6 +197,7 @@ func (sm *StubMessage) Attribute(key string) string {
 type StubConsumer struct {
 	DirectMessages []SentMessage
 	EventList      []string
+	Paused         bool
 }
 
 // NewStubConsumer provides a stub consumer/publisher to place into the handler or context
@@ -140,12 +241,84 @@ func (c *StubConsumer) Message(ctx context.Context, queue, event string, body in
 	c.EventList = append(c.EventList, sm.Event)
 }
 
+// RedriveDLQ satisfies the Consumer interface
+func (c *StubConsumer) RedriveDLQ(ctx context.Context, dlqURL string, opts ...gosqs.RedriveOption) (int, error) {
+	return 0, nil
+}
+
+// ResolvedQueueURL satisfies the Consumer interface
+func (c *StubConsumer) ResolvedQueueURL() string {
+	return ""
+}
+
+// Pause satisfies the Consumer interface
+func (c *StubConsumer) Pause() {
+	c.Paused = true
+}
+
+// Resume satisfies the Consumer interface
+func (c *StubConsumer) Resume() {
+	c.Paused = false
+}
+
+// IsPaused satisfies the Consumer interface
+func (c *StubConsumer) IsPaused() bool {
+	return c.Paused
+}
+
+// Reply saves the message with the ReplyTo queue of the request and satisfies the Consumer interface
+func (c *StubConsumer) Reply(ctx context.Context, request gosqs.Message, event string, body interface{}) error {
+	if request.ReplyTo() == "" {
+		return gosqs.ErrNoReplyTo
+	}
+
+	c.Message(ctx, request.ReplyTo(), event, body)
+	return nil
+}
+
+// Shutdown satisfies the Consumer interface
+func (c *StubConsumer) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Stats satisfies the Consumer interface
+func (c *StubConsumer) Stats() gosqs.ConsumerStats {
+	return gosqs.ConsumerStats{}
+}
+
 // RegisterHandler satisfies the Consumer interface
 func (c *StubConsumer) RegisterHandler(name string, h gosqs.Handler, a ...gosqs.Adapter) {}
 
+// RegisterRawHandler satisfies the Consumer interface
+func (c *StubConsumer) RegisterRawHandler(name string, h gosqs.RawHandler, a ...gosqs.Adapter) {}
+
+// PeekMessage satisfies the Consumer interface
+func (c *StubConsumer) PeekMessage(ctx context.Context, messageID string) (gosqs.Message, error) {
+	return nil, nil
+}
+
+// RegisterSchema satisfies the Consumer interface
+func (c *StubConsumer) RegisterSchema(msgType string, schema []byte) error { return nil }
+
+// ProcessOne satisfies the Consumer interface
+func (c *StubConsumer) ProcessOne(ctx context.Context, m gosqs.Message) error { return nil }
+
+// QueueTags satisfies the Consumer interface
+func (c *StubConsumer) QueueTags(ctx context.Context) (map[string]string, error) { return nil, nil }
+
+// QueueDepth satisfies the Consumer interface
+func (c *StubConsumer) QueueDepth() (gosqs.QueueDepth, error) { return gosqs.QueueDepth{}, nil }
+
+// RegisterResultHandler satisfies the Consumer interface
+func (c *StubConsumer) RegisterResultHandler(name string, h gosqs.ResultHandler, a ...gosqs.Adapter) {
+}
+
+// RequireHandlers satisfies the Consumer interface
+func (c *StubConsumer) RequireHandlers(types ...string) error { return nil }
+
 // StubPublisher provides a stub framework for service unit tests
 //
-// SNS messages event names will go into the DispatcherMessages string array
+// SNS messages event names will go into the DispatcherMessages string array.
 //
 // Direct Messages to SQS will go into a map[string]string which defines
 // the queueName as the key and the event as the value. If a message is
@@ -225,3 +398,61 @@ func (c *StubPublisher) Message(queue, event string, body interface{}) {
 	c.DirectMessages = append(c.DirectMessages, sm)
 	c.EventList = append(c.EventList, sm.Event)
 }
+
+// Publish saves the message in the dispatcher array and satisfies the Publisher interface
+func (c *StubPublisher) Publish(ctx context.Context, event string, body interface{}, opts ...gosqs.PublishOption) (string, error) {
+	sm := SentMessage{
+		Event: event,
+		Body:  body,
+	}
+	c.DispatcherMessages = append(c.DispatcherMessages, sm)
+	c.EventList = append(c.EventList, sm.Event)
+	return fmt.Sprintf("stub-%d", len(c.EventList)), nil
+}
+
+// PublishReader saves the message read from r as a json.RawMessage body in the dispatcher array and satisfies the Publisher interface
+func (c *StubPublisher) PublishReader(ctx context.Context, event string, r io.Reader, opts ...gosqs.PublishOption) (string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Publish(ctx, event, json.RawMessage(b), opts...)
+}
+
+// PublishTo saves the message into the local map and satisfies the Publisher interface
+func (c *StubPublisher) PublishTo(ctx context.Context, queue, event string, body interface{}, opts ...gosqs.PublishOption) (string, error) {
+	sm := SentMessage{
+		QueueName: queue,
+		Event:     event,
+		Body:      body,
+	}
+	c.DirectMessages = append(c.DirectMessages, sm)
+	c.EventList = append(c.EventList, sm.Event)
+	return fmt.Sprintf("stub-%d", len(c.EventList)), nil
+}
+
+// ResolvedTopicARN satisfies the Publisher interface
+func (c *StubPublisher) ResolvedTopicARN() string {
+	return ""
+}
+
+// PublishBatch saves every entry into the local map and satisfies the Publisher interface
+func (c *StubPublisher) PublishBatch(ctx context.Context, queue string, entries []gosqs.BatchEntry) (gosqs.BatchResult, error) {
+	results := make(gosqs.BatchResult, len(entries))
+	for i, e := range entries {
+		id, _ := c.PublishTo(ctx, queue, e.Event, e.Body, e.Options...)
+		results[i] = gosqs.BatchEntryResult{MessageID: id}
+	}
+	return results, nil
+}
+
+// Flush satisfies the Publisher interface
+func (c *StubPublisher) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close satisfies the Publisher interface
+func (c *StubPublisher) Close(ctx context.Context) error {
+	return nil
+}
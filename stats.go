@@ -0,0 +1,135 @@
+package gosqs
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// throttleBaseDelay is the first pause applied once SQS throttles ReceiveMessage, it doubles on every consecutive throttle
+	throttleBaseDelay = time.Second
+	// throttleMaxDelay caps the pause between throttled receives
+	throttleMaxDelay = time.Minute
+)
+
+// ConsumerStats is a point in time snapshot of the consumer, it can be polled for dashboards and alerting
+type ConsumerStats struct {
+	// Throttled is true while SQS is throttling ReceiveMessage calls
+	Throttled bool
+	// ThrottledSince is the time the current throttling streak started
+	ThrottledSince time.Time
+	// ThrottleCount is the number of consecutive throttled receives
+	ThrottleCount int
+
+	// ActiveWorkers is the number of workers currently running a handler
+	ActiveWorkers int
+	// IdleWorkers is the number of workers waiting for a message
+	IdleWorkers int
+	// InFlight is the number of received messages that have not finished processing, including messages waiting for a worker
+	InFlight int
+	// Processed is the total number of messages whose handler succeeded
+	Processed int
+	// Failed is the total number of messages whose handler returned an error
+	Failed int
+	// LastReceive is the time of the last successful ReceiveMessage call
+	LastReceive time.Time
+	// LastReceiveRequestID is the SQS request ID of the last ReceiveMessage call, successful or not
+	LastReceiveRequestID string
+}
+
+// consumerStats guards the stats that are shared between the receive loop and the workers
+type consumerStats struct {
+	mu    sync.Mutex
+	stats ConsumerStats
+}
+
+// snapshot returns a copy of the current stats
+func (s *consumerStats) snapshot() ConsumerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// throttled records a throttled receive and returns how long the receive loop should pause
+func (s *consumerStats) throttled(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stats.Throttled {
+		s.stats.Throttled = true
+		s.stats.ThrottledSince = now
+	}
+
+	pause := throttleBaseDelay << uint(s.stats.ThrottleCount)
+	if s.stats.ThrottleCount >= 16 || pause > throttleMaxDelay {
+		pause = throttleMaxDelay
+	}
+
+	s.stats.ThrottleCount++
+	return pause
+}
+
+// resetThrottle records a successful receive or handler run, resetting the throttle state
+func (s *consumerStats) resetThrottle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Throttled = false
+	s.stats.ThrottledSince = time.Time{}
+	s.stats.ThrottleCount = 0
+}
+
+// received records a successful receive and the messages handed to the workers
+func (s *consumerStats) received(now time.Time, messages int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.LastReceive = now
+	s.stats.InFlight += messages
+}
+
+// receiveRequest records the request ID of a ReceiveMessage call
+func (s *consumerStats) receiveRequest(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastReceiveRequestID = id
+}
+
+// started records a worker starting to run a handler
+func (s *consumerStats) started() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.ActiveWorkers++
+}
+
+// finished records the outcome of a handler
+func (s *consumerStats) finished(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.ActiveWorkers--
+	if err != nil {
+		s.stats.Failed++
+		return
+	}
+	s.stats.Processed++
+}
+
+// done records a received message finishing processing
+func (s *consumerStats) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats.InFlight > 0 {
+		s.stats.InFlight--
+	}
+}
+
+// Stats returns a point in time snapshot of the consumer
+func (c *consumer) Stats() ConsumerStats {
+	s := c.stats.snapshot()
+	if idle := c.workerPool - s.ActiveWorkers; idle > 0 {
+		s.IdleWorkers = idle
+	}
+	return s
+}
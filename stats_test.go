@@ -0,0 +1,71 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleStats(t *testing.T) {
+	c := &consumer{}
+	now := time.Now()
+
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if pause := c.stats.throttled(now); pause != expected {
+			t.Fatalf("unexpected pause for throttle %d, expected %s, got %s", i, expected, pause)
+		}
+	}
+
+	s := c.Stats()
+	if !s.Throttled || s.ThrottleCount != 3 || !s.ThrottledSince.Equal(now) {
+		t.Fatalf("unexpected throttle stats, got %+v", s)
+	}
+
+	for i := 0; i < 20; i++ {
+		c.stats.throttled(now)
+	}
+	if pause := c.stats.throttled(now); pause != throttleMaxDelay {
+		t.Errorf("expected the pause to be capped at %s, got %s", throttleMaxDelay, pause)
+	}
+
+	c.stats.resetThrottle()
+	if s := c.Stats(); s.Throttled || s.ThrottleCount != 0 {
+		t.Errorf("expected a successful receive to reset the throttle state, got %+v", s)
+	}
+}
+
+func TestWorkerStats(t *testing.T) {
+	m := newMockSQS()
+	running := make(chan struct{})
+	release := make(chan struct{})
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, workerPool: 4,
+		handlers: map[string]Handler{
+			"blocked": func(ctx context.Context, msg Message) error { running <- struct{}{}; <-release; return nil },
+			"failed":  func(ctx context.Context, msg Message) error { return errors.New("failed") },
+		}}
+
+	m.add("queue", "blocked", "{}")
+	m.add("queue", "failed", "{}")
+
+	var wg sync.WaitGroup
+	c.poll(func(msg *message) {
+		wg.Add(1)
+		go func() { defer wg.Done(); c.run(msg) }()
+	})
+	<-running
+
+	s := c.Stats()
+	if s.ActiveWorkers < 1 || s.IdleWorkers != 4-s.ActiveWorkers || s.InFlight < 1 || s.LastReceive.IsZero() {
+		t.Fatalf("unexpected stats while a handler runs, got %+v", s)
+	}
+
+	close(release)
+	wg.Wait()
+
+	s = c.Stats()
+	if s.ActiveWorkers != 0 || s.IdleWorkers != 4 || s.InFlight != 0 || s.Processed != 1 || s.Failed != 1 {
+		t.Errorf("unexpected stats after processing, got %+v", s)
+	}
+}
@@ -0,0 +1,158 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// concurrentSQS records the most sends that were running at the same time
+type concurrentSQS struct {
+	*mockSQS
+
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (c *concurrentSQS) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.peak {
+		c.peak = c.running
+	}
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	return c.mockSQS.SendMessageWithContext(ctx, in, opts...)
+}
+
+func TestRateLimiter(t *testing.T) {
+	var disabled *rateLimiter
+	if err := disabled.wait(context.TODO()); err != nil {
+		t.Fatalf("expected a disabled limiter not to wait, got %v", err)
+	}
+
+	l := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		l.wait(context.TODO())
+	}
+
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("expected 6 events at 100 per second to take 50ms, got %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newRateLimiter(0.1).wait(ctx); err != nil {
+		t.Fatalf("expected the first event to pass, got %v", err)
+	}
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Errorf("expected a cancelled wait to fail, got %v", err)
+	}
+}
+
+func TestRedriveThrottling(t *testing.T) {
+	m := &concurrentSQS{mockSQS: newMockSQS()}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+	for i := 0; i < 25; i++ {
+		m.add("dlq", "post_created", "{}")
+	}
+
+	start := time.Now()
+	moved, err := c.RedriveDLQ(context.TODO(), "dlq", WithRedriveConcurrency(3), WithRedriveRate(500))
+	if err != nil || moved != 25 {
+		t.Fatalf("expected every message to be moved, got %d, %v", moved, err)
+	}
+
+	if m.peak > 3 || m.peak < 2 {
+		t.Errorf("expected up to 3 concurrent moves, got %d", m.peak)
+	}
+
+	if d := time.Since(start); d < 48*time.Millisecond {
+		t.Errorf("expected 25 messages at 500 per second to take 48ms, got %v", d)
+	}
+
+	if len(m.queues["queue"]) != 25 || len(m.deleted) != 25 {
+		t.Errorf("expected the messages to be moved, got %d sent and %d deleted", len(m.queues["queue"]), len(m.deleted))
+	}
+}
+
+func TestMaxMessagesPerSecond(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2, throttle: newRateLimiter(200)}
+	c.RegisterHandler("post_created", func(ctx context.Context, m Message) error { return nil })
+	for i := 0; i < 5; i++ {
+		m.add("queue", "post_created", "{}")
+	}
+
+	start := time.Now()
+	c.poll(func(msg *message) { c.run(msg) })
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("expected 5 messages at 200 per second to take 20ms, got %v", d)
+	}
+
+	if len(m.deleted) != 5 {
+		t.Errorf("expected every message to be processed, got %d", len(m.deleted))
+	}
+}
+
+func TestRedriveFIFOSequential(t *testing.T) {
+	m := &concurrentSQS{mockSQS: newMockSQS()}
+	c := &consumer{sqs: m, QueueURL: "queue.fifo", logger: &testLogger{}}
+	for i := 0; i < 5; i++ {
+		msg := m.add("dlq.fifo", "post_created", fmt.Sprintf(`{"val":"%d"}`, i))
+		msg.Attributes[sqs.MessageSystemAttributeNameMessageGroupId] = aws.String("post-1")
+	}
+
+	if moved, err := c.RedriveDLQ(context.TODO(), "dlq.fifo", WithRedriveConcurrency(3)); err != nil || moved != 5 {
+		t.Fatalf("expected every message to be moved, got %d, %v", moved, err)
+	}
+
+	if m.peak != 1 {
+		t.Errorf("expected the messages of a FIFO queue to be moved one at a time, got %d", m.peak)
+	}
+
+	for i, in := range m.sent {
+		if want := fmt.Sprintf(`{"val":"%d"}`, i); *in.MessageBody != want {
+			t.Errorf("expected the group to keep its order, got %s at %d", *in.MessageBody, i)
+		}
+	}
+}
+
+func TestRedriveRateCancelled(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}}
+	for i := 0; i < 3; i++ {
+		m.add("dlq", "post_created", "{}")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	moved, err := c.RedriveDLQ(ctx, "dlq", WithRedriveRate(1))
+	if err == nil || moved != 1 {
+		t.Fatalf("expected the run to stop after the first message, got %d, %v", moved, err)
+	}
+
+	if len(m.visibilities) != 2 {
+		t.Errorf("expected the rest of the batch to be released, got %v", m.visibilities)
+	}
+
+	for h, v := range m.visibilities {
+		if v != 0 {
+			t.Errorf("expected %s to be visible right away, got %d", h, v)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package gosqs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MessageTimings breaks down where the time of a single message went, it is passed to the TimingsHook once the consumer
+// is done with the message
+type MessageTimings struct {
+	// Route is the event the message was routed with, e.g. post_created
+	Route string
+	// MessageID is the SQS message ID
+	MessageID string
+	// Dispatch is the time between the receive returning the message and the start of its handler, it grows when every
+	// worker is busy or when the message waits for its message group
+	Dispatch time.Duration
+	// Decode is the time the handler spent in Decode, DecodeContext and DecodeModified, it is part of Handler
+	Decode time.Duration
+	// Handler is how long the handler ran, 0 for messages without a handler
+	Handler time.Duration
+	// Delete is how long deleting the message took, 0 when it was not deleted after the handler run, e.g. because the
+	// handler failed, the message was deferred or its delete is withheld for an atomic batch
+	Delete time.Duration
+	// Deleted is true when the message was deleted after the handler run
+	Deleted bool
+	// Err is the error returned by the handler, nil if it succeeded
+	Err error
+}
+
+// TimingsHookFunc receives the timings of every processed message. It is called from the worker goroutines and must be
+// safe for concurrent use, slow hooks delay the processing of the next message
+type TimingsHookFunc func(MessageTimings)
+
+// timeDecode adds the time since start to the decode time of the message
+func (m *message) timeDecode(start time.Time) {
+	atomic.AddInt64(&m.decodeTime, int64(time.Since(start)))
+}
+
+// newTimings starts the timings of a message whose handler is about to run, nil if no TimingsHook is configured
+func (c *consumer) newTimings(m *message) *MessageTimings {
+	if c.timingsHook == nil {
+		return nil
+	}
+
+	t := &MessageTimings{Route: m.Route()}
+	if m.MessageId != nil {
+		t.MessageID = *m.MessageId
+	}
+	if !m.receivedAt.IsZero() {
+		t.Dispatch = time.Since(m.receivedAt)
+	}
+
+	return t
+}
+
+// handled records the handler run
+func (t *MessageTimings) handled(m *message, d time.Duration, err error) {
+	if t == nil {
+		return
+	}
+
+	t.Handler, t.Err = d, err
+	t.Decode = time.Duration(atomic.LoadInt64(&m.decodeTime))
+}
+
+// timeDelete runs the delete and records how long it took
+func (t *MessageTimings) timeDelete(del func() error) error {
+	if t == nil {
+		return del()
+	}
+
+	start := time.Now()
+	err := del()
+	t.Delete, t.Deleted = time.Since(start), err == nil
+	return err
+}
+
+// reportTimings passes the timings to the hook
+func (c *consumer) reportTimings(t *MessageTimings) {
+	if t != nil {
+		c.timingsHook(*t)
+	}
+}
@@ -0,0 +1,55 @@
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimingsHook(t *testing.T) {
+	m := newMockSQS()
+	m.add("queue", "post_created", `{"val":"a"}`)
+	m.add("queue", "post_failed", `{"val":"b"}`)
+	m.add("queue", "post_unknown", `{}`)
+
+	var mu sync.Mutex
+	timings := map[string]MessageTimings{}
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2,
+		timingsHook: func(t MessageTimings) {
+			mu.Lock()
+			defer mu.Unlock()
+			timings[t.Route] = t
+		},
+		handlers: map[string]Handler{
+			"post_created": func(ctx context.Context, msg Message) error {
+				var s sample
+				time.Sleep(10 * time.Millisecond)
+				return msg.Decode(&s)
+			},
+			"post_failed": func(ctx context.Context, msg Message) error {
+				return errors.New("failed")
+			},
+		}}
+	c.poll(func(msg *message) { c.run(msg) })
+
+	ok := timings["post_created"]
+	if ok.Handler < 10*time.Millisecond || ok.Decode <= 0 || ok.Decode > ok.Handler {
+		t.Errorf("expected the handler and decode time, got %+v", ok)
+	}
+
+	if !ok.Deleted || ok.Err != nil || ok.MessageID != "1" {
+		t.Errorf("expected the successful message to be deleted, got %+v", ok)
+	}
+
+	failed := timings["post_failed"]
+	if failed.Deleted || failed.Delete != 0 || failed.Err == nil {
+		t.Errorf("expected the failed message to report its error without a delete, got %+v", failed)
+	}
+
+	unknown, reported := timings["post_unknown"]
+	if !reported || unknown.Handler != 0 || !unknown.Deleted {
+		t.Errorf("expected messages without a handler to report the delete only, got %+v", unknown)
+	}
+}
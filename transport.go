@@ -0,0 +1,85 @@
+package gosqs
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// Transport provides the SQS and SNS clients consumers and publishers send their requests through. Unless Config.Transport
+// is set the AWS clients of the session returned by the SessionProvider are used. The memory package provides an in-memory
+// transport for unit tests and local development without AWS or an emulator
+type Transport interface {
+	SQS() sqsiface.SQSAPI
+	SNS() snsiface.SNSAPI
+}
+
+// awsTransport is the transport backed by the AWS clients
+type awsTransport struct {
+	sqs sqsiface.SQSAPI
+	sns snsiface.SNSAPI
+}
+
+func (t *awsTransport) SQS() sqsiface.SQSAPI {
+	return t.sqs
+}
+
+func (t *awsTransport) SNS() snsiface.SNSAPI {
+	return t.sns
+}
+
+// transport returns the configured transport or creates the AWS transport from the session. Errors of the SessionProvider
+// are returned as is
+func (c Config) transport() (Transport, error) {
+	if c.Transport != nil {
+		return c.Transport, nil
+	}
+
+	if c.SessionProvider == nil {
+		c.SessionProvider = newSession
+	}
+
+	sess, err := c.SessionProvider(c)
+	if err != nil {
+		return nil, err
+	}
+
+	sqsClient, snsClient := sqs.New(sess), sns.New(sess)
+	if c.TopicRegion != "" {
+		snsClient = sns.New(sess, aws.NewConfig().WithRegion(c.TopicRegion))
+	}
+
+	c.applyRequestHandlers(&sqsClient.Handlers)
+	c.applyRequestHandlers(&snsClient.Handlers)
+	return &awsTransport{sqs: sqsClient, sns: snsClient}, nil
+}
+
+// failoverSNS returns the SNS client of the failover region, the client of the Transport when one is set
+func (c Config) failoverSNS() (snsiface.SNSAPI, error) {
+	if c.Transport != nil {
+		return c.Transport.SNS(), nil
+	}
+
+	if c.SessionProvider == nil {
+		c.SessionProvider = newSession
+	}
+
+	sess, err := c.SessionProvider(c)
+	if err != nil {
+		return nil, err
+	}
+
+	client := sns.New(sess, aws.NewConfig().WithRegion(c.failoverRegion()))
+	c.applyRequestHandlers(&client.Handlers)
+	return client, nil
+}
+
+// applyRequestHandlers applies Config.RequestHandlers to the handlers of a client
+func (c Config) applyRequestHandlers(h *request.Handlers) {
+	for _, fn := range c.RequestHandlers {
+		fn(h)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build go1.18
+// +build go1.18
+
+package gosqs
+
+import "context"
+
+// MessageType binds an event to the Go type of its body so that the publisher and the handler of the event cannot
+// disagree on the payload, e.g.
+//
+//	var PostPublished = gosqs.NewMessageType[Post]("post_published")
+//
+//	PostPublished.Publish(ctx, pub, post)
+//	PostPublished.Register(consumer, func(ctx context.Context, post Post, m gosqs.Message) error { ... })
+type MessageType[T any] struct {
+	event string
+}
+
+// NewMessageType creates a MessageType for the event
+func NewMessageType[T any](event string) MessageType[T] {
+	return MessageType[T]{event: event}
+}
+
+// Event returns the event name the type is bound to
+func (t MessageType[T]) Event() string {
+	return t.event
+}
+
+// Publish sends the body to the topic, see Publisher.Publish
+func (t MessageType[T]) Publish(ctx context.Context, p Publisher, body T, opts ...PublishOption) (string, error) {
+	return p.Publish(ctx, t.event, body, opts...)
+}
+
+// PublishTo sends the body to an individual queue, see Publisher.PublishTo
+func (t MessageType[T]) PublishTo(ctx context.Context, p Publisher, queue string, body T, opts ...PublishOption) (string, error) {
+	return p.PublishTo(ctx, queue, t.event, body, opts...)
+}
+
+// Handler decodes the message into T before calling h, decode failures are returned as ErrDecode
+func (t MessageType[T]) Handler(h func(ctx context.Context, body T, m Message) error) Handler {
+	return func(ctx context.Context, m Message) error {
+		var body T
+		if err := m.DecodeContext(ctx, &body); err != nil {
+			return err
+		}
+
+		return h(ctx, body, m)
+	}
+}
+
+// Register registers the typed handler for the event on the consumer
+func (t MessageType[T]) Register(c Consumer, h func(ctx context.Context, body T, m Message) error, adapters ...Adapter) {
+	c.RegisterHandler(t.event, t.Handler(h), adapters...)
+}
+
+// PublishTyped sends the payload to the topic as the event msgType, see Publisher.Publish
+func PublishTyped[T any](ctx context.Context, p Publisher, msgType string, payload T, opts ...PublishOption) (string, error) {
+	return NewMessageType[T](msgType).Publish(ctx, p, payload, opts...)
+}
@@ -0,0 +1,65 @@
+//go:build go1.18
+// +build go1.18
+
+package gosqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMessageType(t *testing.T) {
+	postPublished := NewMessageType[sample]("post_published")
+
+	m := newMockSQS()
+	p := &publisher{sqs: m, env: "dev", sqsURL: "http://localhost:4100/"}
+	c := &consumer{sqs: m, QueueURL: "http://localhost:4100/dev-post-worker", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 2}
+
+	var got []sample
+	postPublished.Register(c, func(ctx context.Context, s sample, msg Message) error {
+		got = append(got, s)
+		return nil
+	})
+
+	if _, err := postPublished.PublishTo(context.TODO(), p, "post-worker", sample{Val: "hello"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	m.add(c.QueueURL, postPublished.Event(), "not json")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(got) != 1 || got[0].Val != "hello" {
+		t.Fatalf("expected the typed body to be handled, got %+v", got)
+	}
+
+	if len(m.deleted) != 1 {
+		t.Errorf("expected only the decoded message to be deleted, got %v", m.deleted)
+	}
+}
+
+func TestMessageTypeDecodeError(t *testing.T) {
+	h := NewMessageType[sample]("post_published").Handler(func(ctx context.Context, s sample, msg Message) error {
+		t.Fatal("handler must not be called for a malformed body")
+		return nil
+	})
+
+	m := newMockSQS()
+	msg := newMessage(m.add("queue", "post_published", "not json"))
+	if err := h(context.TODO(), msg); !errors.Is(err, ErrDecode) {
+		t.Fatalf("expected %v, got %v", ErrDecode, err)
+	}
+}
+
+func TestPublishTyped(t *testing.T) {
+	s := &mockSNS{}
+	p := &publisher{sns: s, arn: "arn:aws:sns:local:000000000000:dev-post-worker"}
+
+	if _, err := PublishTyped(context.TODO(), p, "post_published", sample{Val: "hello"}); err != nil {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	if len(s.published) != 1 || *s.published[0].Message != `{"val":"hello"}` {
+		t.Fatalf("unexpected published messages, got %v", s.published)
+	}
+}
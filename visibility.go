@@ -0,0 +1,152 @@
+package gosqs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// visibilityBatchWindow is how long an extension waits for others to share a ChangeMessageVisibilityBatch call. Messages
+// received together reach their extension at about the same time
+const visibilityBatchWindow = 50 * time.Millisecond
+
+// maxVisibilityBatch is the largest number of entries ChangeMessageVisibilityBatch accepts
+const maxVisibilityBatch = 10
+
+type visibilityChange struct {
+	handle  *string
+	timeout int64
+	result  chan error
+}
+
+// visibilityBatcher collects visibility changes requested within the batch window. The zero value is ready to use
+type visibilityBatcher struct {
+	mu      sync.Mutex
+	pending []visibilityChange
+}
+
+// add queues the change and returns the batch to send right away once it is full
+func (b *visibilityBatcher) add(change visibilityChange, flush func([]visibilityChange)) {
+	b.mu.Lock()
+	b.pending = append(b.pending, change)
+	switch len(b.pending) {
+	case 1:
+		time.AfterFunc(visibilityBatchWindow, func() {
+			if batch := b.take(); len(batch) > 0 {
+				flush(batch)
+			}
+		})
+	case maxVisibilityBatch:
+		batch := b.pending
+		b.pending = nil
+		b.mu.Unlock()
+		flush(batch)
+		return
+	}
+	b.mu.Unlock()
+}
+
+func (b *visibilityBatcher) take() []visibilityChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// changeVisibility sets the visibility timeout of a received message, changes requested around the same time are sent
+// together with ChangeMessageVisibilityBatch
+func (c *consumer) changeVisibility(handle *string, timeout int64) error {
+	return c.changeVisibilityContext(context.Background(), handle, timeout)
+}
+
+// changeVisibilityContext is changeVisibility returning the context's error once it is done, the change may still be sent
+// with the batch it was added to
+func (c *consumer) changeVisibilityContext(ctx context.Context, handle *string, timeout int64) error {
+	change := visibilityChange{handle: handle, timeout: timeout, result: make(chan error, 1)}
+	c.visibility.add(change, c.sendVisibility)
+
+	select {
+	case err := <-change.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendVisibility sends a batch of visibility changes and reports the outcome of every entry to its requester
+func (c *consumer) sendVisibility(batch []visibilityChange) {
+	if len(batch) == 1 {
+		_, err := c.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{QueueUrl: &c.QueueURL, ReceiptHandle: batch[0].handle, VisibilityTimeout: &batch[0].timeout})
+		if err != nil {
+			err = ErrUnableToExtend.Context(err)
+		}
+		batch[0].result <- err
+		return
+	}
+
+	entries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, len(batch))
+	for i := range batch {
+		entries[i] = &sqs.ChangeMessageVisibilityBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: batch[i].handle, VisibilityTimeout: &batch[i].timeout}
+	}
+
+	out, err := c.sqs.ChangeMessageVisibilityBatch(&sqs.ChangeMessageVisibilityBatchInput{QueueUrl: &c.QueueURL, Entries: entries})
+	if err != nil {
+		for _, change := range batch {
+			change.result <- ErrUnableToExtend.Context(err)
+		}
+		return
+	}
+
+	failed := map[string]error{}
+	for _, f := range out.Failed {
+		failed[aws.StringValue(f.Id)] = ErrUnableToExtend.Context(fmt.Errorf("%s: %s", aws.StringValue(f.Code), aws.StringValue(f.Message)))
+	}
+
+	for i, change := range batch {
+		change.result <- failed[strconv.Itoa(i)]
+	}
+}
+
+// visibilityOf returns the visibility timeout of a received message, the timeout of its route if RouteVisibility has one
+func (c *consumer) visibilityOf(m *message) int {
+	if m.visibility > 0 {
+		return m.visibility
+	}
+
+	return c.VisibilityTimeout
+}
+
+// applyRouteVisibility changes the visibility of the received messages whose route has a timeout in RouteVisibility. The
+// changes are sent concurrently so they share ChangeMessageVisibilityBatch calls, a message whose change fails keeps the
+// visibility timeout of the receive
+func (c *consumer) applyRouteVisibility(messages []*message) {
+	if len(c.routeVisibility) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, m := range messages {
+		timeout, ok := c.routeVisibility[m.Route()]
+		if !ok || timeout == c.VisibilityTimeout {
+			continue
+		}
+
+		wg.Add(1)
+		go func(m *message, timeout int) {
+			defer wg.Done()
+			if err := c.changeVisibility(m.ReceiptHandle, int64(timeout)); err != nil {
+				c.logMessage(m, err.Error(), m.Route())
+				return
+			}
+			m.visibility = timeout
+		}(m, timeout)
+	}
+	wg.Wait()
+}
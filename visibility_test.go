@@ -0,0 +1,131 @@
+package gosqs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestChangeVisibilityBatch(t *testing.T) {
+	m := newMockSQS()
+	c := &consumer{sqs: m, QueueURL: "queue"}
+
+	var handles []*string
+	for i := 0; i < 3; i++ {
+		m.add("queue", "post_created", "{}")
+	}
+	out, _ := m.ReceiveMessage(c.receiveInput())
+	for _, msg := range out.Messages {
+		handles = append(handles, msg.ReceiptHandle)
+	}
+	handles = append(handles, aws.String("expired"))
+
+	errs := make([]error, len(handles))
+	var wg sync.WaitGroup
+	for i, h := range handles {
+		wg.Add(1)
+		go func(i int, h *string) {
+			defer wg.Done()
+			errs[i] = c.changeVisibility(h, 60)
+		}(i, h)
+	}
+	wg.Wait()
+
+	if m.visibilityBatches != 1 {
+		t.Errorf("expected a single batch call, got %d", m.visibilityBatches)
+	}
+
+	for i, h := range handles[:3] {
+		if errs[i] != nil || m.visibilities[*h] != 60 {
+			t.Errorf("expected %s to be extended, got %v", *h, errs[i])
+		}
+	}
+
+	if errs[3] == nil {
+		t.Error("expected the failed entry to be reported")
+	}
+}
+
+func TestExtendMaxProcessingTime(t *testing.T) {
+	m := newMockSQS()
+	m.add("queue", "post_created", "{}")
+	out, _ := m.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: aws.String("queue")})
+	msg := newMessage(out.Messages[0])
+
+	var exhausted []Message
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 11, extensionLimit: 10,
+		maxProcessingTime: 500 * time.Millisecond, onExtensionExhausted: func(m Message) { exhausted = append(exhausted, m) }}
+
+	// the first extension is due after a second, by then the max processing time has passed
+	c.extend(context.TODO(), msg)
+
+	if len(exhausted) != 1 || exhausted[0] != msg {
+		t.Fatalf("expected the exhausted hook to be called with the message, got %v", exhausted)
+	}
+
+	if len(m.visibilities) != 0 {
+		t.Errorf("expected no extension, got %v", m.visibilities)
+	}
+}
+
+func TestExtendAfter(t *testing.T) {
+	tests := map[int]time.Duration{30: 20 * time.Second, 11: time.Second, 10: 5 * time.Second, 3: 1500 * time.Millisecond, 1: time.Second, 0: time.Second}
+	for visibility, want := range tests {
+		if got := extendAfter(visibility); got != want {
+			t.Errorf("expected a visibility of %d to be extended after %v, got %v", visibility, want, got)
+		}
+	}
+}
+
+func TestExtendMaxProcessingTimeVisibility(t *testing.T) {
+	m := newMockSQS()
+	m.add("queue", "post_created", "{}")
+	out, _ := m.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: aws.String("queue")})
+	msg := newMessage(out.Messages[0])
+
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 11, extensionLimit: 2,
+		maxProcessingTime: 1500 * time.Millisecond}
+
+	// the visibility is extended once after a second, the max processing time has passed by the second extension
+	c.extend(context.TODO(), msg)
+
+	if v := m.visibilities["receipt-1"]; v != 11 {
+		t.Errorf("expected the extension to last a visibility timeout from now, got %d", v)
+	}
+}
+
+func TestRouteVisibility(t *testing.T) {
+	m := newMockSQS()
+	var seen []int
+	c := &consumer{sqs: m, QueueURL: "queue", logger: &testLogger{}, VisibilityTimeout: 30, extensionLimit: 0,
+		routeVisibility: map[string]int{"report_generated": 300, "post_created": 30}}
+	handler := func(ctx context.Context, msg Message) error {
+		seen = append(seen, c.visibilityOf(msg.(*message)))
+		return nil
+	}
+	c.RegisterHandler("report_generated", handler)
+	c.RegisterHandler("post_created", handler)
+	c.RegisterHandler("post_deleted", handler)
+
+	m.add("queue", "report_generated", "{}")
+	m.add("queue", "report_generated", "{}")
+	m.add("queue", "post_created", "{}")
+	m.add("queue", "post_deleted", "{}")
+	c.poll(func(msg *message) { c.run(msg) })
+
+	if len(m.visibilities) != 2 || m.visibilities["receipt-1"] != 300 || m.visibilities["receipt-2"] != 300 || m.visibilityBatches != 1 {
+		t.Errorf("expected the reports to share a single visibility change, got %v in %d batches", m.visibilities, m.visibilityBatches)
+	}
+
+	if len(seen) != 4 || seen[0] != 300 || seen[2] != 30 || seen[3] != 30 {
+		t.Errorf("expected the extensions to use the route visibility, got %v", seen)
+	}
+
+	if err := (Config{RouteVisibility: map[string]int{"report_generated": 0}}).Validate(); err == nil {
+		t.Error("expected an invalid route visibility to be rejected")
+	}
+}